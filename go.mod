@@ -11,6 +11,7 @@ require (
 	github.com/google/generative-ai-go v0.17.0
 	github.com/gordonklaus/portaudio v0.0.0-20230709114228-aafa478834f5
 	google.golang.org/api v0.190.0
+	google.golang.org/grpc v1.65.0
 )
 
 require (
@@ -68,7 +69,6 @@ require (
 	golang.org/x/time v0.6.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20240730163845-b1a4ccb954bf // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240730163845-b1a4ccb954bf // indirect
-	google.golang.org/grpc v1.65.0 // indirect
 	google.golang.org/protobuf v1.34.2 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
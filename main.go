@@ -4,6 +4,7 @@ import (
     "os"
 	"fmt"
     "log"
+    "flag"
     "regexp"
     "io/ioutil"
     "path/filepath"
@@ -13,6 +14,8 @@ import (
     // Local utilities
     "kai/source/core"
     "kai/source/ui"
+    // Sound hardware, registered as the default audio backend
+    _ "kai/source/audio"
 )
 
 // Method sets the app's environment variables programmatically
@@ -22,7 +25,8 @@ func init() {
         configDir := filepath.Join(".", ".config")
         files, err := ioutil.ReadDir(configDir)
         if err != nil {
-            log.Fatalf("Unable to read .config directory: %v", err)
+            log.Printf("Unable to read .config directory: %v", err)
+            return
         }
         // Regular expression to match service account file
         var re = regexp.MustCompile(`gen-lang-client-\d+-[a-z0-9]+\.json`)
@@ -33,9 +37,12 @@ func init() {
                 return
             }
         }
-        log.Fatalf(
+        // Offline speech engines work without Google Cloud credentials
+        log.Printf(
             "No valid service account file found in %s. "+
-            "Please place the Google Cloud JSON key file in this directory.",
+            "Google Cloud speech features will be unavailable, while text "+
+            "chat keeps working; place the Google Cloud JSON key file in "+
+            "this directory to enable them.",
             configDir,
         )
     } else {
@@ -97,7 +104,9 @@ func initializeKai(state *core.AppState, window fyne.Window) error {
         return nil
     }
     // Attempt to initialize Kai with the API key
-    kai, err := core.InitializeKai(state.Config.APIKey, state.HistoryFile)
+    kai, err := core.InitializeKai(
+        state.Config.APIKey, state.HistoryFile, state.Config,
+    )
     if err != nil {
         // If initialization fails, show the Auth Screen
         ui.ShowAuthScreen(window, state)
@@ -105,7 +114,7 @@ func initializeKai(state *core.AppState, window fyne.Window) error {
     }
     // Assign Kai instance to the application state
     state.Kai = kai
-    defer state.Kai.Client.Close()
+    defer state.Kai.Close()
     // Prime the AI with the default primer
     defaultPrimer, exists := state.Prompts.Primers["Default"]
     if !exists {
@@ -132,12 +141,76 @@ func greetUser(state *core.AppState) {
                        "otherwise just greet the user."
     responseJSON, err := state.Kai.Reason(greetingMessage)
     if err != nil {
-        log.Fatalf("Failed to send greeting message: %v", err)
+        // Not fatal; the user can still type once the service is reachable
+        log.Printf("Failed to send greeting message: %v", err)
+        return
     }
     state.Kai.Respond(responseJSON)
 }
 
+// Method runs the transcribe command, which prints the transcripts of audio 
+// files without starting the app: kai transcribe [-o FILE] AUDIO...
+//
+// Parameters:
+//  - args: The command line arguments after "transcribe".
+//
+// Returns:
+//  - An error if an argument is invalid or a transcription fails
+func runTranscribe(args []string) error {
+    flags := flag.NewFlagSet("transcribe", flag.ExitOnError)
+    output := flags.String("o", "", "write the transcripts to this file")
+    flags.Usage = func() {
+        fmt.Fprintln(flags.Output(), "Usage: kai transcribe [-o FILE] AUDIO...")
+        fmt.Fprintln(flags.Output(), "Transcribes WAV, FLAC and OGG files.")
+        flags.PrintDefaults()
+    }
+    flags.Parse(args)
+    if flags.NArg() == 0 {
+        flags.Usage()
+        return fmt.Errorf("no audio files given")
+    }
+    config, err := core.LoadConfig(".config/config.json")
+    if err != nil {
+        return err
+    }
+    kai, err := core.InitializeTranscriber(config)
+    if err != nil {
+        return err
+    }
+    defer kai.Recognizer.Close()
+    out := os.Stdout
+    if *output != "" {
+        if out, err = os.Create(*output); err != nil {
+            return err
+        }
+        defer out.Close()
+    }
+    for _, file := range flags.Args() {
+        transcript, err := kai.TranscribeFile(
+            kai.Context, file, func(chunk, chunks int) {
+                fmt.Fprintf(os.Stderr, "Transcribing %s (%d/%d)\n", file, chunk, chunks)
+            },
+        )
+        if err != nil {
+            return fmt.Errorf("%s: %v", file, err)
+        }
+        // Label the transcripts when there are several
+        if flags.NArg() > 1 {
+            fmt.Fprintf(out, "%s:\n", file)
+        }
+        fmt.Fprintln(out, transcript.Text)
+    }
+    return nil
+}
+
 func main() {
+    // Run a command instead of the app
+    if len(os.Args) > 1 && os.Args[1] == "transcribe" {
+        if err := runTranscribe(os.Args[2:]); err != nil {
+            log.Fatalf("Failed to transcribe: %v", err)
+        }
+        return
+    }
     // Initialize AppState
     state, err := InitializeAppState()
     if err != nil {
@@ -157,9 +230,10 @@ func main() {
         state.Kai, err = core.InitializeKai(
             state.Config.APIKey,
             state.HistoryFile,
+            state.Config,
         )
         if err == nil {
-            defer state.Kai.Client.Close()
+            defer state.Kai.Close()
             // Prime the AI with the default primer
             defaultPrimer, exists := state.Prompts.Primers["Default"]
             if !exists {
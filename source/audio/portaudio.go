@@ -0,0 +1,352 @@
+// Package audio plays and records sound through PortAudio. It is kept out
+// of the core, which needs the C library neither to build nor to test, and
+// registers itself as the core's "portaudio" audio backend when imported.
+package audio
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"context"
+	"github.com/gordonklaus/portaudio"
+	// Local imports
+	"kai/source/core"
+	"kai/source/utils"
+)
+
+// Method registers PortAudio as the default audio backend.
+func init() {
+	core.RegisterAudioBackend("portaudio", func(config *core.AudioConfig) core.AudioIO {
+		return &PortAudioIO{Config: config}
+	})
+}
+
+// Sample rates tried, after the preferred rate and the device's default
+// rate, when opening a device.
+var fallbackSampleRates = []int{48000, 44100, 32000, 22050, 16000}
+
+// Frames read from the microphone at a time.
+const inputFramesPerBuffer = 64
+
+// Frames written to the speakers at a time.
+const outputFramesPerBuffer = 1024
+
+// PortAudioIO records from and plays to sound hardware through PortAudio.
+// The library is initialized on first use and terminated by Close. It is
+// safe for concurrent use, so speech can play while the microphone records.
+type PortAudioIO struct {
+	// Devices to use; the system defaults if nil or unset
+	Config      *core.AudioConfig
+	mutex       sync.Mutex
+	initialized bool
+	reported    map[string]bool // Devices whose resampling was logged
+}
+
+// The format negotiated with a device: its stream parameters, and the
+// sample rate and channel count they use.
+type audioFormat struct {
+	params     portaudio.StreamParameters
+	sampleRate int
+	channels   int
+}
+
+// Method reads the configured input device until the stop channel closes or
+// onSamples returns false. The device is recorded at a sample rate it
+// supports and the samples are converted to mono at the requested rate, so
+// recognizers always receive audio at the rate they are told.
+func (a *PortAudioIO) Record(
+	stop <-chan struct{},
+	sampleRate int,
+	onSamples func(samples []int16) bool,
+) error {
+	if err := a.initialize(); err != nil {
+		return err
+	}
+	format, err := a.negotiate(true, sampleRate)
+	if err != nil {
+		return err
+	}
+	// Create an input buffer to store audio samples
+	format.params.FramesPerBuffer = inputFramesPerBuffer
+	in := make([]int16, format.params.FramesPerBuffer*format.channels)
+	resampler := utils.NewResampler(format.sampleRate, sampleRate)
+	// Open a stream for audio input
+	stream, err := portaudio.OpenStream(format.params, in)
+	if err != nil {
+		return fmt.Errorf("failed to open input stream: %v", err)
+	}
+	defer stream.Close()
+	// Start the audio stream
+	if err := stream.Start(); err != nil {
+		return fmt.Errorf("failed to start the stream: %v", err)
+	}
+	defer stream.Stop()
+	for {
+		select {
+		case <-stop:
+			// Stop recording when a signal is received on the stop channel
+			return nil
+		default:
+			// Read audio samples into the input buffer
+			if err := stream.Read(); err != nil {
+				return fmt.Errorf("failed to read from stream: %v", err)
+			}
+			samples := resampler.Process(
+				utils.DownmixSamples(in, format.channels),
+			)
+			if len(samples) > 0 && !onSamples(samples) {
+				return nil
+			}
+		}
+	}
+}
+
+// Method plays samples on the configured output device, resampled to a
+// rate the device supports. Playback stops as soon as the context is
+// cancelled.
+func (a *PortAudioIO) Play(
+	ctx context.Context,
+	samples []int16,
+	sampleRate int,
+) error {
+	if err := a.initialize(); err != nil {
+		return err
+	}
+	format, err := a.negotiate(false, sampleRate)
+	if err != nil {
+		return err
+	}
+	samples = utils.Resample(samples, sampleRate, format.sampleRate)
+	format.params.FramesPerBuffer = outputFramesPerBuffer
+	currentIndex := 0
+	// Closed by the audio callback once all data has been written
+	finished := make(chan struct{})
+	var finishOnce sync.Once
+	// Open a stream for audio playback
+	stream, err := portaudio.OpenStream(
+		format.params,
+		func(out []int16) {
+			// Write each sample to every channel
+			for i := 0; i < len(out); i += format.channels {
+				sample := int16(0) // Fill with silence if the data is finished
+				if currentIndex < len(samples) {
+					sample = samples[currentIndex]
+					currentIndex++
+				}
+				for channel := 0; channel < format.channels; channel++ {
+					out[i+channel] = sample
+				}
+			}
+			if currentIndex >= len(samples) {
+				finishOnce.Do(func() { close(finished) })
+			}
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to open output stream: %v", err)
+	}
+	defer stream.Close()
+	// Start the audio stream
+	if err := stream.Start(); err != nil {
+		return fmt.Errorf("failed to start stream: %v", err)
+	}
+	// Wait for the audio to finish playing, or cut it off when interrupted
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		if err := stream.Abort(); err != nil {
+			return fmt.Errorf("failed to abort stream: %v", err)
+		}
+		return ctx.Err()
+	}
+	// Stop the audio stream
+	if err := stream.Stop(); err != nil {
+		return fmt.Errorf("failed to stop stream: %v", err)
+	}
+	return nil
+}
+
+// Method lists the audio devices.
+func (a *PortAudioIO) Devices() ([]core.AudioDevice, error) {
+	if err := a.initialize(); err != nil {
+		return nil, err
+	}
+	infos, err := portaudio.Devices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audio devices: %v", err)
+	}
+	devices := make([]core.AudioDevice, 0, len(infos))
+	for _, info := range infos {
+		devices = append(devices, core.AudioDevice{
+			Name:              info.Name,
+			Input:             info.MaxInputChannels > 0,
+			Output:            info.MaxOutputChannels > 0,
+			DefaultSampleRate: int(info.DefaultSampleRate),
+		})
+	}
+	return devices, nil
+}
+
+// Method terminates PortAudio if it was initialized.
+func (a *PortAudioIO) Close() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if !a.initialized {
+		return nil
+	}
+	a.initialized = false
+	return portaudio.Terminate()
+}
+
+// Method initializes PortAudio once.
+func (a *PortAudioIO) initialize() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.initialized {
+		return nil
+	}
+	if err := portaudio.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize PortAudio: %v", err)
+	}
+	a.initialized = true
+	return nil
+}
+
+// Method negotiates the format of the configured input or output device,
+// or of the default device if none is configured or the configured one is
+// missing.
+//
+// Parameters:
+//  - input: Whether to open an input device rather than an output device.
+//  - sampleRate: The sample rate the caller works at.
+//
+// Returns:
+//  - audioFormat: The negotiated format.
+//  - error: Error if there is no usable device.
+func (a *PortAudioIO) negotiate(input bool, sampleRate int) (audioFormat, error) {
+	name := ""
+	if a.Config != nil {
+		name = a.Config.OutputDevice
+		if input {
+			name = a.Config.InputDevice
+		}
+	}
+	device, err := findAudioDevice(name, input)
+	if err != nil {
+		return audioFormat{}, err
+	}
+	format, err := negotiateFormat(device, input, sampleRate)
+	if err != nil {
+		return audioFormat{}, err
+	}
+	// Report resampling once per device rather than on every use
+	key := fmt.Sprintf("%s@%d", device.Name, format.sampleRate)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if format.sampleRate != sampleRate && !a.reported[key] {
+		log.Printf(
+			"Audio device %q runs at %d Hz; resampling from %d Hz",
+			device.Name, format.sampleRate, sampleRate,
+		)
+		if a.reported == nil {
+			a.reported = make(map[string]bool)
+		}
+		a.reported[key] = true
+	}
+	return format, nil
+}
+
+// Method finds a device by name, falling back to the default device.
+func findAudioDevice(name string, input bool) (*portaudio.DeviceInfo, error) {
+	if name != "" {
+		devices, err := portaudio.Devices()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list audio devices: %v", err)
+		}
+		for _, device := range devices {
+			if device.Name != name {
+				continue
+			}
+			if (input && device.MaxInputChannels > 0) ||
+				(!input && device.MaxOutputChannels > 0) {
+				return device, nil
+			}
+		}
+		log.Printf("Audio device %q not found, using the default device", name)
+	}
+	if input {
+		device, err := portaudio.DefaultInputDevice()
+		if err != nil {
+			return nil, fmt.Errorf("no audio input device: %v", err)
+		}
+		return device, nil
+	}
+	device, err := portaudio.DefaultOutputDevice()
+	if err != nil {
+		return nil, fmt.Errorf("no audio output device: %v", err)
+	}
+	return device, nil
+}
+
+// Method finds a sample rate and channel count the device supports. The
+// preferred rate avoids resampling and is tried first, then the device's
+// default rate; mono is preferred over the device's full channel count.
+//
+// Parameters:
+//  - device: The device to open.
+//  - input: Whether the device is opened for input.
+//  - preferred: The sample rate Kai processes audio at.
+//
+// Returns:
+//  - audioFormat: The supported format.
+//  - error: Error if the device supports none of the candidate formats.
+func negotiateFormat(
+	device *portaudio.DeviceInfo,
+	input bool,
+	preferred int,
+) (audioFormat, error) {
+	rates := append([]int{preferred, int(device.DefaultSampleRate)}, fallbackSampleRates...)
+	maxChannels := device.MaxOutputChannels
+	if input {
+		maxChannels = device.MaxInputChannels
+	}
+	channelCounts := []int{1}
+	if maxChannels > 1 {
+		channelCounts = append(channelCounts, min(maxChannels, 2))
+	}
+	var lastErr error
+	for _, channels := range channelCounts {
+		for _, rate := range rates {
+			if rate <= 0 {
+				continue
+			}
+			params := portaudio.StreamParameters{
+				SampleRate:      float64(rate),
+				FramesPerBuffer: portaudio.FramesPerBufferUnspecified,
+			}
+			if input {
+				params.Input = portaudio.StreamDeviceParameters{
+					Device:   device,
+					Channels: channels,
+					Latency:  device.DefaultLowInputLatency,
+				}
+			} else {
+				params.Output = portaudio.StreamDeviceParameters{
+					Device:   device,
+					Channels: channels,
+					Latency:  device.DefaultHighOutputLatency,
+				}
+			}
+			// Checked with a 16-bit buffer, the format Kai uses
+			var buffer []int16
+			err := portaudio.IsFormatSupported(params, buffer)
+			if err == nil {
+				return audioFormat{params, rate, channels}, nil
+			}
+			lastErr = err
+		}
+	}
+	return audioFormat{}, fmt.Errorf(
+		"audio device %q supports no usable format: %v", device.Name, lastErr,
+	)
+}
@@ -0,0 +1,162 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"errors"
+	"context"
+	"strconv"
+	"strings"
+	"encoding/json"
+)
+
+// ErrAskCancelled is returned by OnAsk when the user dismisses a question.
+var ErrAskCancelled = errors.New("question cancelled")
+
+// Question is a clarifying question asked by the AI, optionally with a set
+// of multiple-choice options.
+type Question struct {
+	Text    string   `json:"question"`
+	Options []string `json:"options"`
+}
+
+// Registers the "ask" response type.
+func init() {
+	RegisterHandler(ResponseHandler{
+		Type:        "ask",
+		Description: "A clarifying question. If a request is ambiguous, " +
+			"do not guess and do not ask in a 'script'; use an 'ask' item " +
+			"instead. The user's answer will be sent back to you.",
+		Fields: []HandlerField{
+			{
+				Name:        "question",
+				Type:        "string",
+				Description: "The question to present.",
+				Required:    true,
+			},
+			{
+				Name:        "options",
+				Type:        "array",
+				Description: "Short multiple-choice answers.",
+			},
+		},
+		Execute: processAsk,
+	})
+}
+
+// Method handles the processing of an "ask" response item. It pauses the
+// response loop until the user answers, then resumes the same turn by
+// feeding the answer back into the AI.
+//
+// Parameters:
+//  - kai: The AI system handling the request.
+//  - data: The JSON-encoded data holding the question and its options.
+//  - branchCount: The current branch count to manage recursion.
+//
+// Returns:
+//  - bool: True if the remaining items of the response should be skipped.
+func processAsk(kai *Kai, data json.RawMessage, branchCount int) bool {
+	var question Question
+	if err := json.Unmarshal(data, &question); err != nil {
+		log.Printf("Failed to parse ask data: %v", err)
+		return false
+	}
+	// Speak the question so voice users hear it
+	if err := kai.Speak(question.Text); err != nil {
+		log.Printf("Failed to speak: %v", err)
+	}
+	answer, err := kai.askUser(question)
+	if errors.Is(err, ErrAskCancelled) || kai.interrupted() {
+		// Dismissed, or the user moved on; the turn ends here
+		log.Printf("Question dismissed: %q", question.Text)
+		return true
+	}
+	if err != nil {
+		log.Printf("Failed to get an answer: %v", err)
+		return true
+	}
+	kai.handleAIResponse(fmt.Sprintf(
+		"You asked the user: %q. The user answered: %q. " +
+		"Continue with the original request using this answer.",
+		question.Text, answer,
+	), branchCount)
+	return true
+}
+
+// Method presents a question to the user and waits for the answer, using
+// the UI if one is attached and the terminal otherwise. Waiting ends when
+// the current turn is interrupted.
+//
+// Parameters:
+//  - question: The question to ask.
+//
+// Returns:
+//  - string: The user's answer, with option numbers resolved to their text.
+//  - error: ErrAskCancelled if the user dismissed the question, the turn's
+//    error if it was interrupted, or another error while waiting.
+func (kai *Kai) askUser(question Question) (string, error) {
+	var answer string
+	var err error
+	if kai.OnAsk != nil {
+		answer, err = kai.OnAsk(kai.currentTurn(), question)
+	} else {
+		answer, err = askOnConsole(kai.currentTurn(), terminal(), question)
+	}
+	if err != nil {
+		return "", err
+	}
+	return resolveOption(question, answer), nil
+}
+
+// Method prints a question and its numbered options, then reads the answer
+// from the terminal until the context is cancelled.
+//
+// Parameters:
+//  - ctx: Cancelled when the turn is interrupted.
+//  - input: The terminal to take the answer from.
+//  - question: The question to ask.
+//
+// Returns:
+//  - string: The raw answer.
+//  - error: Error encountered while reading, if any.
+func askOnConsole(ctx context.Context, input *consoleInput, question Question) (string, error) {
+	fmt.Println(question.Text)
+	for i, option := range question.Options {
+		fmt.Printf("  %d) %s\n", i+1, option)
+	}
+	for {
+		fmt.Print("Answer> ")
+		answer, err := input.readLine(ctx)
+		answer = strings.TrimSpace(answer)
+		if answer != "" {
+			return answer, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read answer: %w", err)
+		}
+	}
+}
+
+// Method maps an answer given as an option number, or as option text in a
+// different case, to the option itself. Free-form answers are returned as is.
+//
+// Parameters:
+//  - question: The question that was asked.
+//  - answer: The user's answer.
+//
+// Returns:
+//  - string: The resolved answer.
+func resolveOption(question Question, answer string) string {
+	answer = strings.TrimSpace(answer)
+	if index, err := strconv.Atoi(answer); err == nil {
+		if index >= 1 && index <= len(question.Options) {
+			return question.Options[index-1]
+		}
+	}
+	for _, option := range question.Options {
+		if strings.EqualFold(strings.TrimSpace(option), answer) {
+			return option
+		}
+	}
+	return answer
+}
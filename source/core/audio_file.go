@@ -0,0 +1,144 @@
+package core
+
+import (
+	"os"
+	"fmt"
+	"sync"
+	"time"
+	"context"
+	"path/filepath"
+	// Local utilities
+	"kai/source/utils"
+)
+
+// Silence appended to every input file, so voice activity detection sees
+// the end of the last utterance.
+const fileInputTrailingSilence = time.Second
+
+// Samples passed to onSamples at a time, like a microphone buffer.
+const fileInputBuffer = 64
+
+// FileAudioIO is a virtual audio device backed by WAV files, for running
+// the voice loop without sound hardware, e.g. in tests and on CI machines.
+// Each recording reads the next input file, and each playback writes a
+// numbered WAV file to the output directory. It is safe for concurrent use.
+type FileAudioIO struct {
+	// WAV files played into the microphone, one per recording
+	Inputs    []string
+	// Directory the played audio is written to; nothing is written if empty
+	OutputDir string
+	mutex     sync.Mutex
+	next      int      // Index of the next input file
+	outputs   []string // Files written so far
+}
+
+// Method creates a file-backed device, checking that the input files exist
+// and creating the output directory.
+//
+// Parameters:
+//  - inputs: The WAV files to record from, in order.
+//  - outputDir: The directory to write played audio to; may be empty.
+//
+// Returns:
+//  - *FileAudioIO: The device.
+//  - error: Error if an input file is missing or the directory cannot be
+//    created.
+func NewFileAudioIO(inputs []string, outputDir string) (*FileAudioIO, error) {
+	paths := make([]string, len(inputs))
+	for i, input := range inputs {
+		paths[i] = expandPath(input)
+		if _, err := os.Stat(paths[i]); err != nil {
+			return nil, fmt.Errorf("audio input file not found: %w", err)
+		}
+	}
+	if outputDir != "" {
+		outputDir = expandPath(outputDir)
+		if err := os.MkdirAll(outputDir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create audio output directory: %w", err)
+		}
+	}
+	return &FileAudioIO{Inputs: paths, OutputDir: outputDir}, nil
+}
+
+// Method records the next input file, resampled to the requested rate and
+// followed by a second of silence. Recording ends with the file, or earlier
+// if the stop channel closes or onSamples returns false. Once all files
+// have been recorded, recordings are empty.
+func (a *FileAudioIO) Record(
+	stop <-chan struct{},
+	sampleRate int,
+	onSamples func(samples []int16) bool,
+) error {
+	a.mutex.Lock()
+	if a.next >= len(a.Inputs) {
+		a.mutex.Unlock()
+		return nil
+	}
+	input := a.Inputs[a.next]
+	a.next++
+	a.mutex.Unlock()
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("failed to read audio input file: %v", err)
+	}
+	samples, fileRate, err := utils.DecodeWav(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %v", input, err)
+	}
+	samples = utils.Resample(samples, fileRate, sampleRate)
+	samples = append(samples, make([]int16, durationSamples(fileInputTrailingSilence, sampleRate))...)
+	for start := 0; start < len(samples); start += fileInputBuffer {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+		if !onSamples(samples[start:min(start+fileInputBuffer, len(samples))]) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Method writes the samples to the next numbered WAV file in the output
+// directory, unless the context has already been cancelled.
+func (a *FileAudioIO) Play(
+	ctx context.Context,
+	samples []int16,
+	sampleRate int,
+) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.OutputDir == "" {
+		return nil
+	}
+	path := filepath.Join(a.OutputDir, fmt.Sprintf("output-%03d.wav", len(a.outputs)+1))
+	if err := utils.WriteWavFile(path, utils.SamplesToBytes(samples), sampleRate); err != nil {
+		return err
+	}
+	a.outputs = append(a.outputs, path)
+	return nil
+}
+
+// Method lists the virtual input and output devices.
+func (a *FileAudioIO) Devices() ([]AudioDevice, error) {
+	return []AudioDevice{
+		{Name: "WAV file input", Input: true},
+		{Name: "WAV file output", Output: true},
+	}, nil
+}
+
+// Method returns the paths of the WAV files written so far, in order.
+func (a *FileAudioIO) Outputs() []string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return append([]string(nil), a.outputs...)
+}
+
+// Method has nothing to release.
+func (a *FileAudioIO) Close() error {
+	return nil
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"os"
+	"math"
+	"context"
+	"testing"
+	"path/filepath"
+	// Local utilities
+	"kai/source/utils"
+)
+
+// toneSynthesizer speaks every text as a tone lasting 10 ms per character,
+// so tests can tell the utterances apart by their length.
+type toneSynthesizer struct{}
+
+func (toneSynthesizer) Synthesize(
+	_ context.Context,
+	text string,
+	_ Voice,
+	sampleRate int,
+) ([]byte, error) {
+	return utils.SamplesToBytes(toneSamples(440, len(text)*sampleRate/100, sampleRate, 0)), nil
+}
+
+func (toneSynthesizer) Close() error {
+	return nil
+}
+
+// Method returns a tone, preceded by the given samples of silence.
+func toneSamples(frequency float64, length, sampleRate, silence int) []int16 {
+	samples := make([]int16, silence+length)
+	for i := 0; i < length; i++ {
+		samples[silence+i] = int16(8000 * math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRate)))
+	}
+	return samples
+}
+
+// Runs a spoken request through the whole voice loop on WAV files: it is
+// recorded, trimmed by voice activity detection, transcribed, answered and
+// the answer is played.
+func TestFileAudioVoiceLoop(t *testing.T) {
+	dir := t.TempDir()
+	// Half a second of silence, then a second of "speech", recorded at a
+	// different rate than Kai works at
+	input := filepath.Join(dir, "request.wav")
+	request := toneSamples(440, 16000, 16000, 8000)
+	if err := utils.WriteWavFile(input, utils.SamplesToBytes(request), 16000); err != nil {
+		t.Fatal(err)
+	}
+	audio, err := NewFileAudioIO([]string{input}, filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recognizer := &FakeRecognizer{Transcripts: []string{"what time is it"}, Language: "en-US"}
+	kai := &Kai{
+		Context:     context.Background(),
+		Config:      &Config{},
+		SampleRate:  44100,
+		Audio:       audio,
+		Recognizer:  recognizer,
+		Synthesizer: toneSynthesizer{},
+	}
+
+	// Listen: the leading silence is trimmed, the speech is kept
+	recorded, err := kai.Listen(nil)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	seconds := float64(len(recorded)/2) / float64(kai.SampleRate)
+	if seconds < 1 || seconds > 1.6 {
+		t.Errorf("recorded %.2f s, want the 1 s of speech and its padding", seconds)
+	}
+
+	// Recognize: the recognizer receives the recording
+	transcript, err := kai.Recognize(recorded)
+	if err != nil {
+		t.Fatalf("Recognize: %v", err)
+	}
+	if transcript.Text != "what time is it" {
+		t.Errorf("got transcript %q", transcript.Text)
+	}
+	if len(recognizer.Calls) != 1 || len(recognizer.Calls[0]) == 0 {
+		t.Fatalf("recognizer got %d calls, want one with audio", len(recognizer.Calls))
+	}
+
+	// Respond and Speak: each utterance is played into its own file
+	kai.Respond(`[{"type": "script", "data": {"message": "It is noon.", "role": "conclusion"}}]`)
+	if err := kai.Speak("Anything else?"); err != nil {
+		t.Fatalf("Speak: %v", err)
+	}
+	outputs := audio.Outputs()
+	want := []string{"It is noon.", "Anything else?"}
+	if len(outputs) != len(want) {
+		t.Fatalf("played %d utterances, want %d", len(outputs), len(want))
+	}
+	for i, output := range outputs {
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples, sampleRate, err := utils.DecodeWav(data)
+		if err != nil {
+			t.Fatalf("%s: %v", output, err)
+		}
+		if sampleRate != kai.SampleRate || len(samples) != len(want[i])*sampleRate/100 {
+			t.Errorf(
+				"%s: %d samples at %d Hz, want the speech of %q at %d Hz",
+				output, len(samples), sampleRate, want[i], kai.SampleRate,
+			)
+		}
+	}
+}
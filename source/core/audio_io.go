@@ -0,0 +1,147 @@
+package core
+
+import (
+	"fmt"
+	"errors"
+	"context"
+)
+
+// Sample rate Kai processes audio at when none is configured.
+const defaultSampleRate = 44100
+
+// AudioIO records and plays 16-bit mono PCM audio. Implementations convert
+// between the requested sample rate and whatever their devices use.
+type AudioIO interface {
+	// Record passes buffers of samples to onSamples until the stop channel
+	// closes, onSamples returns false or the input ends. The buffer may be
+	// reused between calls.
+	Record(stop <-chan struct{}, sampleRate int, onSamples func(samples []int16) bool) error
+	// Play plays samples until they end or the context is cancelled.
+	Play(ctx context.Context, samples []int16, sampleRate int) error
+	// Devices lists the devices that can be chosen in the settings.
+	Devices() ([]AudioDevice, error)
+	Close() error
+}
+
+// AudioDevice describes an audio device that can be chosen in the settings.
+type AudioDevice struct {
+	Name              string
+	Input             bool // Has input channels, e.g. a microphone
+	Output            bool // Has output channels, e.g. speakers
+	DefaultSampleRate int
+}
+
+// Audio backends provided by other packages, by name. PortAudio needs a C
+// library, so it registers itself from its own package and the core builds
+// and tests without it.
+var audioBackends = map[string]func(config *AudioConfig) AudioIO{}
+
+// Backend used when the configuration names none.
+const defaultAudioBackend = "portaudio"
+
+// Method registers an audio backend. It is meant to be called from init
+// functions and panics on duplicate or incomplete backends.
+//
+// Parameters:
+//  - name: The name selecting the backend in the configuration.
+//  - create: Creates the backend from the audio configuration.
+func RegisterAudioBackend(name string, create func(config *AudioConfig) AudioIO) {
+	if name == "" || create == nil {
+		panic("audio backend requires a name and a constructor")
+	}
+	if _, exists := audioBackends[name]; exists {
+		panic(fmt.Sprintf("audio backend %q already registered", name))
+	}
+	audioBackends[name] = create
+}
+
+// Method creates the audio backend selected in the configuration.
+//
+// Parameters:
+//  - config: The audio configuration. The PortAudio backend reads the
+//    devices from it on every use, so changes apply right away.
+//
+// Returns:
+//  - AudioIO: The selected backend.
+//  - error: Error if the backend is unknown or misconfigured.
+func NewAudioIO(config *AudioConfig) (AudioIO, error) {
+	backend := config.Backend
+	if backend == "" {
+		backend = defaultAudioBackend
+	}
+	if backend == "file" {
+		return NewFileAudioIO(config.InputFiles, config.OutputDir)
+	}
+	if create, ok := audioBackends[backend]; ok {
+		return create(config), nil
+	}
+	if backend == defaultAudioBackend {
+		return nil, errNoAudioBackend
+	}
+	return nil, fmt.Errorf("unknown audio backend %q", config.Backend)
+}
+
+// Method creates the default audio backend, or one that reports the error
+// on every use if it was not built in.
+func defaultAudioIO(config *AudioConfig) AudioIO {
+	if create, ok := audioBackends[defaultAudioBackend]; ok {
+		return create(config)
+	}
+	return unavailableAudioIO{}
+}
+
+// Method returns Kai's audio backend, the default one with the default
+// devices if none has been set up.
+func (kai *Kai) audioIO() AudioIO {
+	kai.audioMutex.Lock()
+	defer kai.audioMutex.Unlock()
+	if kai.Audio == nil {
+		kai.Audio = defaultAudioIO(nil)
+	}
+	return kai.Audio
+}
+
+// Method lists the audio devices of Kai's audio backend.
+func (kai *Kai) ListAudioDevices() ([]AudioDevice, error) {
+	return kai.audioIO().Devices()
+}
+
+// Method returns the audio configuration, with the default sample rate if
+// none is set.
+func (kai *Kai) audioConfig() AudioConfig {
+	var config AudioConfig
+	if kai.Config != nil {
+		config = kai.Config.Audio
+	}
+	if config.SampleRate <= 0 {
+		config.SampleRate = defaultSampleRate
+	}
+	return config
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Error of a program built without its default audio backend.
+var errNoAudioBackend = errors.New("built without PortAudio audio support")
+
+// unavailableAudioIO stands in for PortAudio when it was not built in, so
+// text chat keeps working and voice features report why they cannot.
+type unavailableAudioIO struct{}
+
+func (unavailableAudioIO) Record(<-chan struct{}, int, func([]int16) bool) error {
+	return errNoAudioBackend
+}
+
+func (unavailableAudioIO) Play(context.Context, []int16, int) error {
+	return errNoAudioBackend
+}
+
+func (unavailableAudioIO) Devices() ([]AudioDevice, error) {
+	return nil, errNoAudioBackend
+}
+
+func (unavailableAudioIO) Close() error {
+	return nil
+}
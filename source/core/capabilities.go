@@ -0,0 +1,117 @@
+package core
+
+import (
+	"os"
+	"fmt"
+	"runtime"
+	"path/filepath"
+)
+
+// File the application default credentials are written to by
+// "gcloud auth application-default login".
+const gcloudCredentialsName = "application_default_credentials.json"
+
+// Capability is an optional service or device Kai can work without.
+type Capability struct {
+	Available bool
+	// Why it is unavailable, for the user
+	Reason    string
+}
+
+// Capabilities records what was found at startup. Text chat works without
+// any of them; missing ones disable the microphone or speech output.
+type Capabilities struct {
+	// Google Cloud credentials for speech recognition and synthesis
+	Credentials  Capability
+	// Recording and recognizing speech
+	Microphone   Capability
+	// Playing synthesized speech; replies are written otherwise
+	SpeechOutput Capability
+}
+
+// Method reports whether Google Cloud credentials can be found: a key file
+// named by GOOGLE_APPLICATION_CREDENTIALS, which is set at startup from a
+// key file in .config, or the application default credentials written by
+// gcloud.
+//
+// Returns:
+//  - bool: True if a credentials file exists.
+//  - string: Why no credentials were found, naming the places looked in.
+func GoogleCredentials() (bool, string) {
+	if file := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); file != "" {
+		if _, err := os.Stat(file); err != nil {
+			return false, fmt.Sprintf("the Google Cloud key file %s is missing", file)
+		}
+		return true, ""
+	}
+	adc := gcloudCredentialsFile()
+	if adc == "" {
+		return false, "no Google Cloud key file was found in .config, " +
+			"and the gcloud configuration folder could not be located"
+	}
+	if _, err := os.Stat(adc); err == nil {
+		return true, ""
+	}
+	return false, fmt.Sprintf(
+		"no Google Cloud key file was found in .config or at %s", adc,
+	)
+}
+
+// Method returns where gcloud writes the application default credentials:
+// %APPDATA%\gcloud on Windows and ~/.config/gcloud elsewhere, macOS
+// included. Returns an empty string if that folder cannot be located.
+func gcloudCredentialsFile() string {
+	if runtime.GOOS == "windows" {
+		appData := os.Getenv("APPDATA")
+		if appData == "" {
+			return ""
+		}
+		return filepath.Join(appData, "gcloud", gcloudCredentialsName)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gcloud", gcloudCredentialsName)
+}
+
+// Method detects the available capabilities and turns off what cannot
+// work: without an output device replies are written instead of spoken.
+// The microphone needs an input device, and credentials unless an offline
+// recognizer is configured.
+//
+// Returns:
+//  - Capabilities: What is available, and why the rest is not.
+func (kai *Kai) DetectCapabilities() Capabilities {
+	var capabilities Capabilities
+	available, reason := GoogleCredentials()
+	capabilities.Credentials = Capability{available, reason}
+	input, output := Capability{}, Capability{}
+	devices, err := kai.audioIO().Devices()
+	if err != nil {
+		input.Reason = fmt.Sprintf("audio is unavailable: %v", err)
+		output.Reason = input.Reason
+	} else {
+		input.Reason, output.Reason = "no microphone was found", "no speakers were found"
+		for _, device := range devices {
+			input.Available = input.Available || device.Input
+			output.Available = output.Available || device.Output
+		}
+	}
+	if input.Available {
+		if _, google := kai.Recognizer.(*GoogleRecognizer); google && !available {
+			input = Capability{Reason: "speech recognition needs Google Cloud " +
+				"credentials or an offline engine, and " + reason}
+		}
+	}
+	if input.Available {
+		input.Reason = ""
+	}
+	if output.Available {
+		output.Reason = ""
+	} else {
+		kai.speechOutputOff.Store(true)
+	}
+	capabilities.Microphone, capabilities.SpeechOutput = input, output
+	return capabilities
+}
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"path/filepath"
+)
+
+func TestGoogleCredentials(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("APPDATA", home)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	adc := filepath.Join(home, ".config", "gcloud", gcloudCredentialsName)
+	if runtime.GOOS == "windows" {
+		adc = filepath.Join(home, "gcloud", gcloudCredentialsName)
+	}
+	available, reason := GoogleCredentials()
+	if available || !strings.Contains(reason, adc) {
+		t.Errorf("without credentials: got %v, %q, want the reason to name %s", available, reason, adc)
+	}
+	if err := os.MkdirAll(filepath.Dir(adc), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(adc, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if available, reason := GoogleCredentials(); !available {
+		t.Errorf("gcloud credentials in %s not found: %s", adc, reason)
+	}
+	key := filepath.Join(home, "missing.json")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", key)
+	if available, reason := GoogleCredentials(); available || !strings.Contains(reason, key) {
+		t.Errorf("with a missing key file: got %v, %q", available, reason)
+	}
+}
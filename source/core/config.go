@@ -2,12 +2,155 @@ package core
 
 import (
 	"os"
+	"fmt"
 	"encoding/json"
+	// Local imports
+	"kai/source/mcp"
 )
 
-// Config structure to hold API key.
+// Config structure to hold API key and user settings.
 type Config struct {
-	APIKey      string `json:"api_key"`
+	APIKey       string             `json:"api_key"`
+	MCPServers   []mcp.ServerConfig `json:"mcp_servers,omitempty"`
+	SpeechToText SpeechToTextConfig `json:"speech_to_text,omitempty"`
+	WakeWord     WakeWordConfig     `json:"wake_word,omitempty"`
+	VAD          VADConfig          `json:"vad,omitempty"`
+	Language     LanguageConfig     `json:"language,omitempty"`
+	TextToSpeech TextToSpeechConfig `json:"text_to_speech,omitempty"`
+	Voice        VoiceConfig        `json:"voice,omitempty"`
+	Audio        AudioConfig        `json:"audio,omitempty"`
+	Speakers     SpeakerConfig      `json:"speakers,omitempty"`
+	Approval     ApprovalConfig     `json:"approval,omitempty"`
+}
+
+// ApprovalConfig configures approving commands, file changes and plans by
+// voice when the request was spoken.
+type ApprovalConfig struct {
+	// Time to start answering, in milliseconds, before the approval is
+	// cancelled; 10 seconds if zero
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+}
+
+// SpeakerConfig configures identifying the users of a shared workstation
+// by voice.
+type SpeakerConfig struct {
+	// Match each spoken request to an enrolled user's profile
+	Enabled       bool    `json:"enabled,omitempty"`
+	// File of the enrolled profiles; "data/speakers.json" if empty
+	ProfilesFile  string  `json:"profiles_file,omitempty"`
+	// Similarity from 0 to 1 a voice needs to match a profile; 0.93 if zero
+	Threshold     float64 `json:"threshold,omitempty"`
+	// Permission policy of voices matching no profile: "full", "review"
+	// (default) or "chat"
+	UnknownPolicy string  `json:"unknown_policy,omitempty"`
+}
+
+// AudioConfig selects the audio devices and the sample rate Kai works at.
+type AudioConfig struct {
+	// Name of the microphone; the system default if empty
+	InputDevice    string   `json:"input_device,omitempty"`
+	// Name of the speakers or headset; the system default if empty
+	OutputDevice   string   `json:"output_device,omitempty"`
+	// Rate audio is recorded, recognized and synthesized at; 44100 if zero.
+	// Devices that do not support it are resampled.
+	SampleRate     int      `json:"sample_rate,omitempty"`
+	// Send recordings to the recognizer as recorded, without resampling,
+	// noise suppression and gain normalization
+	RawRecognition bool     `json:"raw_recognition,omitempty"`
+	// "portaudio" (default), or "file" to record from WAV files and write
+	// played audio to WAV files instead of using sound hardware
+	Backend        string   `json:"backend,omitempty"`
+	// WAV files the file backend records from, one per recording
+	InputFiles     []string `json:"input_files,omitempty"`
+	// Directory the file backend writes played audio to
+	OutputDir      string   `json:"output_dir,omitempty"`
+}
+
+// VoiceConfig holds the voice and speech parameters chosen in the settings.
+type VoiceConfig struct {
+	// Voice name, e.g. "en-US-Neural2-D"; the language's default if empty
+	Name         string  `json:"name,omitempty"`
+	// "male" (default), "female" or "neutral"
+	Gender       string  `json:"gender,omitempty"`
+	// Speed from 0.25 to 4; 1 if zero
+	SpeakingRate float64 `json:"speaking_rate,omitempty"`
+	// Pitch shift from -20 to 20 semitones
+	Pitch        float64 `json:"pitch,omitempty"`
+	// Volume change from -96 to 16 dB
+	VolumeGainDb float64 `json:"volume_gain_db,omitempty"`
+}
+
+// TextToSpeechConfig selects and configures the speech synthesis engine.
+type TextToSpeechConfig struct {
+	// "auto" (default), "google", "piper", "espeak" or "text"
+	Engine      string   `json:"engine,omitempty"`
+	// Path of the offline engine's binary; looked up on PATH if relative
+	Binary      string   `json:"binary,omitempty"`
+	// Path of the piper voice model
+	Model       string   `json:"model,omitempty"`
+	// Extra arguments for the offline engine
+	Args        []string `json:"args,omitempty"`
+	// Directory of the synthesized speech cache; "data/tts_cache" if empty
+	CacheDir    string   `json:"cache_dir,omitempty"`
+	// Size cap of the cache in megabytes; 64 if zero, no cache if negative
+	CacheSizeMB int      `json:"cache_size_mb,omitempty"`
+}
+
+// LanguageConfig selects the languages spoken to and by Kai.
+type LanguageConfig struct {
+	// Primary BCP-47 language code; "en-US" if empty
+	Code         string            `json:"code,omitempty"`
+	// Other languages the user may speak, e.g. ["es-US"]
+	Alternatives []string          `json:"alternatives,omitempty"`
+	// Text-to-speech voice name per language code, overriding the defaults
+	Voices       map[string]string `json:"voices,omitempty"`
+}
+
+// VADConfig configures voice activity detection on microphone input.
+type VADConfig struct {
+	// RMS amplitude above which audio counts as speech
+	Threshold      float64 `json:"threshold,omitempty"`
+	// Silence that ends an utterance, in milliseconds
+	SilenceMs      int     `json:"silence_ms,omitempty"`
+	// Longest utterance recorded, in milliseconds; 30 seconds if zero
+	MaxUtteranceMs int     `json:"max_utterance_ms,omitempty"`
+	// End push-to-talk recordings after a pause instead of on release
+	AutoStop       bool    `json:"auto_stop,omitempty"`
+}
+
+// WakeWordConfig configures hands-free listening.
+type WakeWordConfig struct {
+	// Start listening for the wake word when the home screen opens
+	Enabled      bool               `json:"enabled,omitempty"`
+	// The wake word; "kai" if empty
+	Phrase       string             `json:"phrase,omitempty"`
+	// Offline engine that listens for the wake word, e.g. whisper with a
+	// tiny model; the speech_to_text engine is used if it is offline
+	SpeechToText SpeechToTextConfig `json:"speech_to_text,omitempty"`
+	// Listen for the wake word with a cloud recognizer, uploading every
+	// short phrase the microphone hears
+	AllowCloud   bool               `json:"allow_cloud,omitempty"`
+}
+
+// SpeechToTextConfig selects and configures the speech recognition engine.
+type SpeechToTextConfig struct {
+	// "google" (default), "whisper", "vosk" or "fake"
+	Engine        string   `json:"engine,omitempty"`
+	// Path of the offline engine's binary; looked up on PATH if relative
+	Binary        string   `json:"binary,omitempty"`
+	// Path of the offline engine's model
+	Model         string   `json:"model,omitempty"`
+	// Extra arguments for the offline engine, or canned transcripts for
+	// the fake engine
+	Args          []string `json:"args,omitempty"`
+	// Words the recognizer should expect, ahead of the collected hints
+	Phrases       []string `json:"phrases,omitempty"`
+	// Do not collect phrase hints from PATH, the installed packages and
+	// the conversation history
+	NoPhraseHints bool     `json:"no_phrase_hints,omitempty"`
+	// Confidence below which the user confirms what was heard before it
+	// is sent; 0.75 if zero, negative to never ask
+	MinConfidence float64  `json:"min_confidence,omitempty"`
 }
 
 // SaveConfig writes the Config struct to the configuration file.
@@ -34,5 +177,9 @@ func LoadConfig(file string) (*Config, error) {
     if err != nil {
         return nil, err
     }
+    // Refuse unknown permission policies rather than guess what they allow
+    if err := checkPolicy(config.Speakers.UnknownPolicy); err != nil {
+        return nil, fmt.Errorf("speakers: unknown_policy: %w", err)
+    }
     return &config, nil
 }
\ No newline at end of file
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"errors"
+	"strings"
+)
+
+// Confidence below which a transcript is confirmed if the configuration
+// does not set a threshold.
+const defaultMinConfidence = 0.75
+
+// Option offered to discard a misheard transcript.
+const cancelTranscript = "Cancel"
+
+// Spoken ways to pick a candidate by its position.
+var candidateOrdinals = map[string]int{
+	"one": 1, "first": 1, "two": 2, "second": 2, "three": 3, "third": 3,
+}
+
+// Words that may surround a spoken ordinal.
+var candidateFillers = map[string]bool{
+	"the": true, "number": true, "option": true, "choice": true,
+}
+
+// Method reports whether a transcript is uncertain enough that the user
+// should confirm it before it is sent. Transcripts from engines that report
+// no confidence are trusted.
+func (kai *Kai) NeedsConfirmation(transcript Transcript) bool {
+	threshold := defaultMinConfidence
+	if kai.Config != nil && kai.Config.SpeechToText.MinConfidence != 0 {
+		threshold = kai.Config.SpeechToText.MinConfidence
+	}
+	return transcript.Confidence > 0 && float64(transcript.Confidence) < threshold
+}
+
+// Method returns the readings of the utterance to choose from: the text and
+// its alternatives, without duplicates.
+func (t Transcript) Candidates() []string {
+	var candidates []string
+	for _, text := range append([]string{t.Text}, t.Alternatives...) {
+		text = strings.TrimSpace(text)
+		duplicate := text == ""
+		for _, candidate := range candidates {
+			duplicate = duplicate || strings.EqualFold(candidate, text)
+		}
+		if !duplicate {
+			candidates = append(candidates, text)
+		}
+	}
+	return candidates
+}
+
+// Method asks the user which reading of an uncertain transcript they meant.
+// The candidates are shown, or printed, and spoken, so voice users can
+// answer by repeating one, by its number, or by saying something else
+// entirely; the UI also lets the user edit the transcript. Nothing reaches
+// Reason until the user has answered.
+//
+// Parameters:
+//  - transcript: The uncertain transcript.
+//
+// Returns:
+//  - string: The text to send, or an empty string if the user cancelled.
+//  - error: Error encountered while waiting for the answer, if any.
+func (kai *Kai) ConfirmTranscript(transcript Transcript) (string, error) {
+	candidates := transcript.Candidates()
+	if len(candidates) == 0 {
+		return "", nil
+	}
+	question := Question{
+		Text:    "I'm not sure I heard you right. Did you mean:",
+		Options: append(candidates, cancelTranscript),
+	}
+	spoken := fmt.Sprintf("%q", candidates[0])
+	if len(candidates) > 1 {
+		quoted := make([]string, len(candidates))
+		for i, candidate := range candidates {
+			quoted[i] = fmt.Sprintf("%d, %q", i+1, candidate)
+		}
+		spoken = strings.Join(quoted, "; or ")
+	}
+	if err := kai.Speak(question.Text + " " + spoken + "?"); err != nil {
+		log.Printf("Failed to speak: %v", err)
+	}
+	answer, err := kai.askUser(question)
+	if errors.Is(err, ErrAskCancelled) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return resolveCandidate(candidates, answer), nil
+}
+
+// Method maps an answer to an uncertain transcript to the text to send: a
+// candidate picked by its number, a spoken ordinal such as "the second
+// one", nothing if the user cancelled, and any other answer as is.
+//
+// Parameters:
+//  - candidates: The candidates offered.
+//  - answer: The answer, with option numbers already resolved.
+//
+// Returns:
+//  - string: The text to send; empty if the transcript was rejected.
+func resolveCandidate(candidates []string, answer string) string {
+	answer = strings.TrimSpace(answer)
+	words := wordRegexp.FindAllString(strings.ToLower(answer), -1)
+	switch strings.Join(words, " ") {
+	case "cancel", "no", "never mind", "nevermind", "none", "none of them":
+		return ""
+	}
+	// "two", "the second", "number two", "the first one"
+	index := 0
+	for _, word := range words {
+		if ordinal, ok := candidateOrdinals[word]; ok {
+			if word != "one" || index == 0 {
+				index = ordinal
+			}
+		} else if !candidateFillers[word] {
+			// Something else was said, e.g. "open two tabs"
+			return answer
+		}
+	}
+	if index > 0 && index <= len(candidates) {
+		return candidates[index-1]
+	}
+	return answer
+}
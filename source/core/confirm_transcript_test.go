@@ -0,0 +1,68 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResolveCandidate(t *testing.T) {
+	candidates := []string{"open the logs", "open the locks", "open two logs"}
+	tests := []struct {
+		answer string
+		want   string
+	}{
+		{"two", "open the locks"},
+		{"The second one.", "open the locks"},
+		{"number three", "open two logs"},
+		{"option one", "open the logs"},
+		{"the first one", "open the logs"},
+		{"one", "open the logs"},
+		{"  open the locks ", "open the locks"},
+		{"the fourth", "the fourth"},
+		{"open two tabs", "open two tabs"},
+		{"Never mind", ""},
+		{"none of them", ""},
+		{"cancel", ""},
+	}
+	for _, test := range tests {
+		if got := resolveCandidate(candidates, test.answer); got != test.want {
+			t.Errorf("resolveCandidate(%q) = %q, want %q", test.answer, got, test.want)
+		}
+	}
+}
+
+func TestTranscriptCandidates(t *testing.T) {
+	transcript := Transcript{
+		Text:         "open the logs",
+		Alternatives: []string{"Open the logs", " open the locks ", "", "open the locks"},
+	}
+	want := []string{"open the logs", "open the locks"}
+	if got := transcript.Candidates(); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestNeedsConfirmation(t *testing.T) {
+	tests := []struct {
+		name       string
+		threshold  float64
+		confidence float32
+		want       bool
+	}{
+		{"no confidence reported", 0, 0, false},
+		{"confident", 0, 0.9, false},
+		{"uncertain", 0, 0.5, true},
+		{"at the threshold", 0.5, 0.5, false},
+		{"configured threshold", 0.95, 0.9, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kai := &Kai{Config: &Config{}}
+			kai.Config.SpeechToText.MinConfidence = test.threshold
+			transcript := Transcript{Text: "hi", Confidence: test.confidence}
+			if got := kai.NeedsConfirmation(transcript); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
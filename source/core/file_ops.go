@@ -0,0 +1,433 @@
+package core
+
+import (
+	"os"
+	"fmt"
+	"log"
+	"time"
+	"strings"
+	"encoding/json"
+	"path/filepath"
+	// Local utilities
+	"kai/source/utils"
+)
+
+// Largest file content fed back into the AI from a "file_read" item.
+const maxFileReadBytes = 64 * 1024
+
+// FileChange describes a modification made to a file by a response item, so
+// the UI can present it as a previewable diff.
+type FileChange struct {
+	Path      string
+	Operation string
+	Diff      string
+	Backup    string
+}
+
+// Registers the file operation response types.
+func init() {
+	pathField := HandlerField{
+		Name:        "path",
+		Type:        "string",
+		Description: "The path of the file.",
+		Required:    true,
+	}
+	RegisterHandler(ResponseHandler{
+		Type:        "file_read",
+		Description: "Reads a file. Its content will be sent back to you. " +
+			"To work with files, do not use shell redirection, heredocs " +
+			"or 'sed -i'; use the file types instead.",
+		Fields:      []HandlerField{pathField},
+		Execute:     processFileRead,
+	})
+	RegisterHandler(ResponseHandler{
+		Type:        "file_write",
+		Description: "Replaces the content of a file, creating it if needed.",
+		Fields: []HandlerField{
+			pathField,
+			{
+				Name:        "content",
+				Type:        "string",
+				Description: "The complete new file content.",
+				Required:    true,
+			},
+		},
+		Execute: processFileWrite,
+	})
+	RegisterHandler(ResponseHandler{
+		Type:        "file_append",
+		Description: "Appends text to a file, creating it if needed.",
+		Fields: []HandlerField{
+			pathField,
+			{
+				Name:        "content",
+				Type:        "string",
+				Description: "The text to append.",
+				Required:    true,
+			},
+		},
+		Execute: processFileAppend,
+	})
+	RegisterHandler(ResponseHandler{
+		Type:        "file_patch",
+		Description: "Applies a unified diff to a file. If the patch does " +
+			"not apply you will receive a conflict report.",
+		Fields: []HandlerField{
+			pathField,
+			{
+				Name:        "diff",
+				Type:        "string",
+				Description: "A unified diff against the current file content.",
+				Required:    true,
+			},
+		},
+		Validate: validateFilePatch,
+		Execute:  processFilePatch,
+	})
+}
+
+// Method handles the processing of a "file_read" response item by feeding
+// the file content back into the AI.
+//
+// Parameters:
+//  - kai: The AI system handling the request.
+//  - data: The JSON-encoded data holding the path of the file to read.
+//  - branchCount: The current branch count to manage recursion.
+//
+// Returns:
+//  - bool: True if the result was fed back into the AI.
+func processFileRead(kai *Kai, data json.RawMessage, branchCount int) bool {
+	var fileData struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(data, &fileData); err != nil {
+		log.Printf("Failed to parse file_read data: %v", err)
+		return false
+	}
+	path := expandPath(fileData.Path)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		kai.handleAIResponse(fmt.Sprintf(
+			"Reading %s failed: %v. " +
+			"Please analyze the error and generate a new solution.",
+			fileData.Path, err,
+		), branchCount)
+		return true
+	}
+	truncated := ""
+	if len(content) > maxFileReadBytes {
+		content = content[:maxFileReadBytes]
+		truncated = fmt.Sprintf(
+			" (truncated to the first %d bytes)", maxFileReadBytes,
+		)
+	}
+	kai.handleAIResponse(fmt.Sprintf(
+		"Contents of %s%s:\n%s\n" +
+		"Please analyze the file and provide a suitable response.",
+		fileData.Path, truncated, content,
+	), branchCount)
+	return true
+}
+
+// Method handles the processing of a "file_write" response item by
+// atomically replacing the file content.
+//
+// Parameters:
+//  - kai: The AI system handling the request.
+//  - data: The JSON-encoded data holding the path and the new content.
+//  - branchCount: The current branch count to manage recursion.
+//
+// Returns:
+//  - bool: True if the result was fed back into the AI.
+func processFileWrite(kai *Kai, data json.RawMessage, branchCount int) bool {
+	var fileData struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(data, &fileData); err != nil {
+		log.Printf("Failed to parse file_write data: %v", err)
+		return false
+	}
+	return kai.modifyFile(
+		"write", fileData.Path, branchCount,
+		func(string) (string, error) {
+			return fileData.Content, nil
+		},
+	)
+}
+
+// Method handles the processing of a "file_append" response item by
+// atomically appending content to the file, creating it if needed.
+//
+// Parameters:
+//  - kai: The AI system handling the request.
+//  - data: The JSON-encoded data holding the path and the content to append.
+//  - branchCount: The current branch count to manage recursion.
+//
+// Returns:
+//  - bool: True if the result was fed back into the AI.
+func processFileAppend(kai *Kai, data json.RawMessage, branchCount int) bool {
+	var fileData struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(data, &fileData); err != nil {
+		log.Printf("Failed to parse file_append data: %v", err)
+		return false
+	}
+	return kai.modifyFile(
+		"append", fileData.Path, branchCount,
+		func(current string) (string, error) {
+			return current + fileData.Content, nil
+		},
+	)
+}
+
+// Method handles the processing of a "file_patch" response item by applying
+// a unified diff to the file. Conflicting hunks are reported back to the AI
+// and leave the file untouched.
+//
+// Parameters:
+//  - kai: The AI system handling the request.
+//  - data: The JSON-encoded data holding the path and the unified diff.
+//  - branchCount: The current branch count to manage recursion.
+//
+// Returns:
+//  - bool: True if the result was fed back into the AI.
+func processFilePatch(kai *Kai, data json.RawMessage, branchCount int) bool {
+	var fileData struct {
+		Path string `json:"path"`
+		Diff string `json:"diff"`
+	}
+	if err := json.Unmarshal(data, &fileData); err != nil {
+		log.Printf("Failed to parse file_patch data: %v", err)
+		return false
+	}
+	return kai.modifyFile(
+		"patch", fileData.Path, branchCount,
+		func(current string) (string, error) {
+			return utils.ApplyPatch(current, fileData.Diff)
+		},
+	)
+}
+
+// Method checks that the diff of a "file_patch" item parses and contains at
+// least one hunk.
+//
+// Parameters:
+//  - data: The JSON-encoded data of the item.
+//
+// Returns:
+//  - error: A description of the problem, if any.
+func validateFilePatch(data json.RawMessage) error {
+	var fileData struct {
+		Diff string `json:"diff"`
+	}
+	if err := json.Unmarshal(data, &fileData); err != nil {
+		return err
+	}
+	hunks, err := utils.ParsePatch(fileData.Diff)
+	if err != nil {
+		return err
+	}
+	if len(hunks) == 0 {
+		return fmt.Errorf("the diff contains no hunks")
+	}
+	return nil
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Method reads a file, transforms its content and writes the result back
+// atomically, keeping a backup of the previous version. The resulting diff is
+// sent to the UI, and a short confirmation or the failure is fed back into
+// the AI.
+//
+// Parameters:
+//  - operation: The name of the operation, used in messages.
+//  - path: The path of the file to modify.
+//  - branchCount: The current branch count to manage recursion.
+//  - transform: Function producing the new content from the current one.
+//
+// Returns:
+//  - bool: True if the result was fed back into the AI.
+func (kai *Kai) modifyFile(
+	operation, path string,
+	branchCount int,
+	transform func(current string) (string, error),
+) bool {
+	target := expandPath(path)
+	current, err := os.ReadFile(target)
+	if err != nil && !os.IsNotExist(err) {
+		kai.reportFileError(operation, path, err, branchCount)
+		return true
+	}
+	updated, err := transform(string(current))
+	if err != nil {
+		kai.reportFileError(operation, path, err, branchCount)
+		return true
+	}
+	backup, err := kai.writeFileAtomic(target, []byte(updated))
+	if err != nil {
+		kai.reportFileError(operation, path, err, branchCount)
+		return true
+	}
+	kai.notifyFileChange(FileChange{
+		Path:      path,
+		Operation: operation,
+		Diff:      utils.UnifiedDiff(path, string(current), updated),
+		Backup:    backup,
+	})
+	kai.handleAIResponse(fmt.Sprintf(
+		"File %s of %s succeeded; it is now %d bytes long. " +
+		"Continue with the next step, or tell the user it is done.",
+		operation, path, len(updated),
+	), branchCount)
+	return true
+}
+
+// Method writes data to a temporary file next to the target and renames it
+// into place, so readers never observe a partially written file. An existing
+// file is copied to the backup directory first. Symbolic links are followed,
+// so the file they point to is replaced rather than the link itself.
+//
+// Parameters:
+//  - path: The path of the file to write.
+//  - data: The new file content.
+//
+// Returns:
+//  - string: The path of the backup copy, or empty if the file was new.
+//  - error: Error encountered while writing, if any.
+func (kai *Kai) writeFileAtomic(path string, data []byte) (string, error) {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	} else if link, linkErr := os.Readlink(path); linkErr == nil {
+		// A dangling link: create the file it points to
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(path), link)
+		}
+		path = link
+	}
+	mode := os.FileMode(0o644)
+	backup := ""
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+		if backup, err = kai.backupFile(path); err != nil {
+			return "", fmt.Errorf("failed to back up file: %w", err)
+		}
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".kai-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	// Remove the temporary file unless it was renamed into place
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return "", fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to replace file: %w", err)
+	}
+	return backup, nil
+}
+
+// Method copies a file into the backup directory next to the history file,
+// naming the copy after its absolute path and the current time.
+//
+// Parameters:
+//  - path: The path of the file to back up.
+//
+// Returns:
+//  - string: The path of the backup copy.
+//  - error: Error encountered while copying, if any.
+func (kai *Kai) backupFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	backupDir := filepath.Join(filepath.Dir(kai.HistoryFile), "backups")
+	if err := os.MkdirAll(backupDir, 0o755); err != nil {
+		return "", err
+	}
+	name := strings.ReplaceAll(
+		strings.TrimPrefix(absolute, string(filepath.Separator)),
+		string(filepath.Separator), "_",
+	)
+	backup := filepath.Join(
+		backupDir, name+"."+time.Now().Format("20060102-150405.000"),
+	)
+	return backup, os.WriteFile(backup, data, 0o600)
+}
+
+// Method sends a file change to the UI, or prints the diff when no UI is
+// attached.
+//
+// Parameters:
+//  - change: The file change to report.
+func (kai *Kai) notifyFileChange(change FileChange) {
+	if kai.OnFileChange != nil {
+		kai.OnFileChange(change)
+		return
+	}
+	fmt.Printf("%s %s\n%s", change.Operation, change.Path, change.Diff)
+}
+
+// Method feeds a failed file operation back into the AI. Patch conflicts
+// include a report of the mismatching hunks.
+//
+// Parameters:
+//  - operation: The name of the failed operation.
+//  - path: The path of the file.
+//  - err: The error returned by the operation.
+//  - branchCount: The current branch count to manage recursion.
+func (kai *Kai) reportFileError(
+	operation, path string,
+	err error,
+	branchCount int,
+) {
+	message := fmt.Sprintf("File %s of %s failed: %v.", operation, path, err)
+	if conflict, ok := err.(*utils.PatchConflictError); ok {
+		message += " Conflict report:\n" + conflict.Report() + "\n" +
+			"Read the file again and send a patch against its current content."
+	} else {
+		message += " Please analyze the error and generate a new solution."
+	}
+	kai.handleAIResponse(message, branchCount)
+}
+
+// Method expands a leading tilde to the user's home directory.
+//
+// Parameters:
+//  - path: The path to expand.
+//
+// Returns:
+//  - string: The expanded path.
+func expandPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"os"
+	"testing"
+	"path/filepath"
+)
+
+func TestWriteFileAtomicFollowsSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	kai := &Kai{HistoryFile: filepath.Join(dir, "data", "history.json")}
+	target := filepath.Join(dir, "target.txt")
+	if err := os.WriteFile(target, []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Symlink("target.txt", link); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	backup, err := kai.writeFileAtomic(link, []byte("new\n"))
+	if err != nil {
+		t.Fatalf("writeFileAtomic: %v", err)
+	}
+	if backup == "" {
+		t.Error("expected a backup of the existing file")
+	}
+	info, err := os.Lstat(link)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("the link was replaced by a regular file")
+	}
+	content, _ := os.ReadFile(target)
+	if string(content) != "new\n" {
+		t.Errorf("target content is %q, want %q", content, "new\n")
+	}
+	if info, _ := os.Stat(target); info.Mode().Perm() != 0o600 {
+		t.Errorf("target mode is %v, want 0600", info.Mode().Perm())
+	}
+}
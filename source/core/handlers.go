@@ -0,0 +1,162 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"encoding/json"
+)
+
+// HandlerField describes one field of a response item's "data" object.
+type HandlerField struct {
+	Name        string
+	Type        string // JSON type: "string", "number", "boolean", "array" or "object"
+	Description string
+	Required    bool
+}
+
+// ResponseHandler ties a ResponseItem type to its data schema, validation
+// and execution. The description and fields are used to generate the
+// protocol section of the primer.
+type ResponseHandler struct {
+	Type        string
+	Description string
+	Fields      []HandlerField
+	// Optional validation on top of the field schema
+	Validate func(data json.RawMessage) error
+	// Executes the item; returns true if the rest of the response should be
+	// skipped because a new response has been generated
+	Execute func(kai *Kai, data json.RawMessage, branchCount int) bool
+}
+
+// Registered handlers by type, and their registration order.
+var (
+	responseHandlers = map[string]*ResponseHandler{}
+	handlerOrder     []string
+)
+
+// Method registers a handler for a ResponseItem type. It is meant to be
+// called from init functions and panics on duplicate or incomplete handlers.
+//
+// Parameters:
+//  - handler: The handler to register.
+func RegisterHandler(handler ResponseHandler) {
+	if handler.Type == "" || handler.Execute == nil {
+		panic("response handler requires a type and an executor")
+	}
+	if _, exists := responseHandlers[handler.Type]; exists {
+		panic(fmt.Sprintf("response handler %q already registered", handler.Type))
+	}
+	responseHandlers[handler.Type] = &handler
+	handlerOrder = append(handlerOrder, handler.Type)
+}
+
+// Method returns the handler registered for a ResponseItem type.
+//
+// Parameters:
+//  - itemType: The ResponseItem type.
+//
+// Returns:
+//  - *ResponseHandler: The handler, or nil if none is registered.
+//  - bool: True if a handler was found.
+func LookupHandler(itemType string) (*ResponseHandler, bool) {
+	handler, exists := responseHandlers[itemType]
+	return handler, exists
+}
+
+// Method returns the registered ResponseItem types in registration order.
+func HandlerTypes() []string {
+	return append([]string(nil), handlerOrder...)
+}
+
+// Method generates the response protocol section of the primer from the
+// registered handlers.
+//
+// Returns:
+//  - string: The protocol description.
+func ProtocolDescription() string {
+	var protocol strings.Builder
+	protocol.WriteString("Response protocol. ")
+	protocol.WriteString("The 'type' field must be one of the following:\n")
+	for _, itemType := range handlerOrder {
+		handler := responseHandlers[itemType]
+		fmt.Fprintf(&protocol, "- '%s': %s\n", handler.Type, handler.Description)
+		for _, field := range handler.Fields {
+			requirement := "optional"
+			if field.Required {
+				requirement = "required"
+			}
+			fmt.Fprintf(
+				&protocol, "    - '%s' (%s, %s): %s\n",
+				field.Name, field.Type, requirement, field.Description,
+			)
+		}
+	}
+	return strings.TrimRight(protocol.String(), "\n")
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Method checks a response item's data against the handler's field schema
+// and its custom validator.
+//
+// Parameters:
+//  - data: The JSON-encoded data of the item.
+//
+// Returns:
+//  - error: A description of the first problem found, if any.
+func (handler *ResponseHandler) validate(data json.RawMessage) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("'data' must be a JSON object")
+	}
+	for _, field := range handler.Fields {
+		value, present := fields[field.Name]
+		if !present || string(value) == "null" {
+			if field.Required {
+				return fmt.Errorf("missing required field '%s'", field.Name)
+			}
+			continue
+		}
+		if field.Type != "" && jsonType(value) != field.Type {
+			return fmt.Errorf(
+				"field '%s' must be of type %s", field.Name, field.Type,
+			)
+		}
+	}
+	if handler.Validate != nil {
+		return handler.Validate(data)
+	}
+	return nil
+}
+
+// Method reports the JSON type of an encoded value.
+func jsonType(value json.RawMessage) string {
+	trimmed := strings.TrimSpace(string(value))
+	if trimmed == "" {
+		return ""
+	}
+	switch trimmed[0] {
+	case '"':
+		return "string"
+	case '[':
+		return "array"
+	case '{':
+		return "object"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return "null"
+	default:
+		return "number"
+	}
+}
+
+// Method returns the registered types as a quoted, sorted list for messages.
+func supportedTypesList() string {
+	types := HandlerTypes()
+	sort.Strings(types)
+	return "'" + strings.Join(types, "', '") + "'"
+}
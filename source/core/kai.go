@@ -2,26 +2,96 @@ package core
 
 import (
 	"fmt"
+	"log"
+	"sync"
 	"context"
+	"sync/atomic"
 	// Google Cloud
 	"google.golang.org/api/option"
 	// Gemini API
 	"github.com/google/generative-ai-go/genai"
+	// Local imports
+	"kai/source/mcp"
 )
 
 type Kai struct {
 	ApiKey      string
 	HistoryFile string
+	Config      *Config
 	Client      *genai.Client
 	Model       *genai.GenerativeModel
 	Chat        *genai.ChatSession
 	Context     context.Context
 	SampleRate  int
+	Recognizer  Recognizer
+	Synthesizer Synthesizer
+	// Records and plays audio; PortAudio with the default devices if nil
+	Audio       AudioIO
+	audioMutex  sync.Mutex
+	// Detects the wake word in hands-free mode; transcript based if nil
+	KeywordSpotter KeywordSpotter
+	// Called with every file modified by a response item
+	OnFileChange func(FileChange)
+	// Called to ask the user a question and wait for the answer; gives up
+	// when the context is cancelled
+	OnAsk func(context.Context, Question) (string, error)
+	// Called to review a plan; returns the approved plan or false, which
+	// it also returns when the context is cancelled
+	OnPlanReview func(context.Context, Plan) (Plan, bool)
+	// Called as each step of an approved plan runs
+	OnPlanProgress func(PlanProgress)
+	// Called with problems the user can fix in recorded audio, e.g. clipping
+	OnAudioWarning func(string)
+	// Called with the prompt while listening for a spoken approval, and
+	// with an empty string when done
+	OnVoiceApproval func(string)
+	// Called with each reply written as text instead of spoken
+	OnSpeechText func(string)
+	// What was found at startup; missing capabilities are turned off
+	Capabilities Capabilities
+	// Connected Model Context Protocol servers by name
+	MCPServers map[string]*mcp.Client
+	// Set while speech is playing
+	speaking atomic.Bool
+	// Set if the user's latest request was spoken
+	voiceInput atomic.Bool
+	// Set once playback failed; speech is written as text from then on
+	speechOutputOff atomic.Bool
+	// Serializes turns; guarded by turnMutex are the current turn and its
+	// cancellation
+	turnLock     sync.Mutex
+	turnMutex    sync.Mutex
+	turnContext  context.Context
+	turnCancel   context.CancelFunc
+	// Speech waiting to be synthesized and played
+	speech       speechQueue
+	// Language of the user's latest speech, and the language the model was
+	// last told about
+	languageMutex  sync.Mutex
+	language       string
+	reasonLanguage string
+	// Enrolled users, the latest one identified by voice and the one whose
+	// profile is in use; nil if unknown. Each is verified if it was matched
+	// to the voice of the latest request rather than kept from an earlier one
+	speakerMutex    sync.Mutex
+	speakers        []SpeakerProfile
+	speaker         *SpeakerProfile
+	speakerVerified bool
+	activeSpeaker   *SpeakerProfile
+	activeVerified  bool
+	// History of unrecognized users, and the primer of new conversations
+	sharedHistoryFile string
+	primer            string
+	// Serializes saving and switching the conversation history
+	historyMutex      sync.Mutex
 }
 
 // Method initializes and validates a new Kai instance with the 
-// provided API key.
-func InitializeKai(apiKey, historyFile string) (*Kai, error) {
+// provided API key and connects the services listed in the configuration.
+func InitializeKai(
+	apiKey, historyFile string,
+	config *Config,
+) (*Kai, error) {
 	// Initialize the Gemini API client with the API key
 	ctx := context.Background()
 	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
@@ -52,12 +122,14 @@ func InitializeKai(apiKey, historyFile string) (*Kai, error) {
 	kai := &Kai{
 		ApiKey:      apiKey,
 		HistoryFile: historyFile,
+		Config:      config,
 		Client:      client,
 		Model:       model,
 		Chat:        model.StartChat(),
 		Context:     ctx,
-		SampleRate:  44100, // CD quality
 	}
+	// Process audio at the configured rate, CD quality by default
+	kai.SampleRate = kai.audioConfig().SampleRate
 	// Validate the API key by making a lightweight request
 	iter := kai.Client.ListModels(kai.Context)
 	if iter == nil {
@@ -68,5 +140,61 @@ func InitializeKai(apiKey, historyFile string) (*Kai, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid API key")
 	}
+	// Select the speech-to-text engine
+	kai.Recognizer, err = NewRecognizer(config.SpeechToText, config.Language)
+	if err != nil {
+		log.Printf("Falling back to Google speech recognition: %v", err)
+		kai.Recognizer = NewGoogleRecognizer(config.Language)
+	}
+	// Teach the recognizer the names of commands and packages
+	kai.startPhraseHints()
+	// Recognize the users sharing this workstation by voice
+	kai.sharedHistoryFile = historyFile
+	kai.loadSpeakers()
+	// Select the text-to-speech engine
+	kai.Synthesizer, err = NewSynthesizer(config.TextToSpeech)
+	if err != nil {
+		log.Printf("Falling back to automatic speech synthesis: %v", err)
+		kai.Synthesizer, _ = NewSynthesizer(TextToSpeechConfig{})
+	}
+	// Select the audio backend
+	kai.Audio, err = NewAudioIO(&config.Audio)
+	if err != nil {
+		log.Printf("Falling back to the default audio devices: %v", err)
+		kai.Audio = defaultAudioIO(&config.Audio)
+	}
+	// Keep synthesized speech on disk for phrases spoken again
+	kai.Synthesizer, err = NewCachingSynthesizer(kai.Synthesizer, config.TextToSpeech)
+	if err != nil {
+		log.Printf("Speech cache disabled: %v", err)
+	}
+	// Connect external tools
+	kai.connectMCPServers(config.MCPServers)
+	kai.instructModel(config.MCPServers)
+	// Find out what works on this machine, so text chat works without audio
+	// hardware or cloud credentials
+	kai.Capabilities = kai.DetectCapabilities()
+	for name, capability := range map[string]Capability{
+		"Microphone":    kai.Capabilities.Microphone,
+		"Speech output": kai.Capabilities.SpeechOutput,
+	} {
+		if !capability.Available {
+			log.Printf("%s disabled: %s", name, capability.Reason)
+		}
+	}
 	return kai, nil
+}
+
+// Method reports whether Kai is currently speaking.
+func (kai *Kai) IsSpeaking() bool {
+	return kai.speaking.Load()
+}
+
+// Method releases the resources held by Kai.
+func (kai *Kai) Close() {
+	kai.closeMCPServers()
+	kai.Recognizer.Close()
+	kai.Synthesizer.Close()
+	kai.audioIO().Close()
+	kai.Client.Close()
 }
\ No newline at end of file
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Language used when none is configured.
+const defaultLanguage = "en-US"
+
+// Display names of the base languages, used in notes to the model.
+var languageNames = map[string]string{
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"de": "German",
+	"it": "Italian",
+	"pt": "Portuguese",
+}
+
+// Method returns the primary language code of the configuration.
+func (config LanguageConfig) Primary() string {
+	if config.Code == "" {
+		return defaultLanguage
+	}
+	return normalizeLanguage(config.Code)
+}
+
+// Method returns the primary language followed by the alternatives.
+func (config LanguageConfig) All() []string {
+	languages := []string{config.Primary()}
+	for _, code := range config.Alternatives {
+		languages = append(languages, normalizeLanguage(code))
+	}
+	return languages
+}
+
+// Method matches a detected language, which may be a bare base language
+// such as "es", to the configured language code with the same base. The
+// detected code is returned as is if no configured language matches.
+func (config LanguageConfig) Resolve(detected string) string {
+	detected = normalizeLanguage(detected)
+	if detected == "" {
+		return ""
+	}
+	languages := config.All()
+	for _, code := range languages {
+		if code == detected {
+			return code
+		}
+	}
+	for _, code := range languages {
+		if languageBase(code) == languageBase(detected) {
+			return code
+		}
+	}
+	return detected
+}
+
+// Method returns the language the user last spoke in, or the primary
+// language if none was detected yet.
+func (kai *Kai) Language() string {
+	kai.languageMutex.Lock()
+	defer kai.languageMutex.Unlock()
+	if kai.language == "" {
+		return kai.languageConfig().Primary()
+	}
+	return kai.language
+}
+
+// Method records the language detected in the user's speech.
+func (kai *Kai) setLanguage(detected string) {
+	language := kai.languageConfig().Resolve(detected)
+	if language == "" {
+		return
+	}
+	kai.languageMutex.Lock()
+	defer kai.languageMutex.Unlock()
+	kai.language = language
+}
+
+// Method returns a note asking the model to reply in the user's language
+// when it changed since the last message, or an empty string.
+func (kai *Kai) languageNote() string {
+	language := kai.Language()
+	kai.languageMutex.Lock()
+	defer kai.languageMutex.Unlock()
+	previous := kai.reasonLanguage
+	if previous == "" {
+		previous = kai.languageConfig().Primary()
+	}
+	kai.reasonLanguage = language
+	if language == previous {
+		return ""
+	}
+	return fmt.Sprintf(
+		"(The user is now speaking %s. Reply in that language.)",
+		languageName(language),
+	)
+}
+
+// Method returns the language configuration, which is empty without a
+// configuration file.
+func (kai *Kai) languageConfig() LanguageConfig {
+	if kai.Config == nil {
+		return LanguageConfig{}
+	}
+	return kai.Config.Language
+}
+
+// Method returns a readable name of a language code, e.g.
+// "Spanish (es-US)".
+func languageName(code string) string {
+	if name, ok := languageNames[languageBase(code)]; ok {
+		return fmt.Sprintf("%s (%s)", name, code)
+	}
+	return code
+}
+
+// Method returns the base language of a code, e.g. "es" for "es-US".
+func languageBase(code string) string {
+	base, _, _ := strings.Cut(code, "-")
+	return strings.ToLower(base)
+}
+
+// Method normalizes the casing of a language code, e.g. "es-us" to
+// "es-US" as reported by the recognizers.
+func normalizeLanguage(code string) string {
+	code = strings.ReplaceAll(strings.TrimSpace(code), "_", "-")
+	base, rest, found := strings.Cut(code, "-")
+	if !found {
+		return strings.ToLower(base)
+	}
+	// Only two letter regions are upper case, e.g. not "cmn-Hans-CN"
+	if len(rest) == 2 {
+		rest = strings.ToUpper(rest)
+	}
+	return strings.ToLower(base) + "-" + rest
+}
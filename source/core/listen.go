@@ -1,49 +1,60 @@
 package core
 
-import (
-	"fmt"
-	"github.com/gordonklaus/portaudio"
-)
-
-// Method captures audio input from the microphone using the portaudio library.
+// Method captures audio input from the microphone.
 func (kai *Kai) Listen(stop <-chan struct{}) ([]byte, error) {
-    // Initialize the PortAudio library
-    err := portaudio.Initialize()
-    if err != nil {
-        return nil, fmt.Errorf("failed to initialize PortAudio: %v", err)
+    return kai.ListenStream(stop, nil)
+}
+
+// Method captures audio input like Listen, additionally sending each buffer 
+// of PCM bytes to the chunks channel as it is recorded, so it can be 
+// transcribed while the user is still speaking. The channel is closed when 
+// recording stops.
+//
+// Silence before and after speech is trimmed by voice activity detection. 
+// Recording stops on its own at the maximum utterance length, and after a 
+// pause if auto stop is enabled in the VAD configuration.
+func (kai *Kai) ListenStream(
+    stop <-chan struct{}, 
+    chunks chan<- []byte,
+) ([]byte, error) {
+    if chunks != nil {
+        defer close(chunks)
     }
-    defer portaudio.Terminate()
-    // Create an input buffer to store audio samples
-    in := make([]int16, 64)
-    // Open a default stream for audio input
-    stream, err := portaudio.OpenDefaultStream(1, 0, 44100, len(in), in)
-    if err != nil {
-        return nil, fmt.Errorf("failed to open default stream: %v", err)
+    var config VADConfig
+    if kai.Config != nil {
+        config = kai.Config.VAD
     }
-    defer stream.Close()
-    // Start the audio stream
-    err = stream.Start()
-    if err != nil {
-        return nil, fmt.Errorf("failed to start the stream: %v", err)
-    }
-    defer stream.Stop()
+    vad := NewVoiceActivityDetector(kai.SampleRate, config)
     // Record audio data
     var audioData []int16
-    for {
-        select {
-        case <-stop:
-            // Stop recording when a signal is received on the stop channel
-            return convertToBytes(audioData), nil
-        default:
-            // Read audio samples into the input buffer
-            err := stream.Read()
-            if err != nil {
-                return nil, fmt.Errorf("failed to read from stream: %v", err)
-            }
-            // Append the samples to the audio data slice
-            audioData = append(audioData, in...)
+    err := kai.capture(stop, func(samples []int16) bool {
+        voiced, ended := vad.Process(samples)
+        // Append the speech to the audio data slice
+        audioData = append(audioData, voiced...)
+        if chunks != nil && len(voiced) > 0 {
+            chunks <- convertToBytes(voiced)
+        }
+        // Pauses only end the recording with auto stop; without it the 
+        // detector drops the silence and waits for more speech
+        if ended && (config.AutoStop || vad.Truncated()) {
+            return false
         }
+        return len(audioData) < vad.MaxSamples()
+    })
+    if err != nil {
+        return nil, err
     }
+    return convertToBytes(audioData), nil
+}
+
+// Method reads the microphone until the stop channel closes or onSamples 
+// returns false, passing each buffer of samples at Kai's sample rate to 
+// onSamples. The buffer is reused between calls.
+func (kai *Kai) capture(
+    stop <-chan struct{}, 
+    onSamples func(samples []int16) bool,
+) error {
+    return kai.audioIO().Record(stop, kai.SampleRate, onSamples)
 }
 
 // Method converts audio data from int16 to a byte slice.
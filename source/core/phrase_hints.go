@@ -0,0 +1,339 @@
+package core
+
+import (
+	"os"
+	"log"
+	"sort"
+	"time"
+	"regexp"
+	"context"
+	"os/exec"
+	"strings"
+	"encoding/json"
+	"path/filepath"
+)
+
+// Most phrases sent to the recognizer; Google accepts up to 5000, but a
+// short list of likely words adapts recognition better.
+const maxPhraseHints = 500
+
+// Weight of the phrase hints in Google's speech adaptation, from 0 to 20;
+// higher values risk hearing commands where the user said other words.
+const phraseHintBoost = 5
+
+// Interval between refreshes of the phrase hints.
+const phraseHintsRefresh = 30 * time.Minute
+
+// Time allowed for a package manager to list the installed packages.
+const packageListTimeout = 10 * time.Second
+
+// Command names worth hinting, e.g. "kubectl" or "apt-get"; longer or
+// stranger file names are rarely spoken.
+var commandNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_+-]{1,23}$`)
+
+// Single words quoted as code in the conversation history.
+var historyCodeRegexp = regexp.MustCompile("`([^`\\s]{2,24})`")
+
+// Separates the commands of a shell command line: pipes, lists and command
+// substitutions.
+var commandSeparatorRegexp = regexp.MustCompile("[|&;()`\\n]+")
+
+// Words that run the command following them.
+var commandPrefixes = map[string]bool{
+	"sudo": true, "doas": true, "env": true, "nohup": true, "time": true, "exec": true,
+}
+
+// Commands listing installed package names, one per line, by package
+// manager.
+var packageListCommands = [][]string{
+	{"dpkg-query", "-W", "-f=${Package}\n"},
+	{"rpm", "-qa", "--qf", "%{NAME}\n"},
+	{"pacman", "-Qq"},
+	{"apk", "info"},
+	{"brew", "list", "-1"},
+}
+
+// AdaptableRecognizer is implemented by recognizers that can be biased
+// towards phrases the user is likely to say.
+type AdaptableRecognizer interface {
+	SetPhraseHints(phrases []string)
+}
+
+// Method keeps the recognizer's phrase hints up to date: they are built
+// now and rebuilt periodically until Kai's context is done, so newly
+// installed tools are recognized. If collecting hints is disabled, only the
+// configured phrases are used.
+func (kai *Kai) startPhraseHints() {
+	recognizer, ok := kai.Recognizer.(AdaptableRecognizer)
+	if !ok || kai.Config == nil {
+		return
+	}
+	if kai.Config.SpeechToText.NoPhraseHints {
+		recognizer.SetPhraseHints(kai.Config.SpeechToText.Phrases)
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(phraseHintsRefresh)
+		defer ticker.Stop()
+		for {
+			hints := kai.collectPhraseHints()
+			recognizer.SetPhraseHints(hints)
+			log.Printf("Updated %d speech recognition phrase hints", len(hints))
+			select {
+			case <-kai.Context.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Method builds the phrase hints, most useful first: the phrases from the
+// configuration, the commands and packages run in the conversation and the
+// shell history, executables that share a package's name, executables the
+// user installed, the remaining executables and finally the packages.
+func (kai *Kai) collectPhraseHints() []string {
+	executables, ownExecutables := pathExecutables()
+	packages := installedPackages(kai.Context)
+	known := make(map[string]bool, len(executables)+len(packages))
+	for _, name := range executables {
+		known[name] = true
+	}
+	isPackage := make(map[string]bool, len(packages))
+	for _, name := range packages {
+		known[name], isPackage[name] = true, true
+	}
+	var hints []string
+	seen := make(map[string]bool)
+	add := func(phrases ...string) {
+		for _, phrase := range phrases {
+			if len(hints) < maxPhraseHints && phrase != "" && !seen[phrase] {
+				hints = append(hints, phrase)
+				seen[phrase] = true
+			}
+		}
+	}
+	add(kai.Config.SpeechToText.Phrases...)
+	add(historyTerms(kai.HistoryFile, shellHistoryFiles(), known)...)
+	for _, name := range executables {
+		if isPackage[name] {
+			add(name)
+		}
+	}
+	add(ownExecutables...)
+	add(executables...)
+	add(packages...)
+	return hints
+}
+
+// Method returns the history files of the user's shells that exist.
+func shellHistoryFiles() []string {
+	var files []string
+	seen := make(map[string]bool)
+	candidates := []string{os.Getenv("HISTFILE")}
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates,
+			filepath.Join(home, ".bash_history"), filepath.Join(home, ".zsh_history"),
+		)
+	}
+	for _, file := range candidates {
+		if file == "" || seen[file] {
+			continue
+		}
+		seen[file] = true
+		if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// Method lists the names of the executables on PATH, in PATH order. The
+// second list holds those outside the system directories, e.g. in
+// ~/.local/bin, which the user probably installed on purpose.
+func pathExecutables() ([]string, []string) {
+	var executables, own []string
+	seen := make(map[string]bool)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		system := dir == "/bin" || dir == "/sbin" ||
+			strings.HasPrefix(dir, "/usr/bin") || strings.HasPrefix(dir, "/usr/sbin")
+		for _, entry := range entries {
+			name := entry.Name()
+			if seen[name] || !commandNameRegexp.MatchString(name) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || info.IsDir() || info.Mode().Perm()&0o111 == 0 {
+				continue
+			}
+			seen[name] = true
+			executables = append(executables, name)
+			if !system {
+				own = append(own, name)
+			}
+		}
+	}
+	return executables, own
+}
+
+// Method lists the installed packages with the first package manager found.
+func installedPackages(ctx context.Context) []string {
+	for _, command := range packageListCommands {
+		if _, err := exec.LookPath(command[0]); err != nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(ctx, packageListTimeout)
+		output, err := exec.CommandContext(ctx, command[0], command[1:]...).Output()
+		cancel()
+		if err != nil {
+			log.Printf("Failed to list installed packages with %s: %v", command[0], err)
+			continue
+		}
+		var packages []string
+		for _, line := range strings.Split(string(output), "\n") {
+			name := strings.TrimSpace(line)
+			if commandNameRegexp.MatchString(name) {
+				packages = append(packages, name)
+			}
+		}
+		return packages
+	}
+	return nil
+}
+
+// Method returns the terms of the saved conversation and of the shell
+// history that are quoted as code or run as a known command or package,
+// most frequent first. Only the command position counts, i.e. the first
+// word of a command, so that common words which happen to name a command,
+// such as "time" or "find", are not hinted because they came up in
+// conversation.
+//
+// Parameters:
+//  - historyFile: The saved conversation.
+//  - shellHistories: The shell history files.
+//  - known: The names of the installed commands and packages.
+//
+// Returns:
+//  - []string: The terms, most frequent first.
+func historyTerms(historyFile string, shellHistories []string, known map[string]bool) []string {
+	counts := make(map[string]int)
+	count := func(commands ...string) {
+		for _, command := range commands {
+			for _, name := range commandNames(command) {
+				if known[name] {
+					counts[name]++
+				}
+			}
+		}
+	}
+	for _, text := range historyTexts(historyFile) {
+		for _, match := range historyCodeRegexp.FindAllStringSubmatch(text, -1) {
+			counts[match[1]]++
+		}
+		count(responseCommands(text)...)
+	}
+	for _, file := range shellHistories {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			// zsh's extended history prefixes ": <time>:<duration>;"
+			if strings.HasPrefix(line, ": ") {
+				if _, command, found := strings.Cut(line, ";"); found {
+					line = command
+				}
+			}
+			count(line)
+		}
+	}
+	terms := make([]string, 0, len(counts))
+	for term := range counts {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if counts[terms[i]] != counts[terms[j]] {
+			return counts[terms[i]] > counts[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	return terms
+}
+
+// Method returns the text parts of a saved conversation.
+func historyTexts(historyFile string) []string {
+	if historyFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(historyFile)
+	if err != nil {
+		return nil
+	}
+	var history []struct {
+		Parts []interface{}
+	}
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil
+	}
+	var texts []string
+	for _, content := range history {
+		for _, part := range content.Parts {
+			if text, ok := part.(string); ok {
+				texts = append(texts, text)
+			}
+		}
+	}
+	return texts
+}
+
+// Method returns the shell commands of a response saved in the
+// conversation: those of its "command" items and of its plans' steps.
+func responseCommands(text string) []string {
+	var items []ResponseItem
+	if err := json.Unmarshal([]byte(text), &items); err != nil {
+		return nil
+	}
+	var commands []string
+	for _, item := range items {
+		switch item.Type {
+		case "command":
+			var command struct {
+				Command string `json:"command"`
+			}
+			if json.Unmarshal(item.Data, &command) == nil {
+				commands = append(commands, command.Command)
+			}
+		case "plan":
+			var plan Plan
+			if json.Unmarshal(item.Data, &plan) == nil {
+				for _, step := range plan.Steps {
+					commands = append(commands, step.Commands...)
+				}
+			}
+		}
+	}
+	return commands
+}
+
+// Method returns the names of the commands a shell command line runs: the
+// first word of each command in a pipeline or list, after "sudo" and
+// similar prefixes and after variable assignments.
+func commandNames(line string) []string {
+	var names []string
+	for _, command := range commandSeparatorRegexp.Split(line, -1) {
+		for _, word := range strings.Fields(command) {
+			if commandPrefixes[word] || strings.Contains(word, "=") {
+				continue
+			}
+			if commandNameRegexp.MatchString(word) {
+				names = append(names, word)
+			}
+			break
+		}
+	}
+	return names
+}
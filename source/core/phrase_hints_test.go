@@ -0,0 +1,56 @@
+package core
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"path/filepath"
+)
+
+func TestCommandNames(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"git status", []string{"git"}},
+		{"sudo apt-get install -y htop && htop", []string{"apt-get", "htop"}},
+		{"LANG=C sort file | uniq -c; echo $(date)", []string{"sort", "uniq", "echo", "date"}},
+		{"./build.sh --release", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		if got := commandNames(test.line); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("commandNames(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestHistoryTermsRanking(t *testing.T) {
+	dir := t.TempDir()
+	history := filepath.Join(dir, "history.json")
+	conversation := `[
+		{"Role": "user", "Parts": ["What time is it? Find me some time to make a test."]},
+		{"Role": "model", "Parts": ["[{\"type\": \"command\", \"data\": {\"command\": \"date\"}}]"]},
+		{"Role": "user", "Parts": ["Make the tests pass, then find out what time the build takes."]},
+		{"Role": "model", "Parts": ["[{\"type\": \"plan\", \"data\": {\"summary\": \"Test\", \"steps\": [{\"title\": \"Run\", \"commands\": [\"make test\", \"git status\"], \"risk\": \"low\"}]}}]"]},
+		{"Role": "model", "Parts": ["[{\"type\": \"script\", \"data\": {\"message\": \"Use ` + "`kubectl`" + ` for that, or find and time it.\"}}]"]}
+	]`
+	if err := os.WriteFile(history, []byte(conversation), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	shell := filepath.Join(dir, ".zsh_history")
+	commands := ": 1700000000:0;git pull\n: 1700000005:0;git log | less\nmake\n"
+	if err := os.WriteFile(shell, []byte(commands), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	known := map[string]bool{
+		"date": true, "find": true, "git": true, "less": true, "make": true,
+		"test": true, "time": true, "what": true,
+	}
+	// Words of the conversation that name commands do not count, only
+	// commands that were run
+	want := []string{"git", "make", "date", "kubectl", "less"}
+	if got := historyTerms(history, []string{shell}, known); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,422 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"errors"
+	"slices"
+	"context"
+	"strconv"
+	"strings"
+	"encoding/json"
+)
+
+// Risk levels of a plan step.
+var planRiskLevels = []string{"low", "medium", "high"}
+
+// Why the rest of a plan was not run after the user interrupted it.
+var errPlanInterrupted = errors.New("interrupted by the user")
+
+// PlanStep is one reviewable step of a plan, with the commands it intends
+// to run and how risky they are.
+type PlanStep struct {
+	Title    string   `json:"title"`
+	Commands []string `json:"commands"`
+	Risk     string   `json:"risk"`
+}
+
+// Plan is a numbered list of steps proposed by the AI for a multi-step
+// request.
+type Plan struct {
+	Summary string     `json:"summary"`
+	Steps   []PlanStep `json:"steps"`
+}
+
+// PlanProgress reports the state of a step while a plan executes.
+type PlanProgress struct {
+	Step   int
+	Total  int
+	Title  string
+	Status string // "running", "done" or "failed"
+	Output string
+}
+
+// Registers the "plan" response type.
+func init() {
+	RegisterHandler(ResponseHandler{
+		Type:        "plan",
+		Description: "A numbered plan for multi-step requests. Use it " +
+			"instead of several 'command' items whenever a request needs " +
+			"more than two commands or anything risky. The user reviews the " +
+			"plan before it is executed step by step, and the result of " +
+			"every step will be sent back to you. A 'plan' must be the only " +
+			"item of its response besides 'script' items.",
+		Fields: []HandlerField{
+			{
+				Name:        "summary",
+				Type:        "string",
+				Description: "One sentence describing the goal of the plan.",
+				Required:    true,
+			},
+			{
+				Name:        "steps",
+				Type:        "array",
+				Description: "The steps, each an object with a 'title' " +
+					"(string), 'commands' (array of shell commands) and " +
+					"'risk' ('low', 'medium' or 'high').",
+				Required: true,
+			},
+		},
+		Validate: validatePlan,
+		Execute:  processPlan,
+	})
+}
+
+// Method checks that a plan has steps with commands and known risk levels.
+//
+// Parameters:
+//  - data: The JSON-encoded data of the item.
+//
+// Returns:
+//  - error: A description of the first problem found, if any.
+func validatePlan(data json.RawMessage) error {
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return err
+	}
+	return checkPlan(plan)
+}
+
+// Method checks a decoded plan, as proposed or as edited in review.
+func checkPlan(plan Plan) error {
+	if len(plan.Steps) == 0 {
+		return fmt.Errorf("the plan has no steps")
+	}
+	for i, step := range plan.Steps {
+		if len(step.Commands) == 0 {
+			return fmt.Errorf("step %d has no commands", i+1)
+		}
+		if !isPlanRisk(step.Risk) {
+			return fmt.Errorf(
+				"step %d has risk %q, expected one of %s",
+				i+1, step.Risk, strings.Join(planRiskLevels, ", "),
+			)
+		}
+	}
+	return nil
+}
+
+// Method handles the processing of a "plan" response item. The plan is
+// presented for review, then the approved steps are executed in order with
+// progress reported to the UI, and the per-step results are fed back into
+// the AI. A plan left invalid by editing, e.g. with a step whose commands
+// were all removed, is not run.
+//
+// Parameters:
+//  - kai: The AI system handling the request.
+//  - data: The JSON-encoded plan.
+//  - branchCount: The current branch count to manage recursion.
+//
+// Returns:
+//  - bool: True if the results were fed back into the AI.
+func processPlan(kai *Kai, data json.RawMessage, branchCount int) bool {
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		log.Printf("Failed to parse plan data: %v", err)
+		return false
+	}
+	approved, ok := kai.reviewPlan(plan)
+	if kai.interrupted() {
+		// The user moved on while the plan was in review
+		log.Printf("Plan review interrupted: %q", plan.Summary)
+		return true
+	}
+	if !ok || len(approved.Steps) == 0 {
+		kai.handleAIResponse(
+			"The user rejected the plan. Do not execute it. " +
+			"Briefly acknowledge and ask how to proceed.",
+			branchCount,
+		)
+		return true
+	}
+	if err := checkPlan(approved); err != nil {
+		log.Printf("Edited plan is invalid: %v", err)
+		kai.handleAIResponse(fmt.Sprintf(
+			"The user edited the plan, but %v, so nothing was run. " +
+			"Briefly tell the user and ask how to proceed.", err,
+		), branchCount)
+		return true
+	}
+	kai.handleAIResponse(kai.executePlan(approved), branchCount)
+	return true
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Method presents a plan for review, using the UI if one is attached and
+// the terminal otherwise. If the user spoke the request, the plan is
+// summarized aloud and approved by voice instead. Interrupting the turn
+// ends the review as a rejection.
+//
+// Parameters:
+//  - plan: The proposed plan.
+//
+// Returns:
+//  - Plan: The plan as approved, possibly edited, reordered or trimmed.
+//  - bool: False if the user rejected the plan.
+func (kai *Kai) reviewPlan(plan Plan) (Plan, bool) {
+	if kai.VoiceInput() {
+		return plan, kai.approveByVoice(spokenPlanSummary(plan), planRisk(plan))
+	}
+	if kai.OnPlanReview != nil {
+		return kai.OnPlanReview(kai.currentTurn(), plan)
+	}
+	return reviewPlanOnConsole(kai.currentTurn(), terminal(), plan)
+}
+
+// Method executes the steps of an approved plan in order, stopping at the
+// first failing command or when the turn is interrupted.
+//
+// Parameters:
+//  - plan: The approved plan.
+//
+// Returns:
+//  - string: A report of every step's result for the AI.
+func (kai *Kai) executePlan(plan Plan) string {
+	var report strings.Builder
+	fmt.Fprintf(&report, "The user approved the plan %q. Step results:\n", plan.Summary)
+	total := len(plan.Steps)
+	for i, step := range plan.Steps {
+		progress := PlanProgress{Step: i + 1, Total: total, Title: step.Title}
+		progress.Status = "running"
+		kai.reportPlanProgress(progress)
+		var outputs []string
+		var failure error
+		for _, command := range step.Commands {
+			if kai.interrupted() {
+				failure = fmt.Errorf("%s: %w", command, errPlanInterrupted)
+				break
+			}
+			output, err := kai.executeCommand(command)
+			if output != "" {
+				outputs = append(outputs, output)
+			}
+			if err != nil {
+				failure = fmt.Errorf("%s: %w", command, err)
+				break
+			}
+		}
+		progress.Output = strings.Join(outputs, "\n")
+		if failure != nil {
+			progress.Status = "failed"
+			kai.reportPlanProgress(progress)
+			fmt.Fprintf(
+				&report, "%d. %s: failed (%v). Output: %s\n",
+				i+1, step.Title, failure, truncateOutput(progress.Output),
+			)
+			fmt.Fprintf(
+				&report, "Execution stopped; %d remaining step(s) were " +
+				"not run. Please analyze the error and generate a new " +
+				"solution.", total-i-1,
+			)
+			return report.String()
+		}
+		progress.Status = "done"
+		kai.reportPlanProgress(progress)
+		fmt.Fprintf(
+			&report, "%d. %s: done. Output: %s\n",
+			i+1, step.Title, truncateOutput(progress.Output),
+		)
+	}
+	report.WriteString(
+		"All steps completed. Please analyze the results and provide a " +
+		"suitable response.",
+	)
+	return report.String()
+}
+
+// Method sends plan progress to the UI, or prints it when no UI is attached.
+//
+// Parameters:
+//  - progress: The progress of the current step.
+func (kai *Kai) reportPlanProgress(progress PlanProgress) {
+	if kai.OnPlanProgress != nil {
+		kai.OnPlanProgress(progress)
+		return
+	}
+	fmt.Printf(
+		"[%d/%d] %s: %s\n",
+		progress.Step, progress.Total, progress.Title, progress.Status,
+	)
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Method prints a plan and lets the user approve, edit, reorder or drop
+// steps from the terminal, until the context is cancelled.
+//
+// Parameters:
+//  - ctx: Cancelled when the turn is interrupted.
+//  - input: The terminal to take the user's input from.
+//  - plan: The proposed plan.
+//
+// Returns:
+//  - Plan: The plan as approved.
+//  - bool: False if the user rejected the plan.
+func reviewPlanOnConsole(ctx context.Context, input *consoleInput, plan Plan) (Plan, bool) {
+	for {
+		fmt.Println(FormatPlan(plan))
+		fmt.Println(
+			"[a]pprove, [d]rop N, [m]ove N M, [e]dit N, [c]ancel",
+		)
+		fmt.Print("Plan> ")
+		line, err := input.readLine(ctx)
+		if err != nil && strings.TrimSpace(line) == "" {
+			return plan, false
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		args := make([]int, 0, 2)
+		for _, field := range fields[1:] {
+			if number, err := strconv.Atoi(field); err == nil {
+				args = append(args, number)
+			}
+		}
+		switch fields[0] {
+		case "a", "approve":
+			return plan, true
+		case "c", "cancel":
+			return plan, false
+		case "d", "drop":
+			if len(args) == 1 {
+				plan = DropPlanStep(plan, args[0]-1)
+			}
+		case "m", "move":
+			if len(args) == 2 {
+				plan = MovePlanStep(plan, args[0]-1, args[1]-1)
+			}
+		case "e", "edit":
+			if len(args) == 1 && args[0] >= 1 && args[0] <= len(plan.Steps) {
+				fmt.Print("Commands (separate with ;;)> ")
+				commands, err := input.readLine(ctx)
+				if err != nil && ctx.Err() != nil {
+					return plan, false
+				}
+				plan.Steps[args[0]-1].Commands = ParsePlanCommands(
+					strings.ReplaceAll(commands, ";;", "\n"),
+				)
+			}
+		}
+	}
+}
+
+// Method formats a plan as a numbered list with commands and risk levels.
+//
+// Parameters:
+//  - plan: The plan to format.
+//
+// Returns:
+//  - string: The formatted plan.
+func FormatPlan(plan Plan) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "Plan: %s\n", plan.Summary)
+	for i, step := range plan.Steps {
+		fmt.Fprintf(&text, "%d. %s [%s risk]\n", i+1, step.Title, step.Risk)
+		for _, command := range step.Commands {
+			fmt.Fprintf(&text, "     $ %s\n", command)
+		}
+	}
+	return strings.TrimRight(text.String(), "\n")
+}
+
+// Method returns a copy of the plan without the step at the given index.
+func DropPlanStep(plan Plan, index int) Plan {
+	if index < 0 || index >= len(plan.Steps) {
+		return plan
+	}
+	steps := append([]PlanStep(nil), plan.Steps[:index]...)
+	plan.Steps = append(steps, plan.Steps[index+1:]...)
+	return plan
+}
+
+// Method returns a copy of the plan with a step moved to a new index.
+func MovePlanStep(plan Plan, from, to int) Plan {
+	if from < 0 || from >= len(plan.Steps) || to < 0 || to >= len(plan.Steps) {
+		return plan
+	}
+	step := plan.Steps[from]
+	plan = DropPlanStep(plan, from)
+	steps := append([]PlanStep(nil), plan.Steps[:to]...)
+	steps = append(steps, step)
+	plan.Steps = append(steps, plan.Steps[to:]...)
+	return plan
+}
+
+// Method splits edited commands, one per line, dropping blank lines.
+func ParsePlanCommands(text string) []string {
+	var commands []string
+	for _, line := range strings.Split(text, "\n") {
+		if command := strings.TrimSpace(line); command != "" {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+// Method returns the highest risk level of a plan's steps.
+func planRisk(plan Plan) string {
+	highest := 0
+	for _, step := range plan.Steps {
+		highest = max(highest, slices.Index(planRiskLevels, step.Risk))
+	}
+	return planRiskLevels[highest]
+}
+
+// Method summarizes a plan in a few spoken sentences: its goal, its size
+// and its riskiest step.
+func spokenPlanSummary(plan Plan) string {
+	commands := 0
+	riskiest := 0
+	for i, step := range plan.Steps {
+		commands += len(step.Commands)
+		if slices.Index(planRiskLevels, step.Risk) >
+			slices.Index(planRiskLevels, plan.Steps[riskiest].Risk) {
+			riskiest = i
+		}
+	}
+	summary := fmt.Sprintf(
+		"Plan: %s. It runs %s in %s.", strings.TrimSuffix(plan.Summary, "."),
+		pluralize(commands, "command"), pluralize(len(plan.Steps), "step"),
+	)
+	if len(plan.Steps) > 0 && plan.Steps[riskiest].Risk != planRiskLevels[0] {
+		summary += fmt.Sprintf(
+			" The riskiest step is: %s.",
+			strings.TrimSuffix(plan.Steps[riskiest].Title, "."),
+		)
+	}
+	return summary
+}
+
+// Method formats a count with a noun, e.g. "1 step" or "3 steps".
+func pluralize(count int, noun string) string {
+	if count == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+// Method reports whether a string is a known risk level.
+func isPlanRisk(risk string) bool {
+	for _, level := range planRiskLevels {
+		if risk == level {
+			return true
+		}
+	}
+	return false
+}
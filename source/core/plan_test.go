@@ -0,0 +1,86 @@
+package core
+
+import (
+	"os"
+	"time"
+	"context"
+	"strings"
+	"testing"
+	"path/filepath"
+)
+
+func TestCheckPlan(t *testing.T) {
+	step := PlanStep{Title: "List", Commands: []string{"ls"}, Risk: "low"}
+	tests := []struct {
+		name  string
+		plan  Plan
+		error string
+	}{
+		{"valid", Plan{Steps: []PlanStep{step}}, ""},
+		{"no steps", Plan{}, "no steps"},
+		{"emptied step", Plan{Steps: []PlanStep{step, {Title: "Empty", Risk: "low"}}}, "step 2 has no commands"},
+		{"unknown risk", Plan{Steps: []PlanStep{{Title: "X", Commands: []string{"ls"}, Risk: "extreme"}}}, "risk"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkPlan(test.plan)
+			if test.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("got %v, want an error containing %q", err, test.error)
+			}
+		})
+	}
+}
+
+func TestExecutePlanTruncatesFailedOutput(t *testing.T) {
+	kai := &Kai{Context: context.Background(), OnPlanProgress: func(PlanProgress) {}}
+	plan := Plan{
+		Summary: "Fail loudly",
+		Steps: []PlanStep{{
+			Title:    "Print a lot",
+			Commands: []string{"head -c 200000 /dev/zero | tr '\\0' x; exit 1"},
+			Risk:     "low",
+		}},
+	}
+	report := kai.executePlan(plan)
+	if len(report) > maxToolOutputBytes+1024 {
+		t.Errorf("report is %d bytes, want the output truncated", len(report))
+	}
+	if !strings.Contains(report, "(output truncated)") {
+		t.Error("report does not say the output was truncated")
+	}
+}
+
+func TestInterruptStopsPlan(t *testing.T) {
+	kai := &Kai{Context: context.Background(), OnPlanProgress: func(PlanProgress) {}}
+	_, endTurn := kai.beginTurn()
+	defer endTurn()
+	marker := filepath.Join(t.TempDir(), "marker")
+	plan := Plan{
+		Summary: "Wait, then mark",
+		Steps: []PlanStep{
+			{Title: "Wait", Commands: []string{"sleep 10"}, Risk: "low"},
+			{Title: "Mark", Commands: []string{"touch " + marker}, Risk: "low"},
+		},
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		kai.Interrupt()
+	}()
+	start := time.Now()
+	report := kai.executePlan(plan)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the interrupted command ran for %v", elapsed)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("the step after the interruption ran")
+	}
+	if !strings.Contains(report, "1 remaining step(s) were not run") {
+		t.Errorf("got report %q", report)
+	}
+}
@@ -0,0 +1,214 @@
+package core
+
+import (
+	"log"
+	"math"
+	"time"
+	// Local utilities
+	"kai/source/utils"
+)
+
+// Sample rate audio is sent to recognizers at: enough for speech, and a
+// quarter of the upload size of CD quality audio.
+const recognitionSampleRate = 16000
+
+// Audio preprocessing settings.
+const (
+	// Cutoff of the high-pass filter removing DC offset and rumble, in Hz
+	highPassCutoff      = 80.0
+	// Speech level the gain normalization aims for, as RMS amplitude
+	targetLevel         = 3000.0
+	// Largest gain applied to quiet speech, so noise is not amplified
+	maxNormalizeGain    = 8.0
+	// Frames less than this factor above the noise floor count as noise
+	noiseGateRatio      = 2.0
+	// Gain applied to noise frames, about -12 dB
+	noiseAttenuation    = 0.25
+	// Length of the frames levels are measured on
+	preprocessFrame     = 20 * time.Millisecond
+	// Input samples at least this loud count as clipped
+	clipLevel           = 32000
+	// Share of clipped input samples that triggers the clipping warning
+	clipWarningFraction = 0.001
+)
+
+// Warning shown when the microphone input is clipping.
+const clipWarning = "Your microphone is too loud and the recording is " +
+	"distorted. Lower its input volume so Kai understands you better."
+
+// AudioPreprocessor prepares recorded 16-bit mono audio for recognition: it
+// resamples to 16 kHz, removes rumble, attenuates background noise between
+// words and normalizes the speech level. It keeps its state between calls,
+// so a stream can be processed buffer by buffer.
+type AudioPreprocessor struct {
+	resampler  *utils.Resampler
+	frameSize  int
+	frame      []float64 // Samples waiting for a complete frame
+	// High-pass filter state
+	alpha      float64
+	lastInput  float64
+	lastOutput float64
+	// Estimated level of the background noise
+	noiseFloor float64
+	// Gain currently applied, moving smoothly between frames
+	gain       float64
+	// Input samples seen and how many of them clipped
+	samples    int
+	clipped    int
+}
+
+// Method creates a preprocessor for audio recorded at the given rate.
+func NewAudioPreprocessor(sampleRate int) *AudioPreprocessor {
+	rc := 1 / (2 * math.Pi * highPassCutoff)
+	dt := 1 / float64(recognitionSampleRate)
+	return &AudioPreprocessor{
+		resampler: utils.NewResampler(sampleRate, recognitionSampleRate),
+		frameSize: durationSamples(preprocessFrame, recognitionSampleRate),
+		alpha:     rc / (rc + dt),
+		gain:      1,
+	}
+}
+
+// Method processes the next buffer of the stream. Samples are returned a
+// frame at a time, so up to 20 ms are held back until Flush.
+//
+// Parameters:
+//  - samples: The recorded samples at the preprocessor's input rate.
+//
+// Returns:
+//  - []int16: The processed samples at 16 kHz.
+func (p *AudioPreprocessor) Process(samples []int16) []int16 {
+	// Detect clipping on the input, before any gain is applied
+	for _, sample := range samples {
+		if sample >= clipLevel || sample <= -clipLevel {
+			p.clipped++
+		}
+	}
+	p.samples += len(samples)
+	var processed []int16
+	for _, sample := range p.resampler.Process(samples) {
+		p.frame = append(p.frame, p.highPass(float64(sample)))
+		if len(p.frame) == p.frameSize {
+			processed = p.processFrame(processed)
+		}
+	}
+	return processed
+}
+
+// Method processes the samples held back at the end of the stream.
+func (p *AudioPreprocessor) Flush() []int16 {
+	if len(p.frame) == 0 {
+		return nil
+	}
+	return p.processFrame(nil)
+}
+
+// Method reports whether enough of the input clipped to distort speech.
+func (p *AudioPreprocessor) Clipping() bool {
+	return p.clipped > 0 &&
+		float64(p.clipped) >= clipWarningFraction*float64(p.samples)
+}
+
+// Method removes frequencies below the cutoff with a one-pole filter.
+func (p *AudioPreprocessor) highPass(sample float64) float64 {
+	output := p.alpha * (p.lastOutput + sample - p.lastInput)
+	p.lastInput, p.lastOutput = sample, output
+	return output
+}
+
+// Method applies noise suppression and gain normalization to the buffered
+// frame and appends it to the output. The gain ramps across the frame from
+// its previous value, so level changes do not click.
+func (p *AudioPreprocessor) processFrame(output []int16) []int16 {
+	var sum float64
+	for _, sample := range p.frame {
+		sum += sample * sample
+	}
+	level := math.Sqrt(sum / float64(len(p.frame)))
+	// Track the noise floor: follow quieter frames at once and louder ones
+	// slowly, so speech barely raises it
+	if p.noiseFloor == 0 || level < p.noiseFloor {
+		p.noiseFloor = level
+	} else {
+		p.noiseFloor += (level - p.noiseFloor) * 0.005
+	}
+	target := noiseAttenuation
+	if level > p.noiseFloor*noiseGateRatio && level > 0 {
+		// Speech: bring it to the target level, without reducing loud speech
+		// below unity gain or boosting quiet speech beyond the limit
+		target = min(max(targetLevel/level, 1), maxNormalizeGain)
+	}
+	start := p.gain
+	p.gain += (target - p.gain) * 0.5
+	for i, sample := range p.frame {
+		gain := start + (p.gain-start)*float64(i+1)/float64(len(p.frame))
+		value := sample * gain
+		output = append(output, int16(max(min(value, math.MaxInt16), math.MinInt16)))
+	}
+	p.frame = p.frame[:0]
+	return output
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Method reports whether recorded audio is preprocessed for recognition.
+func (kai *Kai) preprocessing() bool {
+	return !kai.audioConfig().RawRecognition
+}
+
+// Method prepares a recording for recognition, warning the user if it
+// clipped.
+//
+// Parameters:
+//  - audioData: The recorded 16-bit mono PCM audio.
+//  - sampleRate: The sample rate of the recording.
+//
+// Returns:
+//  - []byte: The audio to recognize.
+//  - int: Its sample rate.
+func (kai *Kai) preprocess(audioData []byte, sampleRate int) ([]byte, int) {
+	if !kai.preprocessing() {
+		return audioData, sampleRate
+	}
+	preprocessor := NewAudioPreprocessor(sampleRate)
+	samples := preprocessor.Process(utils.BytesToSamples(audioData))
+	samples = append(samples, preprocessor.Flush()...)
+	kai.checkClipping(preprocessor)
+	return utils.SamplesToBytes(samples), recognitionSampleRate
+}
+
+// Method prepares chunks streamed at Kai's sample rate for recognition like
+// preprocess. The returned channel is closed after the input channel.
+func (kai *Kai) preprocessStream(chunks <-chan []byte) (<-chan []byte, int) {
+	if !kai.preprocessing() {
+		return chunks, kai.SampleRate
+	}
+	processed := make(chan []byte, cap(chunks))
+	go func() {
+		defer close(processed)
+		preprocessor := NewAudioPreprocessor(kai.SampleRate)
+		for chunk := range chunks {
+			if samples := preprocessor.Process(utils.BytesToSamples(chunk)); len(samples) > 0 {
+				processed <- utils.SamplesToBytes(samples)
+			}
+		}
+		if samples := preprocessor.Flush(); len(samples) > 0 {
+			processed <- utils.SamplesToBytes(samples)
+		}
+		kai.checkClipping(preprocessor)
+	}()
+	return processed, recognitionSampleRate
+}
+
+// Method warns the user if a recording clipped.
+func (kai *Kai) checkClipping(preprocessor *AudioPreprocessor) {
+	if !preprocessor.Clipping() {
+		return
+	}
+	log.Println("Microphone input is clipping")
+	if kai.OnAudioWarning != nil {
+		kai.OnAudioWarning(clipWarning)
+	}
+}
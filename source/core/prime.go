@@ -8,11 +8,15 @@ import (
 	"kai/source/utils"
 	// Gemini API
 	"github.com/google/generative-ai-go/genai"
+	// Local imports
+	"kai/source/mcp"
 )
 
 // Method primes the AI with the provided primer and history file.
 func (kai *Kai) PrimeAI(primer, historyFile string) {
-	// Initialize the chat session
+	// Initialize the chat session, remembering the primer for the
+	// conversations of other speakers
+	kai.primer = primer
 	kai.Chat = kai.Model.StartChat()
 	// Check if a history file is provided
 	if historyFile != "" {
@@ -66,4 +70,21 @@ func (kai *Kai) PrimeAI(primer, historyFile string) {
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+
+// Method describes the response protocol, and the tools and resources of
+// the connected MCP servers, to the model. Both change between sessions,
+// so they are given as a system instruction in every session rather than
+// being stored in the chat history.
+//
+// Parameters:
+//  - servers: The configured MCP servers.
+func (kai *Kai) instructModel(servers []mcp.ServerConfig) {
+	instruction := ProtocolDescription()
+	if len(kai.MCPServers) > 0 {
+		instruction += "\n\n" + kai.describeMCPServers(servers)
+	}
+	kai.Model.SystemInstruction = &genai.Content{
+		Parts: []genai.Part{genai.Text(instruction)},
+	}
+}
@@ -0,0 +1,23 @@
+package core
+
+import (
+	"context"
+	"testing"
+	// Gemini API
+	"github.com/google/generative-ai-go/genai"
+)
+
+// The protocol is not part of the primer, so conversations continued from
+// a history file get it too.
+func TestModelIsInstructedWithProtocol(t *testing.T) {
+	kai := &Kai{Context: context.Background(), Model: &genai.GenerativeModel{}}
+	kai.connectMCPServers(nil)
+	kai.instructModel(nil)
+	instruction := kai.Model.SystemInstruction
+	if instruction == nil || len(instruction.Parts) != 1 {
+		t.Fatalf("got system instruction %+v", instruction)
+	}
+	if text, _ := instruction.Parts[0].(genai.Text); string(text) != ProtocolDescription() {
+		t.Errorf("got system instruction %q, want the response protocol", text)
+	}
+}
@@ -11,8 +11,17 @@ func (kai *Kai) Reason(userInput string) (string, error) {
 	if kai.Chat == nil {
 		return "", fmt.Errorf("Kai's Chat is not initialized")
 	}
+	// Switch to the conversation of the user speaking, if it changed
+	parts := []genai.Part{genai.Text(userInput)}
+	if note := kai.speakerNote(); note != "" {
+		parts = append(parts, genai.Text(note))
+	}
+	// Tell the model when the user switched languages
+	if note := kai.languageNote(); note != "" {
+		parts = append(parts, genai.Text(note))
+	}
 	// Send a message to the chat
-	resp, err := kai.Chat.SendMessage(kai.Context, genai.Text(userInput))
+	resp, err := kai.Chat.SendMessage(kai.Context, parts...)
 	if err != nil {
 		return "", fmt.Errorf("error sending message: %v", err)
 	}
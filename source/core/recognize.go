@@ -1,28 +1,211 @@
 package core
 
 import (
-    "context"
+    "io"
     "fmt"
+    "errors"
+    "sync"
+    "slices"
+    "strings"
+    "context"
     speech "cloud.google.com/go/speech/apiv1"
     "cloud.google.com/go/speech/apiv1/speechpb"
 )
 
-// Method sends recorded audio to the Google Cloud Speech-to-Text API for 
+// ErrNoSpeech is returned by recognizers when the audio contains no words.
+var ErrNoSpeech = errors.New("no transcription results")
+
+// Candidate transcripts requested from engines that can report several.
+const maxAlternatives = 3
+
+// Transcript is the text recognized in an utterance.
+type Transcript struct {
+    Text         string
+    // BCP-47 code of the detected language; empty if unknown
+    Language     string
+    // Estimated probability from 0 to 1 that the text is right; 0 if the
+    // engine does not report it
+    Confidence   float32
+    // Less likely readings of the utterance, most likely first
+    Alternatives []string
+    // Name of the speaker identified by voice; empty if unknown
+    Speaker      string
+}
+
+// Recognizer transcribes recorded 16-bit mono PCM audio to text.
+type Recognizer interface {
+    Recognize(ctx context.Context, audioData []byte, sampleRate int) (Transcript, error)
+    Close() error
+}
+
+// StreamingRecognizer is implemented by recognizers that can transcribe 
+// audio while it is being recorded, reporting interim results.
+type StreamingRecognizer interface {
+    StreamRecognize(
+        ctx context.Context,
+        chunks <-chan []byte,
+        sampleRate int,
+        interim func(transcript string),
+    ) (Transcript, error)
+}
+
+// Method creates the recognizer selected in the configuration, defaulting
+// to Google Cloud Speech-to-Text.
+//
+// Parameters:
+//  - config: The speech-to-text configuration.
+//  - language: The languages the user speaks.
+//
+// Returns:
+//  - Recognizer: The selected recognizer.
+//  - error: Error if the engine is unknown or misconfigured.
+func NewRecognizer(
+    config SpeechToTextConfig,
+    language LanguageConfig,
+) (Recognizer, error) {
+    switch config.Engine {
+    case "", "google":
+        return NewGoogleRecognizer(language), nil
+    case "whisper", "vosk":
+        return NewOfflineRecognizer(config, language)
+    case "fake":
+        return &FakeRecognizer{Transcripts: config.Args}, nil
+    default:
+        return nil, fmt.Errorf("unknown speech-to-text engine %q", config.Engine)
+    }
+}
+
+// Method transcribes recorded audio with the configured recognizer and 
+// records the detected language, so the reply follows it, and the speaker, 
+// so their profile is used. The audio is preprocessed for recognition 
+// first.
+func (kai *Kai) Recognize(audioData []byte) (Transcript, error) {
+    if kai.Recognizer == nil {
+        return Transcript{}, fmt.Errorf("no speech recognizer configured")
+    }
+    audioData, sampleRate := kai.preprocess(audioData, kai.SampleRate)
+    transcript, err := kai.Recognizer.Recognize(kai.Context, audioData, sampleRate)
+    if err == nil {
+        kai.setLanguage(transcript.Language)
+        transcript.Speaker = kai.identifySpeaker(audioData, sampleRate)
+    }
+    return transcript, err
+}
+
+// Method transcribes audio as it is recorded, calling interim with the 
+// transcript so far. Recognizers without streaming support transcribe the 
+// whole recording once the chunks channel closes.
+//
+// Parameters:
+//  - chunks: PCM buffers from ListenStream, closed when recording stops.
+//  - interim: Called with partial transcripts; may be nil.
+//
+// Returns:
+//  - Transcript: The final transcript.
+//  - error: Error encountered during recognition, if any.
+func (kai *Kai) RecognizeStream(
+    chunks <-chan []byte,
+    interim func(transcript string),
+) (Transcript, error) {
+    if interim == nil {
+        interim = func(string) {}
+    }
+    if streaming, ok := kai.Recognizer.(StreamingRecognizer); ok {
+        processed, sampleRate := kai.preprocessStream(chunks)
+        processed, identify := kai.identifySpeakerStream(processed, sampleRate)
+        transcript, err := streaming.StreamRecognize(
+            kai.Context, processed, sampleRate, interim,
+        )
+        // Drain the chunks left if recognition stopped early
+        for range processed {
+        }
+        speaker := identify()
+        if err == nil {
+            kai.setLanguage(transcript.Language)
+            transcript.Speaker = speaker
+        }
+        return transcript, err
+    }
+    var audioData []byte
+    for chunk := range chunks {
+        audioData = append(audioData, chunk...)
+    }
+    return kai.Recognize(audioData)
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// GoogleRecognizer sends audio to the Google Cloud Speech-to-Text API. The
+// client is created on first use and reused afterwards.
+type GoogleRecognizer struct {
+    LanguageCode             string
+    // Up to three other languages the API may detect instead
+    AlternativeLanguageCodes []string
+    mutex                    sync.Mutex
+    client                   *speech.Client
+    phrases                  []string // Phrase hints, see SetPhraseHints
+}
+
+// Method creates a Google recognizer for the configured languages.
+func NewGoogleRecognizer(language LanguageConfig) *GoogleRecognizer {
+    languages := language.All()
+    return &GoogleRecognizer{
+        LanguageCode:             languages[0],
+        AlternativeLanguageCodes: languages[1:],
+    }
+}
+
+// Method returns the recognition settings shared by batch and streaming 
+// requests.
+func (r *GoogleRecognizer) recognitionConfig(
+    sampleRate int,
+) *speechpb.RecognitionConfig {
+    languageCode := r.LanguageCode
+    if languageCode == "" {
+        languageCode = defaultLanguage
+    }
+    config := &speechpb.RecognitionConfig{
+        Encoding:                 speechpb.RecognitionConfig_LINEAR16,
+        SampleRateHertz:          int32(sampleRate),
+        LanguageCode:             languageCode,
+        AlternativeLanguageCodes: r.AlternativeLanguageCodes,
+        MaxAlternatives:          maxAlternatives,
+    }
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+    if len(r.phrases) > 0 {
+        config.SpeechContexts = []*speechpb.SpeechContext{
+            {Phrases: r.phrases, Boost: phraseHintBoost},
+        }
+    }
+    return config
+}
+
+// Method biases recognition towards the given phrases, e.g. command and
+// package names, through the API's speech adaptation. Safe to call while
+// recognizing; the next request uses the new phrases.
+func (r *GoogleRecognizer) SetPhraseHints(phrases []string) {
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+    r.phrases = phrases
+}
+
+// Method sends recorded audio to the Google Cloud Speech-to-Text API for
 // transcription.
-func (kai *Kai) Recognize(audioData []byte) (string, error) {
-    ctx := context.Background()
-    client, err := speech.NewClient(ctx)
+func (r *GoogleRecognizer) Recognize(
+    ctx context.Context,
+    audioData []byte,
+    sampleRate int,
+) (Transcript, error) {
+    client, err := r.speechClient(ctx)
     if err != nil {
-        return "", fmt.Errorf("failed to create speech client: %v", err)
+        return Transcript{}, err
     }
-    defer client.Close()
     // Configure the request with the correct audio encoding and sample rate
     req := &speechpb.RecognizeRequest{
-        Config: &speechpb.RecognitionConfig{
-            Encoding:        speechpb.RecognitionConfig_LINEAR16,
-            SampleRateHertz: 44100,
-            LanguageCode:    "en-US",
-        },
+        Config: r.recognitionConfig(sampleRate),
         Audio: &speechpb.RecognitionAudio{
             AudioSource: &speechpb.RecognitionAudio_Content{
                 Content: audioData,
@@ -32,11 +215,212 @@ func (kai *Kai) Recognize(audioData []byte) (string, error) {
     // Send the request and get the response
     resp, err := client.Recognize(ctx, req)
     if err != nil {
-        return "", fmt.Errorf("failed to recognize speech: %v", err)
+        return Transcript{}, fmt.Errorf("failed to recognize speech: %v", err)
     }
     // Process the response and extract the transcribed text
-    if len(resp.Results) > 0 && len(resp.Results[0].Alternatives) > 0 {
-        return resp.Results[0].Alternatives[0].Transcript, nil
+    var alternatives [][]*speechpb.SpeechRecognitionAlternative
+    language := ""
+    for _, result := range resp.Results {
+        alternatives = append(alternatives, result.Alternatives)
+        if language == "" {
+            language = result.LanguageCode
+        }
+    }
+    transcript := combineAlternatives(alternatives)
+    if transcript.Text == "" {
+        return Transcript{}, ErrNoSpeech
+    }
+    transcript.Language = language
+    return transcript, nil
+}
+
+// Method streams audio to the Google Cloud Speech-to-Text API while it is 
+// recorded. Interim results are reported as they arrive, and the final 
+// transcript is returned once the audio ends and the API has finalized it.
+func (r *GoogleRecognizer) StreamRecognize(
+    ctx context.Context,
+    chunks <-chan []byte,
+    sampleRate int,
+    interim func(transcript string),
+) (Transcript, error) {
+    client, err := r.speechClient(ctx)
+    if err != nil {
+        return Transcript{}, err
+    }
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+    stream, err := client.StreamingRecognize(ctx)
+    if err != nil {
+        return Transcript{}, fmt.Errorf("failed to open recognition stream: %v", err)
+    }
+    // The first request carries the configuration
+    err = stream.Send(&speechpb.StreamingRecognizeRequest{
+        StreamingRequest: &speechpb.StreamingRecognizeRequest_StreamingConfig{
+            StreamingConfig: &speechpb.StreamingRecognitionConfig{
+                Config:         r.recognitionConfig(sampleRate),
+                InterimResults: true,
+            },
+        },
+    })
+    if err != nil {
+        return Transcript{}, fmt.Errorf("failed to send recognition config: %v", err)
+    }
+    // Send audio in the background, batching small buffers into ~100 ms
+    sendErr := make(chan error, 1)
+    go func() {
+        sendErr <- sendAudioChunks(stream, chunks, sampleRate/10*2)
+    }()
+    // Collect results until the API closes the stream
+    var final [][]*speechpb.SpeechRecognitionAlternative
+    var finalText []string
+    pending := ""
+    language := ""
+    for {
+        resp, err := stream.Recv()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            // The sender drains the remaining chunks once the stream closes
+            return Transcript{}, fmt.Errorf("failed to recognize speech: %v", err)
+        }
+        pending = ""
+        for _, result := range resp.Results {
+            if len(result.Alternatives) == 0 {
+                continue
+            }
+            transcript := strings.TrimSpace(result.Alternatives[0].Transcript)
+            if result.LanguageCode != "" {
+                language = result.LanguageCode
+            }
+            if result.IsFinal {
+                final = append(final, result.Alternatives)
+                finalText = append(finalText, transcript)
+            } else {
+                pending = strings.TrimSpace(pending + " " + transcript)
+            }
+        }
+        interim(strings.TrimSpace(strings.Join(finalText, " ") + " " + pending))
+    }
+    if err := <-sendErr; err != nil {
+        return Transcript{}, err
+    }
+    if pending != "" {
+        // The stream ended before the API finalized the last words, so the
+        // confidence and the alternatives are unknown
+        text := strings.TrimSpace(strings.Join(finalText, " ") + " " + pending)
+        return Transcript{Text: text, Language: language}, nil
+    }
+    transcript := combineAlternatives(final)
+    if transcript.Text == "" {
+        return Transcript{}, ErrNoSpeech
+    }
+    transcript.Language = language
+    return transcript, nil
+}
+
+// Method joins the results of consecutive parts of an utterance into one
+// transcript. The confidence is that of the least certain part, and each
+// alternative replaces the parts that have one with their next reading.
+//
+// Parameters:
+//  - results: The alternatives of each part, most likely first.
+//
+// Returns:
+//  - Transcript: The joined text, confidence and alternatives.
+func combineAlternatives(
+    results [][]*speechpb.SpeechRecognitionAlternative,
+) Transcript {
+    var transcript Transcript
+    readings := make([][]string, maxAlternatives)
+    for _, alternatives := range results {
+        if len(alternatives) == 0 {
+            continue
+        }
+        best := alternatives[0]
+        confidence := best.Confidence
+        if confidence > 0 &&
+            (transcript.Confidence == 0 || confidence < transcript.Confidence) {
+            transcript.Confidence = confidence
+        }
+        for i := range readings {
+            reading := best.Transcript
+            if i < len(alternatives) {
+                reading = alternatives[i].Transcript
+            }
+            readings[i] = append(readings[i], strings.TrimSpace(reading))
+        }
+    }
+    transcript.Text = strings.TrimSpace(strings.Join(readings[0], " "))
+    for _, reading := range readings[1:] {
+        text := strings.TrimSpace(strings.Join(reading, " "))
+        if text != "" && text != transcript.Text &&
+            !slices.Contains(transcript.Alternatives, text) {
+            transcript.Alternatives = append(transcript.Alternatives, text)
+        }
+    }
+    return transcript
+}
+
+// Method forwards recorded audio to a recognition stream, batching buffers 
+// to at least minBytes, and closes the sending side when recording ends.
+func sendAudioChunks(
+    stream speechpb.Speech_StreamingRecognizeClient,
+    chunks <-chan []byte,
+    minBytes int,
+) error {
+    var batch []byte
+    send := func() error {
+        if len(batch) == 0 {
+            return nil
+        }
+        err := stream.Send(&speechpb.StreamingRecognizeRequest{
+            StreamingRequest: &speechpb.StreamingRecognizeRequest_AudioContent{
+                AudioContent: batch,
+            },
+        })
+        batch = nil
+        return err
+    }
+    for chunk := range chunks {
+        batch = append(batch, chunk...)
+        if len(batch) >= minBytes {
+            if err := send(); err != nil {
+                for range chunks {}
+                return fmt.Errorf("failed to send audio: %v", err)
+            }
+        }
+    }
+    if err := send(); err != nil {
+        return fmt.Errorf("failed to send audio: %v", err)
+    }
+    return stream.CloseSend()
+}
+
+// Method closes the speech client if it was created.
+func (r *GoogleRecognizer) Close() error {
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+    if r.client == nil {
+        return nil
+    }
+    err := r.client.Close()
+    r.client = nil
+    return err
+}
+
+// Method returns the shared speech client, creating it if needed.
+func (r *GoogleRecognizer) speechClient(
+    ctx context.Context,
+) (*speech.Client, error) {
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+    if r.client == nil {
+        client, err := speech.NewClient(ctx)
+        if err != nil {
+            return nil, fmt.Errorf("failed to create speech client: %v", err)
+        }
+        r.client = client
     }
-    return "", fmt.Errorf("no transcription results")
-}
\ No newline at end of file
+    return r.client, nil
+}
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"sync"
+	"context"
+)
+
+// FakeRecognizer returns canned transcripts in order, for tests and for
+// exercising the voice pipeline without a speech engine. It records the
+// audio it was given.
+type FakeRecognizer struct {
+	Transcripts []string
+	// Language reported with every transcript
+	Language    string
+	Err         error
+	mutex       sync.Mutex
+	Calls       [][]byte
+}
+
+// Method returns the next canned transcript, or Err if set.
+func (r *FakeRecognizer) Recognize(
+	_ context.Context,
+	audioData []byte,
+	_ int,
+) (Transcript, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Calls = append(r.Calls, audioData)
+	if r.Err != nil {
+		return Transcript{}, r.Err
+	}
+	if len(r.Transcripts) == 0 {
+		return Transcript{}, ErrNoSpeech
+	}
+	transcript := r.Transcripts[0]
+	r.Transcripts = r.Transcripts[1:]
+	return Transcript{Text: transcript, Language: r.Language}, nil
+}
+
+// Method has nothing to release.
+func (r *FakeRecognizer) Close() error {
+	return nil
+}
@@ -0,0 +1,184 @@
+package core
+
+import (
+	"os"
+	"fmt"
+	"sync"
+	"bytes"
+	"context"
+	"regexp"
+	"os/exec"
+	"strings"
+	"path/filepath"
+	// Local utilities
+	"kai/source/utils"
+)
+
+// Sample rate expected by the offline speech models.
+const offlineSampleRate = 16000
+
+// Phrase hints passed to whisper as its prompt; the prompt is limited to
+// about 220 tokens.
+const whisperPromptPhrases = 40
+
+// Matches the language whisper reports when detecting it, e.g.
+// "auto-detected language: es (p = 0.97)".
+var whisperLanguageRegexp = regexp.MustCompile(`auto-detected language: (\w+)`)
+
+// OfflineRecognizer transcribes audio locally by running a whisper.cpp or
+// Vosk command line binary on a temporary WAV file.
+type OfflineRecognizer struct {
+	Engine    string
+	Binary    string
+	Model     string
+	Args      []string
+	// Languages the user speaks, primary first. Whisper detects the language
+	// when there are several; Vosk models are trained for a single one.
+	Languages []string
+	mutex     sync.Mutex
+	phrases   []string // Phrase hints, see SetPhraseHints
+}
+
+// Method creates an offline recognizer, checking that the binary and the
+// model exist.
+//
+// Parameters:
+//  - config: The speech-to-text configuration.
+//  - language: The languages the user speaks.
+//
+// Returns:
+//  - *OfflineRecognizer: The recognizer.
+//  - error: Error if the binary or the model cannot be found.
+func NewOfflineRecognizer(
+	config SpeechToTextConfig,
+	language LanguageConfig,
+) (*OfflineRecognizer, error) {
+	binary := config.Binary
+	if binary == "" {
+		binary = map[string]string{
+			"whisper": "whisper-cli",
+			"vosk":    "vosk-transcriber",
+		}[config.Engine]
+	}
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("%s binary not found: %w", config.Engine, err)
+	}
+	if config.Model == "" {
+		return nil, fmt.Errorf("%s requires a model path", config.Engine)
+	}
+	if _, err := os.Stat(expandPath(config.Model)); err != nil {
+		return nil, fmt.Errorf("%s model not found: %w", config.Engine, err)
+	}
+	return &OfflineRecognizer{
+		Engine: config.Engine,
+		Binary: path,
+		Model:     expandPath(config.Model),
+		Args:      config.Args,
+		Languages: language.All(),
+	}, nil
+}
+
+// Method transcribes the audio by resampling it to 16 kHz, writing it to a
+// temporary WAV file and running the engine's binary on it.
+func (r *OfflineRecognizer) Recognize(
+	ctx context.Context,
+	audioData []byte,
+	sampleRate int,
+) (Transcript, error) {
+	dir, err := os.MkdirTemp("", "kai-stt-")
+	if err != nil {
+		return Transcript{}, fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	wavFile := filepath.Join(dir, "input.wav")
+	samples := utils.Resample(
+		utils.BytesToSamples(audioData), sampleRate, offlineSampleRate,
+	)
+	err = utils.WriteWavFile(wavFile, utils.SamplesToBytes(samples), offlineSampleRate)
+	if err != nil {
+		return Transcript{}, err
+	}
+	cmd := exec.CommandContext(ctx, r.Binary, r.arguments(wavFile)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return Transcript{}, fmt.Errorf(
+			"%s failed: %v: %s", r.Engine, err, strings.TrimSpace(stderr.String()),
+		)
+	}
+	transcript := cleanOfflineTranscript(string(output))
+	if transcript == "" {
+		return Transcript{}, ErrNoSpeech
+	}
+	return Transcript{Text: transcript, Language: r.language(stderr.String())}, nil
+}
+
+// Method has nothing to release; each transcription runs its own process.
+func (r *OfflineRecognizer) Close() error {
+	return nil
+}
+
+// Method biases recognition towards the given phrases. Whisper receives the
+// first of them as its initial prompt, which steers the spelling of unusual
+// words; Vosk's command line offers no vocabulary option, so they are
+// ignored there.
+func (r *OfflineRecognizer) SetPhraseHints(phrases []string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.phrases = phrases
+}
+
+// Method builds the command line arguments for the engine.
+func (r *OfflineRecognizer) arguments(wavFile string) []string {
+	var args []string
+	switch r.Engine {
+	case "whisper":
+		// No timestamps, only the transcript
+		args = []string{"-m", r.Model, "-f", wavFile, "-nt"}
+		if len(r.Languages) > 1 {
+			// Keep the log, which reports the detected language
+			args = append(args, "-l", "auto")
+		} else if len(r.Languages) == 1 {
+			args = append(args, "-np", "-l", languageBase(r.Languages[0]))
+		} else {
+			args = append(args, "-np")
+		}
+		r.mutex.Lock()
+		phrases := r.phrases[:min(len(r.phrases), whisperPromptPhrases)]
+		r.mutex.Unlock()
+		if len(phrases) > 0 {
+			args = append(args, "--prompt", strings.Join(phrases, ", "))
+		}
+	case "vosk":
+		args = []string{"-m", r.Model, "-i", wavFile}
+	}
+	return append(args, r.Args...)
+}
+
+// Method returns the language of a transcription: the one whisper detected,
+// or the primary language.
+func (r *OfflineRecognizer) language(stderr string) string {
+	if match := whisperLanguageRegexp.FindStringSubmatch(stderr); match != nil {
+		return match[1]
+	}
+	if len(r.Languages) > 0 {
+		return r.Languages[0]
+	}
+	return ""
+}
+
+// Method joins the transcript lines printed by an engine and drops the
+// markers whisper emits for silence, e.g. "[BLANK_AUDIO]".
+func cleanOfflineTranscript(output string) string {
+	var parts []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || (strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]")) {
+			continue
+		}
+		parts = append(parts, line)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,230 @@
+package core
+
+import (
+	"os"
+	"context"
+	"reflect"
+	"testing"
+	"path/filepath"
+)
+
+// Method creates an executable shell script in a temporary directory.
+func writeScript(t *testing.T, name, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewRecognizer(t *testing.T) {
+	binary := writeScript(t, "engine", "exit 0\n")
+	model := filepath.Join(t.TempDir(), "model.bin")
+	if err := os.WriteFile(model, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		config  SpeechToTextConfig
+		want    Recognizer
+		wantErr bool
+	}{
+		{"default", SpeechToTextConfig{}, &GoogleRecognizer{}, false},
+		{"google", SpeechToTextConfig{Engine: "google"}, &GoogleRecognizer{}, false},
+		{"whisper", SpeechToTextConfig{Engine: "whisper", Binary: binary, Model: model}, &OfflineRecognizer{}, false},
+		{"vosk", SpeechToTextConfig{Engine: "vosk", Binary: binary, Model: model}, &OfflineRecognizer{}, false},
+		{"fake", SpeechToTextConfig{Engine: "fake", Args: []string{"hello"}}, &FakeRecognizer{}, false},
+		{"missing binary", SpeechToTextConfig{Engine: "whisper", Binary: "/nonexistent/whisper", Model: model}, nil, true},
+		{"missing model", SpeechToTextConfig{Engine: "vosk", Binary: binary, Model: model + ".missing"}, nil, true},
+		{"no model", SpeechToTextConfig{Engine: "whisper", Binary: binary}, nil, true},
+		{"unknown engine", SpeechToTextConfig{Engine: "kaldi"}, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recognizer, err := NewRecognizer(test.config, LanguageConfig{})
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %T", recognizer)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewRecognizer: %v", err)
+			}
+			defer recognizer.Close()
+			if reflect.TypeOf(recognizer) != reflect.TypeOf(test.want) {
+				t.Errorf("got %T, want %T", recognizer, test.want)
+			}
+			if offline, ok := recognizer.(*OfflineRecognizer); ok {
+				if offline.Engine != test.config.Engine || offline.Model != model {
+					t.Errorf("unexpected recognizer: %+v", offline)
+				}
+			}
+		})
+	}
+}
+
+func TestCleanOfflineTranscript(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"", ""},
+		{"  Hello world.  \n", "Hello world."},
+		{"[BLANK_AUDIO]\n", ""},
+		{" Open the\n terminal\n", "Open the terminal"},
+		{"[MUSIC]\n Hello\n\n[BLANK_AUDIO]\n there\n", "Hello there"},
+		{"list [files] here\n", "list [files] here"},
+	}
+	for _, test := range tests {
+		if got := cleanOfflineTranscript(test.output); got != test.want {
+			t.Errorf("cleanOfflineTranscript(%q) = %q, want %q", test.output, got, test.want)
+		}
+	}
+}
+
+func TestOfflineRecognizerArguments(t *testing.T) {
+	many := make([]string, whisperPromptPhrases+5)
+	for i := range many {
+		many[i] = "git"
+	}
+	manyPrompt := many[0]
+	for _, phrase := range many[1:whisperPromptPhrases] {
+		manyPrompt += ", " + phrase
+	}
+	tests := []struct {
+		name       string
+		recognizer *OfflineRecognizer
+		phrases    []string
+		want       []string
+	}{
+		{
+			name:       "whisper without language",
+			recognizer: &OfflineRecognizer{Engine: "whisper", Model: "m.bin"},
+			want:       []string{"-m", "m.bin", "-f", "in.wav", "-nt", "-np"},
+		},
+		{
+			name:       "whisper with one language",
+			recognizer: &OfflineRecognizer{Engine: "whisper", Model: "m.bin", Languages: []string{"es-US"}},
+			want:       []string{"-m", "m.bin", "-f", "in.wav", "-nt", "-np", "-l", "es"},
+		},
+		{
+			name:       "whisper detecting the language",
+			recognizer: &OfflineRecognizer{Engine: "whisper", Model: "m.bin", Languages: []string{"en-US", "es-US"}},
+			want:       []string{"-m", "m.bin", "-f", "in.wav", "-nt", "-l", "auto"},
+		},
+		{
+			name:       "whisper with phrase hints and extra arguments",
+			recognizer: &OfflineRecognizer{Engine: "whisper", Model: "m.bin", Args: []string{"-t", "4"}},
+			phrases:    []string{"kubectl", "htop"},
+			want:       []string{"-m", "m.bin", "-f", "in.wav", "-nt", "-np", "--prompt", "kubectl, htop", "-t", "4"},
+		},
+		{
+			name:       "whisper with too many phrase hints",
+			recognizer: &OfflineRecognizer{Engine: "whisper", Model: "m.bin"},
+			phrases:    many,
+			want:       []string{"-m", "m.bin", "-f", "in.wav", "-nt", "-np", "--prompt", manyPrompt},
+		},
+		{
+			name:       "vosk ignores phrase hints",
+			recognizer: &OfflineRecognizer{Engine: "vosk", Model: "model", Languages: []string{"en-US", "es-US"}, Args: []string{"--log-level", "0"}},
+			phrases:    []string{"kubectl"},
+			want:       []string{"-m", "model", "-i", "in.wav", "--log-level", "0"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.recognizer.SetPhraseHints(test.phrases)
+			if got := test.recognizer.arguments("in.wav"); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestOfflineRecognizerRecognize(t *testing.T) {
+	binary := writeScript(t, "whisper-cli",
+		"echo 'auto-detected language: es (p = 0.97)' >&2\n"+
+			"printf '[BLANK_AUDIO]\\n Hola mundo\\n'\n",
+	)
+	recognizer := &OfflineRecognizer{
+		Engine:    "whisper",
+		Binary:    binary,
+		Model:     "model.bin",
+		Languages: []string{"en-US", "es-US"},
+	}
+	audio := make([]byte, 44100*2/10)
+	transcript, err := recognizer.Recognize(context.Background(), audio, 44100)
+	if err != nil {
+		t.Fatalf("Recognize: %v", err)
+	}
+	if transcript.Text != "Hola mundo" || transcript.Language != "es" {
+		t.Errorf("got %+v", transcript)
+	}
+	silent := &OfflineRecognizer{Engine: "vosk", Binary: writeScript(t, "vosk", "exit 0\n")}
+	if _, err := silent.Recognize(context.Background(), audio, 44100); err != ErrNoSpeech {
+		t.Errorf("got %v, want ErrNoSpeech", err)
+	}
+}
+
+// Runs a stand-in for whisper-cli that prints whisper.cpp's log of a run
+// detecting the language, except with -np, which silences the log.
+func TestWhisperDetectedLanguage(t *testing.T) {
+	log, err := filepath.Abs(filepath.Join("testdata", "whisper", "auto-detected.stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary := writeScript(t, "whisper-cli",
+		"case \" $* \" in *' -np '*) ;; *) cat '"+log+"' >&2 ;; esac\n"+
+			"printf ' Hola mundo\\n'\n",
+	)
+	tests := []struct {
+		name      string
+		languages []string
+		want      string
+	}{
+		{"detected among several", []string{"en-US", "es-US"}, "es"},
+		{"single language", []string{"en-US"}, "en-US"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recognizer := &OfflineRecognizer{
+				Engine:    "whisper",
+				Binary:    binary,
+				Model:     "model.bin",
+				Languages: test.languages,
+			}
+			audio := make([]byte, 16000*2/10)
+			transcript, err := recognizer.Recognize(context.Background(), audio, 16000)
+			if err != nil {
+				t.Fatalf("Recognize: %v", err)
+			}
+			if transcript.Language != test.want {
+				t.Errorf("got language %q, want %q", transcript.Language, test.want)
+			}
+		})
+	}
+}
+
+func TestFakeRecognizer(t *testing.T) {
+	recognizer, err := NewRecognizer(
+		SpeechToTextConfig{Engine: "fake", Args: []string{"first", "second"}},
+		LanguageConfig{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"first", "second"} {
+		transcript, err := recognizer.Recognize(context.Background(), []byte{1, 2}, 16000)
+		if err != nil || transcript.Text != want {
+			t.Errorf("got %q, %v, want %q", transcript.Text, err, want)
+		}
+	}
+	if _, err := recognizer.Recognize(context.Background(), nil, 16000); err != ErrNoSpeech {
+		t.Errorf("got %v once the transcripts ran out, want ErrNoSpeech", err)
+	}
+	if calls := recognizer.(*FakeRecognizer).Calls; len(calls) != 3 {
+		t.Errorf("recorded %d calls, want 3", len(calls))
+	}
+}
@@ -3,12 +3,16 @@ package core
 import (
 	"fmt"
 	"log"
+	"time"
 	"strings"
 	"os/exec"
 	"encoding/json"
 	"github.com/google/generative-ai-go/genai"
 )
 
+// How long to wait for the output of an interrupted command.
+const commandWaitDelay = time.Second
+
 // Method processes the JSON response generated by the AI system and takes 
 // actions based on the response type. It supports handling multiple branches 
 // in the execution flow.
@@ -22,6 +26,10 @@ func (kai *Kai) Respond(jsonStr string, branchCounts ...int) {
 	branchCount := 1
 	if len(branchCounts) > 0 {
 		branchCount = branchCounts[0]
+	} else {
+		// A top-level response starts a new turn, interrupting the last one
+		_, endTurn := kai.beginTurn()
+		defer endTurn()
 	}
 
 	// TODO: Testing
@@ -34,24 +42,108 @@ func (kai *Kai) Respond(jsonStr string, branchCounts ...int) {
 		log.Printf("Error processing response: %v", err)
 		return
 	}
-	// Iterate through the slice and process each item
+	// Iterate through the slice and dispatch each item to its handler
 	for _, item := range responseItems {
-		switch item.Type {
-		case "script":
-			processScript(kai, item.Data)
-		case "command":
-			// Prune branch if a command's output has fed back into the AI
-			if processCommand(kai, item.Data, branchCount) {
-				return
-			}
-		default:
-			fmt.Println("Unknown type:", item.Type)
+		// Drop the rest of an interrupted turn
+		if kai.interrupted() {
+			log.Println("Turn interrupted, dropping the rest of the response")
+			return
+		}
+		handler, exists := LookupHandler(item.Type)
+		if !exists {
+			log.Printf("Unknown response type: %s", item.Type)
+			kai.handleAIResponse(fmt.Sprintf(
+				"Response type '%s' is not supported. Supported types are %s. " +
+				"Please resend the response using supported types only.",
+				item.Type, supportedTypesList(),
+			), branchCount)
+			return
+		}
+		if err := handler.validate(item.Data); err != nil {
+			log.Printf("Invalid %s item: %v", item.Type, err)
+			kai.handleAIResponse(fmt.Sprintf(
+				"Invalid '%s' item: %v. " +
+				"Please resend the response with valid data.",
+				item.Type, err,
+			), branchCount)
+			return
+		}
+		// Refuse what the speaker's permission policy does not allow
+		if err := kai.checkPermission(item.Type); err != nil {
+			log.Printf("Refused %s item: %v", item.Type, err)
+			kai.handleAIResponse(fmt.Sprintf(
+				"The '%s' item was refused: %v. " +
+				"Tell the user, and do not try to work around it.",
+				item.Type, err,
+			), branchCount)
+			return
+		}
+		// Commands and file changes of a spoken request are approved aloud
+		if !kai.approveItemByVoice(item.Type, item.Data) {
+			kai.handleAIResponse(fmt.Sprintf(
+				"The user did not approve the '%s' item, so it was not run. " +
+				"Do not retry it; briefly ask how to proceed.",
+				item.Type,
+			), branchCount)
+			return
+		}
+		// Prune branch if the item's result has fed back into the AI
+		if handler.Execute(kai, item.Data, branchCount) {
+			return
 		}
 	}
 	// Save the conversation history after Kai responds
 	go kai.SaveHistory()
 }
 
+// Registers the built-in "script" and "command" response types.
+func init() {
+	RegisterHandler(ResponseHandler{
+		Type:        "script",
+		Description: "A message spoken to the user.",
+		Fields: []HandlerField{
+			{
+				Name:        "message",
+				Type:        "string",
+				Description: "The text to be spoken.",
+				Required:    true,
+			},
+			{
+				Name:        "role",
+				Type:        "string",
+				Description: "Either 'intro', 'body' or 'conclusion'. Sets " +
+					"the pace and pauses of the speech.",
+				Required:    true,
+			},
+			{
+				Name:        "ssml",
+				Type:        "string",
+				Description: "Optional SSML for the same message, wrapped in " +
+					"<speak>, for control over emphasis, pauses or " +
+					"pronunciation. The message is spoken if it is invalid.",
+			},
+		},
+		Execute: func(kai *Kai, data json.RawMessage, _ int) bool {
+			processScript(kai, data)
+			return false
+		},
+	})
+	RegisterHandler(ResponseHandler{
+		Type:        "command",
+		Description: "A shell command to execute. Its output or error " +
+			"will be sent back to you.",
+		Fields: []HandlerField{
+			{
+				Name:        "command",
+				Type:        "string",
+				Description: "The shell command to be executed.",
+				Required:    true,
+			},
+		},
+		Execute: processCommand,
+	})
+}
+
 // Method handles the processing of a "script" response item.
 //
 // Parameters:
@@ -62,6 +154,7 @@ func processScript(kai *Kai, data json.RawMessage) {
 	var scriptData struct {
 		Message string `json:"message"`
 		Role    string `json:"role"`
+		SSML    string `json:"ssml"`
 	}
 	err := json.Unmarshal(data, &scriptData)
 	if err != nil {
@@ -69,13 +162,8 @@ func processScript(kai *Kai, data json.RawMessage) {
 		return
 	}
 
-	// TODO: Print the role for context and debugging
-	// fmt.Printf("Speaking (%s): %s\n", scriptData.Role, scriptData.Message)
-
-	// Have Kai speak the script
-	if err := kai.Speak(scriptData.Message); err != nil {
-		log.Printf("Failed to speak: %v", err)
-	}
+	// Queue the script so the next items are handled while Kai speaks
+	kai.queueScript(scriptData.Message, scriptData.Role, scriptData.SSML)
 }
 
 // Method handles the processing of a "command" response item by executing 
@@ -213,15 +301,23 @@ func sanitizeAndUnmarshal(jsonStr string) ([]ResponseItem, error) {
 //  - message: The message to be sent back to the AI for further processing.
 //  - branchCount: The current branch count to manage recursion.
 func (kai *Kai) handleAIResponse(message string, branchCount int) {
+	// The user has moved on from an interrupted turn
+	if kai.interrupted() {
+		return
+	}
 	// Feed the message back into the system to generate a new response
 	if newResponse, err := kai.Reason(message); err != nil {
-		log.Fatalf("Failed to process new response: %v", err)
+		log.Printf("Failed to process new response: %v", err)
+		if err := kai.Speak("Sorry, I lost the connection to the AI service."); err != nil {
+			log.Printf("Failed to speak: %v", err)
+		}
 	} else {
 		kai.Respond(newResponse, branchCount + 1)
 	}
 }
 
-// Method executes a given shell command and returns the output.
+// Method executes a given shell command and returns the output. The
+// command is killed if the current turn is interrupted.
 //
 // Parameters:
 //  - command: The shell command to execute.
@@ -230,10 +326,14 @@ func (kai *Kai) handleAIResponse(message string, branchCount int) {
 //  - string: The output from the executed command.
 //  - error: Error encountered during command execution, if any.
 func (kai *Kai) executeCommand(command string) (string, error) {
-    cmd := exec.Command("sh", "-c", command)
+    cmd := exec.CommandContext(kai.currentTurn(), "sh", "-c", command)
+    // Stop waiting for output held open by the command's children
+    cmd.WaitDelay = commandWaitDelay
     output, err := cmd.CombinedOutput()
     if err != nil {
-        return "", fmt.Errorf("failed to execute command: %w", err)
+        // Keep the output, it usually explains the failure
+        return strings.TrimSpace(string(output)), 
+            fmt.Errorf("failed to execute command: %w", err)
     }
     return strings.TrimSpace(string(output)), nil
 }
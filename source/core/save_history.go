@@ -9,6 +9,13 @@ import (
 
 // Saves the chat history to a file.
 func (kai *Kai) SaveHistory() {
+    kai.historyMutex.Lock()
+    defer kai.historyMutex.Unlock()
+    kai.saveHistory()
+}
+
+// Saves the chat history like SaveHistory; historyMutex must be held.
+func (kai *Kai) saveHistory() {
     if kai.HistoryFile == "" {
         log.Println("No history file specified.")
         return
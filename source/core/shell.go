@@ -1,23 +1,29 @@
 package core
 
 import (
+	"io"
 	"os"
 	"fmt"
 	"log"
+	"sync"
 	"bufio"
+	"context"
 	"strings"
 )
 
-// Starts the event loop for shell-based interactions.
+// Starts the event loop for shell-based interactions. Clarifying questions
+// and plans are answered from the same terminal.
 func (kai *Kai) RunShell() {
 	// Ensure history is saved when the function exits
 	defer kai.SaveHistory()
-	// Create a new buffered reader for user input
-	reader := bufio.NewReader(os.Stdin)
+	input := terminal()
 	for {
 		// Prompt user for input
 		fmt.Print("Kai> ")
-		userInput, _ := reader.ReadString('\n')
+		userInput, err := input.readLine(kai.Context)
+		if err != nil && strings.TrimSpace(userInput) == "" {
+			return
+		}
 		userInput = strings.TrimSpace(userInput)
 		if userInput == "" {
 			continue
@@ -25,7 +31,8 @@ func (kai *Kai) RunShell() {
 		// Send the message and process the response
 		responseJSON, err := kai.Reason(userInput)
 		if err != nil {
-			log.Fatalf("Error sending message: %v", err)
+			log.Printf("Error sending message: %v", err)
+			continue
 		}
 		// Process the JSON response
 		kai.Respond(responseJSON)
@@ -34,4 +41,64 @@ func (kai *Kai) RunShell() {
 		fmt.Print(responseJSON)
 
 	}
-}
\ No newline at end of file
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// The terminal Kai reads from, shared by the shell and the prompts it
+// shows so that no typed line is lost between them.
+var terminal = sync.OnceValue(func() *consoleInput {
+	return newConsoleInput(os.Stdin)
+})
+
+// Reads lines in the background, so that waiting for a line can be
+// cancelled. A line typed after that goes to the next reader.
+type consoleInput struct {
+	lines chan consoleLine
+}
+
+// A line read from the terminal, or why reading stopped.
+type consoleLine struct {
+	text string
+	err  error
+}
+
+// Method starts reading lines from a reader.
+func newConsoleInput(reader io.Reader) *consoleInput {
+	input := &consoleInput{lines: make(chan consoleLine)}
+	go func() {
+		buffered := bufio.NewReader(reader)
+		for {
+			text, err := buffered.ReadString('\n')
+			input.lines <- consoleLine{text, err}
+			if err != nil {
+				close(input.lines)
+				return
+			}
+		}
+	}()
+	return input
+}
+
+// Method waits for the next line, including its newline.
+//
+// Parameters:
+//  - ctx: Ends the wait when cancelled.
+//
+// Returns:
+//  - string: The line.
+//  - error: The context's error if it was cancelled, io.EOF once the input
+//    has ended, or the error that ended it.
+func (input *consoleInput) readLine(ctx context.Context) (string, error) {
+	select {
+	case line, ok := <-input.lines:
+		if !ok {
+			return "", io.EOF
+		}
+		return line.text, line.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
@@ -1,147 +1,126 @@
 package core
 
 import (
-    "os"
 	"fmt"
-    "time"
-	"context"
-	"encoding/binary"
-	// Text-to-Speech
-	texttospeech "cloud.google.com/go/texttospeech/apiv1"
-	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
-    // Audio
-	"github.com/gordonklaus/portaudio"
+	"log"
+    "strings"
 )
 
-// Synthesizes speech from the input text and plays it.
-func (kai *Kai) Speak(text string) error {
-    ctx := context.Background()
-    client, err := texttospeech.NewClient(ctx)
-    if err != nil {
-        return fmt.Errorf("failed to create text-to-speech client: %v", err)
-    }
-    defer client.Close()
-    // Perform the text-to-speech request
-    req := &texttospeechpb.SynthesizeSpeechRequest{
-        Input: &texttospeechpb.SynthesisInput{
-            InputSource: &texttospeechpb.SynthesisInput_Text{
-                Text: text,
-            },
-        },
+// Default text-to-speech voices by language. Languages without a voice here
+// or in the configuration use the engine's default voice for the language.
+var defaultVoices = map[string]string{
+    "en-US": "en-US-Polyglot-1",
+    "es-US": "es-US-Polyglot-1",
+    "es-ES": "es-ES-Polyglot-1",
+}
 
-        // TODO: Pick voice
-        Voice: &texttospeechpb.VoiceSelectionParams{
-            LanguageCode: "en-US",
-            Name:         "en-US-Polyglot-1",
-            SsmlGender:   texttospeechpb.SsmlVoiceGender_MALE,
-        },
-        // Voice: &texttospeechpb.VoiceSelectionParams{
-        //     LanguageCode: "en-AU",
-        //     Name:         "en-AU-Wavenet-C",
-        //     SsmlGender:   texttospeechpb.SsmlVoiceGender_FEMALE,
-        // },        
+// Synthesizes speech from the input text and plays it in a voice matching 
+// the language the user last spoke in, after any speech already queued. 
+// Without a working audio device the text is written out instead.
+func (kai *Kai) Speak(text string) error {
+    return kai.queueSpeech(text, "", kai.voiceFor(kai.Language())).wait()
+}
 
-        AudioConfig: &texttospeechpb.AudioConfig{
-            AudioEncoding:    texttospeechpb.AudioEncoding_LINEAR16,
-            SpeakingRate:     1.0,
-            Pitch:            0.0,
-            SampleRateHertz:  int32(kai.SampleRate),
-        },
-    }
-    resp, err := client.SynthesizeSpeech(ctx, req)
-    if err != nil {
-        return fmt.Errorf("failed to synthesize speech: %v", err)
+// Method speaks SSML like Speak. Malformed SSML is logged and the plain 
+// text is spoken instead; engines without SSML support speak its text.
+//
+// Parameters:
+//  - ssml: The SSML document.
+//  - text: The plain text, shown when speech output is unavailable and 
+//          spoken when the SSML is malformed; derived from the SSML if empty.
+//
+// Returns:
+//  - error: Error encountered while speaking, if any.
+func (kai *Kai) SpeakSSML(ssml, text string) error {
+    if text == "" {
+        text = SSMLText(ssml)
     }
-    // Play the audio (you can use any audio playing library)
-    if err := playAudio(resp.AudioContent, kai.SampleRate); err != nil {
-        return fmt.Errorf("failed to play audio: %v", err)
+    if err := ValidateSSML(ssml); err != nil {
+        log.Printf("Speaking plain text instead of invalid SSML: %v", err)
+        return kai.Speak(text)
     }
-    return nil
+    return kai.queueSpeech(text, ssml, kai.voiceFor(kai.Language())).wait()
 }
 
-// Helper Method to play audio using PortAudio
-func playAudio(audioData []byte, sampleRate int) error {
-    // Initialize PortAudio
-    err := portaudio.Initialize()
-    if err != nil {
-        return fmt.Errorf("failed to initialize PortAudio: %v", err)
-    }
-    defer portaudio.Terminate()
-    // Define a buffer size and create a buffer
-    bufferSize := 1024
-    dataSize := len(audioData) / 2
-    currentIndex := 0
-    // Open a stream for audio playback
-    stream, err := portaudio.OpenDefaultStream(
-        0, 1, float64(sampleRate), bufferSize, 
-        func(out []int16) {
-            for i := range out {
-                if currentIndex < dataSize {
-                    out[i] = int16(audioData[2 * currentIndex]) | 
-                             int16(audioData[2 * currentIndex + 1]) << 8
-                    currentIndex++
-                } else {
-                    out[i] = 0 // Fill with silence if the data is finished
-                }
-            }
-        },
-    )
-    if err != nil {
-        return fmt.Errorf("failed to open stream: %v", err)
-    }
-    defer stream.Close()
-    // Start the audio stream
-    if err := stream.Start(); err != nil {
-        return fmt.Errorf("failed to start stream: %v", err)
+// Method queues a script message sentence by sentence without waiting for 
+// it, so the first sentence plays while the rest is synthesized and the 
+// next script items queue up behind it. SSML from the model is queued as 
+// a whole; invalid SSML is replaced by the message.
+//
+// Parameters:
+//  - message: The plain text of the script item.
+//  - role: The role of the item, selecting its prosody.
+//  - ssml: SSML for the message from the model, or an empty string.
+func (kai *Kai) queueScript(message, role, ssml string) {
+    voice := kai.voiceFor(kai.Language())
+    if ssml != "" {
+        if message == "" {
+            message = SSMLText(ssml)
+        }
+        err := ValidateSSML(ssml)
+        if err == nil {
+            kai.queueSpeech(message, ssml, voice)
+            return
+        }
+        log.Printf("Speaking plain text instead of invalid SSML: %v", err)
     }
-    // Wait for the audio to finish playing
-    for currentIndex < dataSize {
-        time.Sleep(10 * time.Millisecond)
+    for i, sentence := range splitSentences(message) {
+        kai.queueSpeech(sentence, sentenceSSML(sentence, role, i == 0), voice)
     }
-    // Stop the audio stream
-    if err := stream.Stop(); err != nil {
-        return fmt.Errorf("failed to stop stream: %v", err)
+}
+
+// Method speaks a sample text in a voice that is not saved yet, so the user 
+// can hear it before choosing it in the settings.
+func (kai *Kai) PreviewVoice(voice Voice, text string) error {
+    return kai.queueSpeech(text, "", voice).wait()
+}
+
+// Method lists the voices of the text-to-speech engine, optionally only 
+// those speaking the given language.
+func (kai *Kai) ListVoices(language string) ([]VoiceInfo, error) {
+    lister, ok := kai.Synthesizer.(VoiceLister)
+    if !ok {
+        return nil, fmt.Errorf("the text-to-speech engine cannot list voices")
     }
-    return nil
+    return lister.ListVoices(kai.Context, language)
 }
 
-// TODO: Move into utility methods
-// Method saves the recorded audio data to a WAV file.
-func saveToWavFile(filename string, audioData []byte) error {
-    file, err := os.Create(filename)
-    if err != nil {
-        return fmt.Errorf("failed to create file: %v", err)
+// Method selects the voice for a language with the speech parameters from 
+// the settings. The name is the voice configured for the language, the 
+// voice chosen in the settings if it speaks the language, or the default.
+func (kai *Kai) voiceFor(language string) Voice {
+    var config VoiceConfig
+    if kai.Config != nil {
+        config = kai.Config.Voice
     }
-    defer file.Close()
-    // WAV file header
-    var header = []byte{
-        'R', 'I', 'F', 'F',
-        0, 0, 0, 0, // ChunkSize (to be filled later)
-        'W', 'A', 'V', 'E',
-        'f', 'm', 't', ' ',
-        16, 0, 0, 0, // Subchunk1Size (16 for PCM)
-        1, 0, // AudioFormat (1 for PCM)
-        1, 0, // NumChannels (1 for mono)
-        0x44, 0xac, 0, 0, // SampleRate (44100 Hz)
-        0x88, 0x58, 1, 0, // ByteRate (SampleRate * NumChannels * BitsPerSample/8)
-        2, 0, // BlockAlign (NumChannels * BitsPerSample/8)
-        16, 0, // BitsPerSample (16 bits)
-        'd', 'a', 't', 'a',
-        0, 0, 0, 0, // Subchunk2Size (to be filled later)
+    voice := Voice{
+        Language:     language,
+        Gender:       config.Gender,
+        SpeakingRate: config.SpeakingRate,
+        Pitch:        config.Pitch,
+        VolumeGainDb: config.VolumeGainDb,
     }
-    // Fill in the ChunkSize and Subchunk2Size
-    chunkSize := 36 + len(audioData)
-    subchunk2Size := len(audioData)
-    binary.LittleEndian.PutUint32(header[4:], uint32(chunkSize))
-    binary.LittleEndian.PutUint32(header[40:], uint32(subchunk2Size))
-    // Write the header and audio data to the file
-    _, err = file.Write(header)
-    if err != nil {
-        return fmt.Errorf("failed to write header: %v", err)
+    if voice.Gender == "" {
+        voice.Gender = "male"
     }
-    _, err = file.Write(audioData)
-    if err != nil {
-        return fmt.Errorf("failed to write audio data: %v", err)
+    if name, ok := kai.languageConfig().Voices[language]; ok {
+        voice.Name = name
+    } else if chosen := VoiceLanguage(config.Name); chosen != "" &&
+        languageBase(chosen) == languageBase(language) {
+        // e.g. an "en-GB" voice chosen while speaking "en-US"
+        voice.Name, voice.Language = config.Name, chosen
+    } else {
+        voice.Name = defaultVoices[language]
     }
-    return nil
-}
\ No newline at end of file
+    return voice
+}
+
+// VoiceLanguage returns the language of a voice name, e.g. "es-US" for 
+// "es-US-Neural2-B", or an empty string if it does not start with one.
+func VoiceLanguage(name string) string {
+    parts := strings.SplitN(name, "-", 3)
+    if len(parts) < 3 || len(parts[0]) < 2 || len(parts[0]) > 3 {
+        return ""
+    }
+    return normalizeLanguage(parts[0] + "-" + parts[1])
+}
@@ -0,0 +1,571 @@
+package core
+
+import (
+	"os"
+	"fmt"
+	"log"
+	"math"
+	"time"
+	"regexp"
+	"slices"
+	"strings"
+	"encoding/hex"
+	"crypto/sha256"
+	"encoding/json"
+	"path/filepath"
+	// Local utilities
+	"kai/source/utils"
+)
+
+// Permission policies of speaker profiles.
+const (
+	PolicyFull   = "full"   // Everything the model asks for runs
+	PolicyReview = "review" // Changes only run as plans the user reviewed
+	PolicyChat   = "chat"   // Conversation and reading files only
+)
+
+// The permission policies, most permissive first.
+var SpeakerPolicies = []string{PolicyFull, PolicyReview, PolicyChat}
+
+// Policy of voices matching no profile if the configuration sets none.
+const defaultUnknownPolicy = PolicyReview
+
+// Response types that change the system, refused by restrictive policies.
+var changingItemTypes = map[string]bool{
+	"command": true, "plan": true, "tool": true,
+	"file_write": true, "file_append": true, "file_patch": true,
+}
+
+// Speaker identification defaults.
+const (
+	defaultSpeakersFile     = "data/speakers.json"
+	// Directory of the speakers' histories without a shared history
+	defaultHistoryDir       = "data"
+	defaultSpeakerThreshold = 0.93
+	// Cepstral coefficients summarized by an embedding
+	speakerCoefficients     = 19
+	// Shortest recording a voice is identified from
+	minSpeakerAudio         = time.Second
+)
+
+// Characters replaced in profile names to form file names.
+var unsafeNameRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+
+// SpeakerProfile is a user sharing the workstation, recognized by voice.
+// It selects who Kai talks to, what it remembers about them, their
+// conversation and what they may do.
+type SpeakerProfile struct {
+	Name        string    `json:"name"`
+	// Notes about the user passed to the model, e.g. their projects
+	Memory      string    `json:"memory,omitempty"`
+	// The user's conversation; "history-<name>.json" next to the shared
+	// history if empty
+	HistoryFile string    `json:"history_file,omitempty"`
+	// "full" (default), "review" or "chat"; unknown values act as "chat"
+	Policy      string    `json:"policy,omitempty"`
+	// Voice summary built from the enrollment recordings
+	Embedding   []float64 `json:"embedding"`
+}
+
+// Method loads the speaker profiles. A missing file holds no profiles.
+func LoadSpeakerProfiles(file string) ([]SpeakerProfile, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var profiles []SpeakerProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("invalid speaker profiles: %w", err)
+	}
+	return profiles, nil
+}
+
+// Method writes the speaker profiles, creating the directory if needed.
+func SaveSpeakerProfiles(file string, profiles []SpeakerProfile) error {
+	data, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0o600)
+}
+
+// Method summarizes a voice as the mean and spread of its cepstral
+// coefficients, liftered and scaled to unit length. Recordings of the same
+// voice give similar embeddings regardless of what was said.
+//
+// Parameters:
+//  - samples: The recorded speech.
+//  - sampleRate: The sample rate of the recording.
+//
+// Returns:
+//  - []float64: The embedding.
+//  - error: Error if the recording is too short.
+func SpeakerEmbedding(samples []int16, sampleRate int) ([]float64, error) {
+	if samplesDuration(len(samples), sampleRate) < minSpeakerAudio {
+		return nil, fmt.Errorf("recording too short to identify the speaker")
+	}
+	frames := utils.MFCC(samples, sampleRate, speakerCoefficients)
+	embedding := make([]float64, 2*speakerCoefficients)
+	for _, frame := range frames {
+		for k, value := range frame {
+			embedding[k] += value / float64(len(frames))
+		}
+	}
+	for _, frame := range frames {
+		for k, value := range frame {
+			deviation := value - embedding[k]
+			embedding[speakerCoefficients+k] += deviation * deviation / float64(len(frames))
+		}
+	}
+	for k := speakerCoefficients; k < len(embedding); k++ {
+		embedding[k] = math.Sqrt(embedding[k])
+	}
+	// Weigh the higher coefficients, which describe the vocal tract, as
+	// much as the lower ones, which every voice shares
+	for k := range embedding {
+		embedding[k] *= float64(k%speakerCoefficients + 1)
+	}
+	return normalizeEmbedding(embedding), nil
+}
+
+// Method returns the cosine similarity of two embeddings of unit length.
+func embeddingSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	similarity := 0.0
+	for i := range a {
+		similarity += a[i] * b[i]
+	}
+	return similarity
+}
+
+// Method scales an embedding to unit length.
+func normalizeEmbedding(embedding []float64) []float64 {
+	length := 0.0
+	for _, value := range embedding {
+		length += value * value
+	}
+	length = math.Sqrt(length)
+	if length == 0 {
+		return embedding
+	}
+	for i := range embedding {
+		embedding[i] /= length
+	}
+	return embedding
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Method reports whether speakers are identified by voice.
+func (kai *Kai) speakerIdentification() bool {
+	return kai.Config != nil && kai.Config.Speakers.Enabled
+}
+
+// Method returns the path of the speaker profiles.
+func (kai *Kai) speakersFile() string {
+	if kai.Config == nil || kai.Config.Speakers.ProfilesFile == "" {
+		return defaultSpeakersFile
+	}
+	return expandPath(kai.Config.Speakers.ProfilesFile)
+}
+
+// Method loads the speaker profiles, so they can be managed even while
+// identification is disabled.
+func (kai *Kai) loadSpeakers() {
+	profiles, err := LoadSpeakerProfiles(kai.speakersFile())
+	if err != nil {
+		log.Printf("Failed to load speaker profiles: %v", err)
+		return
+	}
+	for _, profile := range profiles {
+		if err := checkPolicy(profile.Policy); err != nil {
+			log.Printf("Speaker %s may only chat: %v", profile.Name, err)
+		}
+	}
+	kai.speakerMutex.Lock()
+	defer kai.speakerMutex.Unlock()
+	kai.speakers = profiles
+}
+
+// Method returns a copy of the enrolled speaker profiles.
+func (kai *Kai) SpeakerProfiles() []SpeakerProfile {
+	kai.speakerMutex.Lock()
+	defer kai.speakerMutex.Unlock()
+	return slices.Clone(kai.speakers)
+}
+
+// Method enrolls a user from a few recordings of their voice, replacing
+// the profile with the same name, and saves the profiles. Three sentences
+// of a few seconds each give a reliable embedding.
+//
+// Parameters:
+//  - profile: The user's name, memory, history file and policy.
+//  - recordings: PCM recordings of the user at Kai's sample rate.
+//
+// Returns:
+//  - error: Error if the policy is unknown, a recording is too short or
+//    saving fails.
+func (kai *Kai) EnrollSpeaker(profile SpeakerProfile, recordings [][]byte) error {
+	profile.Name = strings.TrimSpace(profile.Name)
+	if profile.Name == "" {
+		return fmt.Errorf("the speaker needs a name")
+	}
+	if err := checkPolicy(profile.Policy); err != nil {
+		return err
+	}
+	if len(recordings) == 0 {
+		return fmt.Errorf("no recordings of %s", profile.Name)
+	}
+	embedding := make([]float64, 2*speakerCoefficients)
+	for i, recording := range recordings {
+		audio, sampleRate := kai.preprocess(recording, kai.SampleRate)
+		sample, err := SpeakerEmbedding(utils.BytesToSamples(audio), sampleRate)
+		if err != nil {
+			return fmt.Errorf("recording %d: %w", i+1, err)
+		}
+		for k, value := range sample {
+			embedding[k] += value
+		}
+	}
+	profile.Embedding = normalizeEmbedding(embedding)
+	kai.speakerMutex.Lock()
+	defer kai.speakerMutex.Unlock()
+	profiles := slices.DeleteFunc(slices.Clone(kai.speakers), func(p SpeakerProfile) bool {
+		return strings.EqualFold(p.Name, profile.Name)
+	})
+	profiles = append(profiles, profile)
+	if err := SaveSpeakerProfiles(kai.speakersFile(), profiles); err != nil {
+		return fmt.Errorf("failed to save speaker profiles: %w", err)
+	}
+	kai.speakers = profiles
+	return nil
+}
+
+// Method removes a speaker profile and saves the profiles. The user's
+// conversation history is kept.
+func (kai *Kai) RemoveSpeaker(name string) error {
+	kai.speakerMutex.Lock()
+	defer kai.speakerMutex.Unlock()
+	profiles := slices.DeleteFunc(slices.Clone(kai.speakers), func(p SpeakerProfile) bool {
+		return strings.EqualFold(p.Name, strings.TrimSpace(name))
+	})
+	if err := SaveSpeakerProfiles(kai.speakersFile(), profiles); err != nil {
+		return fmt.Errorf("failed to save speaker profiles: %w", err)
+	}
+	kai.speakers = profiles
+	return nil
+}
+
+// Method identifies who spoke a request and selects their profile for the
+// next message to the model. Voices matching no profile closely enough
+// select no profile, i.e. the shared history and the unknown policy. A
+// recording too short to tell keeps the conversation with the current
+// speaker, but not their permissions.
+//
+// Parameters:
+//  - audioData: The preprocessed recording of the request.
+//  - sampleRate: Its sample rate.
+//
+// Returns:
+//  - string: The name of the speaker; empty if unknown.
+func (kai *Kai) identifySpeaker(audioData []byte, sampleRate int) string {
+	if !kai.speakerIdentification() {
+		return ""
+	}
+	profile, err := kai.matchSpeaker(audioData, sampleRate)
+	kai.speakerMutex.Lock()
+	defer kai.speakerMutex.Unlock()
+	if err == nil {
+		kai.speaker = profile
+	}
+	// Too short to tell, e.g. "yes"; keep talking to the current speaker
+	kai.speakerVerified = err == nil
+	if kai.speaker == nil {
+		return ""
+	}
+	return kai.speaker.Name
+}
+
+// Method finds the profile whose voice matches a recording best, without
+// selecting it.
+//
+// Parameters:
+//  - audioData: The preprocessed recording.
+//  - sampleRate: Its sample rate.
+//
+// Returns:
+//  - *SpeakerProfile: A copy of the matching profile; nil if the voice
+//    matches none closely enough.
+//  - error: Error if the recording is too short to tell.
+func (kai *Kai) matchSpeaker(audioData []byte, sampleRate int) (*SpeakerProfile, error) {
+	embedding, err := SpeakerEmbedding(utils.BytesToSamples(audioData), sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	threshold := kai.Config.Speakers.Threshold
+	if threshold == 0 {
+		threshold = defaultSpeakerThreshold
+	}
+	kai.speakerMutex.Lock()
+	defer kai.speakerMutex.Unlock()
+	var best *SpeakerProfile
+	bestSimilarity := threshold
+	for i := range kai.speakers {
+		similarity := embeddingSimilarity(embedding, kai.speakers[i].Embedding)
+		if similarity >= bestSimilarity {
+			best, bestSimilarity = &kai.speakers[i], similarity
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	profile := *best
+	return &profile, nil
+}
+
+// Whether an answer was spoken in the voice of the user who asked.
+type voiceMatch int
+
+const (
+	voiceMatches voiceMatch = iota
+	voiceDiffers
+	voiceUnclear // Too short to recognize, and the asker is known
+)
+
+// Method checks whether an answer was spoken by the user who asked, nil
+// standing for a voice that was not recognized. Like a request, an answer
+// too short to tell counts as an unrecognized voice: it matches an
+// unrecognized asker, and is unclear for a recognized one.
+//
+// Parameters:
+//  - asker: The verified profile of the user who asked.
+//  - audioData: The preprocessed answer.
+//  - sampleRate: Its sample rate.
+//
+// Returns:
+//  - voiceMatch: Whether the voices match.
+func (kai *Kai) answeredBy(asker *SpeakerProfile, audioData []byte, sampleRate int) voiceMatch {
+	if !kai.speakerIdentification() {
+		return voiceMatches
+	}
+	profile, err := kai.matchSpeaker(audioData, sampleRate)
+	switch {
+	case err != nil && asker == nil:
+		return voiceMatches
+	case err != nil:
+		return voiceUnclear
+	case sameSpeaker(profile, asker):
+		return voiceMatches
+	}
+	return voiceDiffers
+}
+
+// Method returns the profile whose permissions apply: the speaker of the
+// conversation if their voice was recognized in the latest request, nil
+// if it was not or was too short to tell.
+func (kai *Kai) verifiedSpeaker() *SpeakerProfile {
+	kai.speakerMutex.Lock()
+	defer kai.speakerMutex.Unlock()
+	if !kai.activeVerified {
+		return nil
+	}
+	return kai.activeSpeaker
+}
+
+// Method forgets the speaker identified by voice, e.g. before a typed
+// request: anyone at the keyboard may have typed it, so it uses the shared
+// history and the unknown policy.
+func (kai *Kai) ForgetSpeaker() {
+	kai.speakerMutex.Lock()
+	defer kai.speakerMutex.Unlock()
+	kai.speaker, kai.speakerVerified = nil, false
+}
+
+// Method collects the preprocessed chunks of a streamed request while
+// passing them on, and returns a function that identifies the speaker
+// once the chunks have all been passed on.
+func (kai *Kai) identifySpeakerStream(
+	chunks <-chan []byte,
+	sampleRate int,
+) (<-chan []byte, func() string) {
+	if !kai.speakerIdentification() {
+		return chunks, func() string { return "" }
+	}
+	forwarded := make(chan []byte, cap(chunks))
+	done := make(chan struct{})
+	var audioData []byte
+	go func() {
+		defer close(done)
+		defer close(forwarded)
+		for chunk := range chunks {
+			audioData = append(audioData, chunk...)
+			forwarded <- chunk
+		}
+	}()
+	return forwarded, func() string {
+		<-done
+		return kai.identifySpeaker(audioData, sampleRate)
+	}
+}
+
+// Method switches to the profile of the latest identified speaker before a
+// message is sent to the model: their conversation history is loaded, and
+// a note tells the model who is speaking, what it remembers about them and
+// what they may do. Returns an empty note if the speaker did not change.
+func (kai *Kai) speakerNote() string {
+	if !kai.speakerIdentification() {
+		return ""
+	}
+	kai.speakerMutex.Lock()
+	speaker := kai.speaker
+	changed := !sameSpeaker(speaker, kai.activeSpeaker)
+	kai.activeSpeaker, kai.activeVerified = speaker, kai.speakerVerified
+	kai.speakerMutex.Unlock()
+	if !changed {
+		return ""
+	}
+	// Keep each user's conversation in their own history
+	historyFile := kai.sharedHistoryFile
+	if speaker != nil {
+		historyFile = speaker.historyFile(kai.sharedHistoryFile)
+	}
+	if historyFile != kai.HistoryFile {
+		kai.switchHistory(historyFile)
+	}
+	if speaker == nil {
+		return "(The user speaking now was not recognized by voice.)"
+	}
+	note := fmt.Sprintf("(The user speaking now is %s.", speaker.Name)
+	if memory := strings.TrimSpace(speaker.Memory); memory != "" {
+		note += fmt.Sprintf(" What you know about them: %s", memory)
+	}
+	switch speaker.policy() {
+	case PolicyReview:
+		note += " Propose any change for them as a 'plan'; other " +
+			"commands, tools and file changes are refused."
+	case PolicyChat:
+		note += " Do not run commands or tools or change files for " +
+			"them; such items are refused."
+	}
+	return note + ")"
+}
+
+// Method saves the current conversation and loads the one in the given
+// history file, primed like a new conversation if it does not exist yet.
+func (kai *Kai) switchHistory(historyFile string) {
+	kai.historyMutex.Lock()
+	defer kai.historyMutex.Unlock()
+	kai.saveHistory()
+	kai.HistoryFile = historyFile
+	kai.PrimeAI(kai.primer, historyFile)
+	log.Printf("Switched to the conversation in %s", historyFile)
+}
+
+// Method checks whether the current speaker's policy allows a response
+// item of the given type. The unknown policy applies unless the speaker's
+// voice was recognized in the latest request.
+//
+// Returns:
+//  - error: Why the item is refused, or nil if it may run.
+func (kai *Kai) checkPermission(itemType string) error {
+	if !kai.speakerIdentification() || !changingItemTypes[itemType] {
+		return nil
+	}
+	speaker := kai.verifiedSpeaker()
+	name, policy := "the unrecognized user", kai.unknownPolicy()
+	if speaker != nil {
+		name, policy = speaker.Name, speaker.policy()
+	}
+	switch policy {
+	case PolicyFull:
+		return nil
+	case PolicyReview:
+		if itemType == "plan" {
+			return nil
+		}
+		return fmt.Errorf("%s may only make changes through a reviewed 'plan'", name)
+	}
+	return fmt.Errorf("%s may not run commands or tools or change files", name)
+}
+
+// Method returns the permission policy of voices matching no profile. An
+// unknown policy in the configuration acts as "chat".
+func (kai *Kai) unknownPolicy() string {
+	policy := kai.Config.Speakers.UnknownPolicy
+	if policy == "" {
+		return defaultUnknownPolicy
+	}
+	if checkPolicy(policy) != nil {
+		return PolicyChat
+	}
+	return policy
+}
+
+// Method returns the profile's history file, next to the shared history
+// unless the profile names one. Names without letters or digits to build a
+// file name from, e.g. in other scripts, are hashed.
+//
+// Parameters:
+//  - sharedHistoryFile: The history of users not recognized by voice.
+//
+// Returns:
+//  - string: The path of the profile's history file.
+func (profile *SpeakerProfile) historyFile(sharedHistoryFile string) string {
+	if profile.HistoryFile != "" {
+		return expandPath(profile.HistoryFile)
+	}
+	name := strings.Trim(unsafeNameRegexp.ReplaceAllString(
+		strings.ToLower(profile.Name), "-",
+	), "-")
+	if name == "" {
+		hash := sha256.Sum256([]byte(strings.ToLower(profile.Name)))
+		name = hex.EncodeToString(hash[:8])
+	}
+	directory := defaultHistoryDir
+	if sharedHistoryFile != "" {
+		directory = filepath.Dir(sharedHistoryFile)
+	}
+	return filepath.Join(directory, "history-"+name+".json")
+}
+
+// Method returns the profile's permission policy. An unknown policy acts as
+// "chat", so a typo never grants more than intended.
+func (profile *SpeakerProfile) policy() string {
+	if profile.Policy == "" {
+		return PolicyFull
+	}
+	if checkPolicy(profile.Policy) != nil {
+		return PolicyChat
+	}
+	return profile.Policy
+}
+
+// Method checks that a permission policy is known. An empty policy selects
+// the default.
+func checkPolicy(policy string) error {
+	if policy == "" || slices.Contains(SpeakerPolicies, policy) {
+		return nil
+	}
+	return fmt.Errorf(
+		"unknown permission policy %q, expected one of %s",
+		policy, strings.Join(SpeakerPolicies, ", "),
+	)
+}
+
+// Method reports whether two selected profiles are the same user.
+func sameSpeaker(a, b *SpeakerProfile) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return strings.EqualFold(a.Name, b.Name)
+}
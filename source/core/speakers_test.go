@@ -0,0 +1,136 @@
+package core
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"path/filepath"
+	// Local utilities
+	"kai/source/utils"
+)
+
+func TestCheckPermissionPolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		unknown  string
+		speaker  *SpeakerProfile
+		itemType string
+		allowed  bool
+	}{
+		{"unknown voice defaults to review", "", nil, "command", false},
+		{"unknown voice may send plans", "", nil, "plan", true},
+		{"unknown voice with full policy", PolicyFull, nil, "command", true},
+		{"misspelled unknown policy only chats", "Full", nil, "plan", false},
+		{"unknown voice may read files", "", nil, "file_read", true},
+		{"profile defaults to full", "", &SpeakerProfile{Name: "Ana"}, "command", true},
+		{"profile with review", "", &SpeakerProfile{Name: "Ana", Policy: PolicyReview}, "file_write", false},
+		{"profile with chat", PolicyFull, &SpeakerProfile{Name: "Ana", Policy: PolicyChat}, "plan", false},
+		{"misspelled profile policy only chats", "", &SpeakerProfile{Name: "Ana", Policy: "admin"}, "plan", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kai := &Kai{Config: &Config{Speakers: SpeakerConfig{
+				Enabled: true, UnknownPolicy: test.unknown,
+			}}}
+			kai.activeSpeaker, kai.activeVerified = test.speaker, true
+			err := kai.checkPermission(test.itemType)
+			if allowed := err == nil; allowed != test.allowed {
+				t.Errorf("got %v, want allowed %v", err, test.allowed)
+			}
+		})
+	}
+}
+
+func TestSpeakerPolicyValidation(t *testing.T) {
+	dir := t.TempDir()
+	kai := &Kai{Config: &Config{Speakers: SpeakerConfig{
+		ProfilesFile: filepath.Join(dir, "speakers.json"),
+	}}}
+	err := kai.EnrollSpeaker(SpeakerProfile{Name: "Ana", Policy: "admin"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "admin") {
+		t.Errorf("enrolling with an unknown policy: got %v", err)
+	}
+	config := filepath.Join(dir, "config.json")
+	data := `{"speakers": {"enabled": true, "unknown_policy": "ful"}}`
+	if err := os.WriteFile(config, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(config); err == nil {
+		t.Error("loading a configuration with an unknown policy succeeded")
+	}
+}
+
+func TestTypedRequestForgetsSpeaker(t *testing.T) {
+	kai := &Kai{Config: &Config{Speakers: SpeakerConfig{Enabled: true}}}
+	profile := &SpeakerProfile{Name: "Ana", Policy: PolicyFull}
+	kai.speaker, kai.activeSpeaker = profile, profile
+	kai.speakerVerified, kai.activeVerified = true, true
+	if err := kai.checkPermission("command"); err != nil {
+		t.Fatalf("identified speaker refused: %v", err)
+	}
+	kai.ForgetSpeaker()
+	if note := kai.speakerNote(); !strings.Contains(note, "not recognized") {
+		t.Errorf("got note %q after a typed request", note)
+	}
+	if err := kai.checkPermission("command"); err == nil {
+		t.Error("typed request kept the identified speaker's permissions")
+	}
+}
+
+func TestShortRequestKeepsOnlyConversation(t *testing.T) {
+	kai := &Kai{Config: &Config{Speakers: SpeakerConfig{Enabled: true}}}
+	profile := &SpeakerProfile{Name: "Ana", Policy: PolicyFull}
+	kai.speaker, kai.activeSpeaker = profile, profile
+	kai.speakerVerified, kai.activeVerified = true, true
+	// Half a second of "yes" is too short to tell who said it
+	short := utils.SamplesToBytes(toneSamples(220, 8000, 16000, 0))
+	if got := kai.identifySpeaker(short, 16000); got != "Ana" {
+		t.Errorf("conversation switched to %q after a short request", got)
+	}
+	if note := kai.speakerNote(); note != "" {
+		t.Errorf("got note %q, want the conversation to continue", note)
+	}
+	if err := kai.checkPermission("command"); err == nil {
+		t.Error("short request kept the identified speaker's permissions")
+	}
+}
+
+func TestSpeakerHistoryFile(t *testing.T) {
+	tests := []struct {
+		profile SpeakerProfile
+		shared  string
+		want    string
+	}{
+		{SpeakerProfile{Name: "Ana María"}, "data/history.json", "data/history-ana-mar-a.json"},
+		{SpeakerProfile{Name: "Ana"}, "/home/ana/kai/history.json", "/home/ana/kai/history-ana.json"},
+		{SpeakerProfile{Name: "Ana"}, "", "data/history-ana.json"},
+		{SpeakerProfile{Name: "Ana", HistoryFile: "ana.json"}, "data/history.json", "ana.json"},
+	}
+	for _, test := range tests {
+		if got := test.profile.historyFile(test.shared); got != filepath.FromSlash(test.want) {
+			t.Errorf("%q: got %q, want %q", test.profile.Name, got, test.want)
+		}
+	}
+	// Names without ASCII letters or digits get distinct hashed files
+	seen := map[string]bool{}
+	for _, name := range []string{"李明", "王芳", "Σοφία", "!!!"} {
+		file := (&SpeakerProfile{Name: name}).historyFile("data/history.json")
+		if strings.Contains(file, "history-.json") || seen[file] {
+			t.Errorf("%q: got %q", name, file)
+		}
+		seen[file] = true
+	}
+}
+
+func TestRemoveSpeakerIgnoresCase(t *testing.T) {
+	kai := &Kai{Config: &Config{Speakers: SpeakerConfig{
+		ProfilesFile: filepath.Join(t.TempDir(), "speakers.json"),
+	}}}
+	kai.speakers = []SpeakerProfile{{Name: "Ana"}, {Name: "Ben"}}
+	if err := kai.RemoveSpeaker("ana "); err != nil {
+		t.Fatal(err)
+	}
+	if profiles := kai.SpeakerProfiles(); len(profiles) != 1 || profiles[0].Name != "Ben" {
+		t.Errorf("got %+v, want only Ben", profiles)
+	}
+}
@@ -0,0 +1,241 @@
+package core
+
+import (
+	"log"
+	"sync"
+	"regexp"
+	"context"
+	"strings"
+	// Local utilities
+	"kai/source/utils"
+)
+
+// Number of utterances synthesized at the same time.
+const speechWorkers = 3
+
+// Capacity of the speech queue; queuing blocks when it is full.
+const speechQueueSize = 64
+
+// Sentences shorter than this are merged with the next one, so short
+// fragments such as "Done." do not cost a request of their own.
+const minSentenceLength = 16
+
+// Matches the end of a sentence: terminal punctuation, optionally closing
+// quotes or brackets, then whitespace.
+var sentenceEndRegexp = regexp.MustCompile(`[.!?…]+["'”’)\]]*\s+`)
+
+// Abbreviations whose period does not end a sentence.
+var abbreviations = map[string]bool{
+	"e.g.": true, "i.e.": true, "etc.": true, "vs.": true, "mr.": true,
+	"mrs.": true, "ms.": true, "dr.": true, "st.": true, "no.": true,
+	"approx.": true, "p.ej.": true, "sr.": true, "sra.": true,
+}
+
+// An utterance waiting in the speech queue. Its audio is synthesized by the
+// workers while earlier utterances play, and it is played in queue order.
+type utterance struct {
+	ctx      context.Context
+	text     string
+	ssml     string
+	voice    Voice
+	textOnly bool          // Write the text instead of synthesizing
+	audio    []byte
+	err      error
+	ready    chan struct{} // Closed once synthesized
+	done     chan struct{} // Closed once played or skipped
+}
+
+// Method waits until the utterance has been played and returns its error.
+// Speech cut short by StopSpeaking or Interrupt is not an error.
+func (u *utterance) wait() error {
+	<-u.done
+	if u.ctx.Err() != nil {
+		return nil
+	}
+	return u.err
+}
+
+// Orders speech: utterances are synthesized concurrently by a bounded pool
+// of workers and played back one after another in the order they were
+// queued, across script items.
+type speechQueue struct {
+	once       sync.Once
+	mutex      sync.Mutex
+	synthesis  chan *utterance
+	playback   chan *utterance
+	last       *utterance
+	// Context of the queued speech, its cancellation and the turn it
+	// belongs to
+	generation context.Context
+	cancel     context.CancelFunc
+	parent     context.Context
+}
+
+// Method queues speech without waiting for it to play. SSML is spoken if
+// given and the engine supports it, the text otherwise.
+//
+// Parameters:
+//  - text: The plain text, also written when speech output is unavailable.
+//  - ssml: The validated SSML document, or an empty string.
+//  - voice: The voice to speak in.
+//
+// Returns:
+//  - *utterance: The queued utterance, to wait for if needed.
+func (kai *Kai) queueSpeech(text, ssml string, voice Voice) *utterance {
+	queue := &kai.speech
+	queue.once.Do(func() {
+		queue.synthesis = make(chan *utterance, speechQueueSize)
+		queue.playback = make(chan *utterance, speechQueueSize)
+		for i := 0; i < speechWorkers; i++ {
+			go kai.synthesizeSpeech()
+		}
+		go kai.playSpeech()
+	})
+	u := &utterance{
+		ctx:      kai.speechContext(),
+		text:     text,
+		ssml:     ssml,
+		voice:    voice,
+		textOnly: kai.textOnlySpeech(),
+		ready:    make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	// Queue for synthesis and playback in the same order
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	queue.last = u
+	queue.synthesis <- u
+	queue.playback <- u
+	return u
+}
+
+// Method waits until the speech queued so far has played, or until the
+// context is cancelled.
+func (kai *Kai) waitForSpeech(ctx context.Context) {
+	kai.speech.mutex.Lock()
+	last := kai.speech.last
+	kai.speech.mutex.Unlock()
+	if last == nil {
+		return
+	}
+	select {
+	case <-last.done:
+	case <-ctx.Done():
+	}
+}
+
+// Method is a synthesis worker: it synthesizes queued utterances until the
+// program exits. Interrupted utterances are skipped.
+func (kai *Kai) synthesizeSpeech() {
+	for u := range kai.speech.synthesis {
+		if u.ctx.Err() == nil && u.textOnly {
+			// Phrases spoken before can still be played from the cache
+			if cache, ok := kai.Synthesizer.(*CachingSynthesizer); ok {
+				u.audio = cache.Lookup(u.text, u.ssml, u.voice, kai.SampleRate)
+			}
+		} else if u.ctx.Err() == nil {
+			if ssmlSynthesizer, ok := kai.Synthesizer.(SSMLSynthesizer); ok && u.ssml != "" {
+				u.audio, u.err = ssmlSynthesizer.SynthesizeSSML(
+					u.ctx, u.ssml, u.voice, kai.SampleRate,
+				)
+			} else {
+				u.audio, u.err = kai.Synthesizer.Synthesize(
+					u.ctx, u.text, u.voice, kai.SampleRate,
+				)
+			}
+		}
+		close(u.ready)
+	}
+}
+
+// Method plays synthesized utterances in queue order until the program
+// exits. When playback fails the text is written instead, from then on.
+func (kai *Kai) playSpeech() {
+	for u := range kai.speech.playback {
+		<-u.ready
+		switch {
+		case u.ctx.Err() != nil:
+			// Interrupted
+		case u.err != nil:
+			log.Printf("Failed to speak: %v", u.err)
+		case kai.speechOutputOff.Load() || (u.textOnly && len(u.audio) == 0):
+			kai.writeSpeech(u)
+		case len(u.audio) == 0:
+			// The synthesizer fell back to writing the text itself
+			if kai.OnSpeechText != nil {
+				kai.OnSpeechText(u.text)
+			}
+		default:
+			kai.speaking.Store(true)
+			err := kai.audioIO().Play(
+				u.ctx, utils.BytesToSamples(u.audio), kai.SampleRate,
+			)
+			kai.speaking.Store(false)
+			if err != nil && u.ctx.Err() == nil {
+				// Stop trying the audio device and fall back to text
+				log.Printf("Speech output disabled, writing text instead: %v", err)
+				kai.speechOutputOff.Store(true)
+				kai.writeSpeech(u)
+			}
+		}
+		// Release the audio of played utterances
+		u.audio = nil
+		close(u.done)
+	}
+}
+
+// Method writes an utterance as text instead of speaking it, on standard
+// output and in the UI if one is attached.
+func (kai *Kai) writeSpeech(u *utterance) {
+	(&TextSynthesizer{}).Synthesize(u.ctx, u.text, u.voice, 0)
+	if kai.OnSpeechText != nil {
+		kai.OnSpeechText(u.text)
+	}
+}
+
+// Method reports whether speech is currently written as text rather than
+// synthesized. Text is written by the player so it stays in order.
+func (kai *Kai) textOnlySpeech() bool {
+	return kai.speechOutputOff.Load() || textOnlySynthesizer(kai.Synthesizer)
+}
+
+// Method reports whether a synthesizer currently writes text rather than
+// producing audio.
+func textOnlySynthesizer(synthesizer Synthesizer) bool {
+	switch synthesizer := synthesizer.(type) {
+	case nil, *TextSynthesizer:
+		return true
+	case *FallbackSynthesizer:
+		return textOnlySynthesizer(synthesizer.Current())
+	case *CachingSynthesizer:
+		return textOnlySynthesizer(synthesizer.Synthesizer)
+	}
+	return false
+}
+
+// Method splits text into sentences for pipelined synthesis. Abbreviations
+// such as "e.g." do not end a sentence, and short sentences are merged with
+// the next one.
+func splitSentences(text string) []string {
+	var sentences []string
+	current := ""
+	last := 0
+	for _, match := range sentenceEndRegexp.FindAllStringIndex(text, -1) {
+		candidate := text[last:match[1]]
+		words := strings.Fields(candidate)
+		if len(words) > 0 && abbreviations[strings.ToLower(words[len(words)-1])] {
+			continue
+		}
+		current += candidate
+		last = match[1]
+		if len(strings.TrimSpace(current)) >= minSentenceLength {
+			sentences = append(sentences, strings.TrimSpace(current))
+			current = ""
+		}
+	}
+	current = strings.TrimSpace(current + text[last:])
+	if current != "" {
+		sentences = append(sentences, current)
+	}
+	return sentences
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"empty", "  ", nil},
+		{"single", "The build finished", []string{"The build finished"}},
+		{
+			"sentences",
+			"The build finished without errors. All 42 tests passed! Shall I deploy it?",
+			[]string{"The build finished without errors.", "All 42 tests passed!", "Shall I deploy it?"},
+		},
+		{
+			"abbreviations",
+			"Use a linter, e.g. golint, before you commit. Ask Dr. Smith etc. if unsure.",
+			[]string{"Use a linter, e.g. golint, before you commit.", "Ask Dr. Smith etc. if unsure."},
+		},
+		{
+			"decimals and versions",
+			"The disk has 3.5 GB free now. Go 1.21.4 is installed here.",
+			[]string{"The disk has 3.5 GB free now.", "Go 1.21.4 is installed here."},
+		},
+		{
+			"short sentences merged",
+			"Done. Yes. The file was saved to disk.",
+			[]string{"Done. Yes. The file was saved to disk."},
+		},
+		{
+			"closing quotes",
+			"He said \"run it now.\" Then it failed (again.) Sorry about that.",
+			[]string{"He said \"run it now.\"", "Then it failed (again.)", "Sorry about that."},
+		},
+		{
+			"ellipsis and no trailing space",
+			"Let me think about it… The answer is 7.",
+			[]string{"Let me think about it…", "The answer is 7."},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := splitSentences(test.text); !slices.Equal(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
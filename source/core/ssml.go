@@ -0,0 +1,269 @@
+package core
+
+import (
+	"io"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"encoding/xml"
+)
+
+// Prosody presets applied to script items by role.
+var rolePresets = map[string]struct {
+	Rate  string // Speaking rate, e.g. "108%"; unchanged if empty
+	Pause string // Pause before the item, e.g. "400ms"; none if empty
+}{
+	"intro":      {Rate: "108%"},
+	"body":       {},
+	"conclusion": {Rate: "96%", Pause: "400ms"},
+}
+
+// Elements accepted in SSML from the model; other elements are rejected so
+// a typo does not end up spoken or refused by the engine.
+var ssmlElements = map[string]bool{
+	"speak": true, "break": true, "say-as": true, "prosody": true,
+	"emphasis": true, "sub": true, "p": true, "s": true, "lang": true,
+	"phoneme": true, "mark": true,
+}
+
+// Patterns of the tokens marked up in plain script messages.
+var (
+	// Command names quoted with backticks, e.g. `grep`
+	ssmlCommandRegexp = regexp.MustCompile("`([^`\\s]+)`")
+	// Absolute, home and relative paths, e.g. ~/notes.txt or ./build
+	ssmlPathRegexp    = regexp.MustCompile(`(?:~|\.{1,2})?/[\w.\-/]*[\w\-]`)
+	// Numbers, not part of a version or an identifier, e.g. 42 or 3.5
+	ssmlNumberRegexp  = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	// Tags, for reading malformed SSML as text
+	ssmlTagRegexp     = regexp.MustCompile(`<[^>]*>`)
+)
+
+// Method converts a plain script message to SSML: the role's prosody preset
+// is applied, and paths, numbers and command names are marked up so they
+// are read out clearly.
+//
+// Parameters:
+//  - message: The plain text of the script item.
+//  - role: The role of the item: "intro", "body" or "conclusion".
+//
+// Returns:
+//  - string: The SSML document.
+func ScriptSSML(message, role string) string {
+	return sentenceSSML(message, role, true)
+}
+
+// Method converts one sentence of a script message to SSML like ScriptSSML.
+// The role's pause only precedes the first sentence.
+func sentenceSSML(sentence, role string, first bool) string {
+	body := markupTokens(sentence)
+	preset := rolePresets[role]
+	if preset.Rate != "" {
+		body = fmt.Sprintf(`<prosody rate="%s">%s</prosody>`, preset.Rate, body)
+	}
+	if preset.Pause != "" && first {
+		body = fmt.Sprintf(`<break time="%s"/>%s`, preset.Pause, body)
+	}
+	return "<speak>" + body + "</speak>"
+}
+
+// Method escapes a message for SSML and marks up its tokens. Short command
+// names such as `ls` are spelled with <say-as> and numbers are read as
+// cardinals. Paths are substituted by their components, e.g. "slash etc
+// slash hosts", since spelling them out character by character would be
+// unbearable.
+func markupTokens(message string) string {
+	var builder strings.Builder
+	last := 0
+	for _, match := range tokenMatches(message) {
+		start, end := match[0], match[1]
+		builder.WriteString(markupNumbers(message[last:start]))
+		token := message[start:end]
+		switch {
+		case strings.HasPrefix(token, "`"):
+			command := strings.Trim(token, "`")
+			if len(command) <= 3 {
+				fmt.Fprintf(&builder,
+					`<say-as interpret-as="characters">%s</say-as>`, escapeSSML(command),
+				)
+			} else {
+				builder.WriteString(escapeSSML(command))
+			}
+		default:
+			fmt.Fprintf(&builder,
+				`<sub alias="%s">%s</sub>`,
+				escapeSSML(spokenPath(token)), escapeSSML(token),
+			)
+		}
+		last = end
+	}
+	builder.WriteString(markupNumbers(message[last:]))
+	return builder.String()
+}
+
+// Method returns the positions of command names and paths in order, with
+// command names taking precedence where both match.
+func tokenMatches(message string) [][]int {
+	commands := ssmlCommandRegexp.FindAllStringIndex(message, -1)
+	var matches [][]int
+	for _, path := range ssmlPathRegexp.FindAllStringIndex(message, -1) {
+		inside := false
+		for _, command := range commands {
+			if path[0] < command[1] && command[0] < path[1] {
+				inside = true
+				break
+			}
+		}
+		// Skip slashes between words, e.g. "and/or"
+		if !inside && (path[0] == 0 || !isWordByte(message[path[0]-1])) {
+			matches = append(matches, path)
+		}
+	}
+	matches = append(matches, commands...)
+	// Sort by position; both lists are already sorted
+	for i := 1; i < len(matches); i++ {
+		for j := i; j > 0 && matches[j][0] < matches[j-1][0]; j-- {
+			matches[j], matches[j-1] = matches[j-1], matches[j]
+		}
+	}
+	return matches
+}
+
+// Method escapes plain text and reads its numbers as cardinals.
+func markupNumbers(text string) string {
+	var builder strings.Builder
+	last := 0
+	for _, match := range ssmlNumberRegexp.FindAllStringIndex(text, -1) {
+		// Leave versions such as 1.2.3 and identifiers such as x86 alone
+		if (match[0] > 0 && isWordByte(text[match[0]-1])) ||
+			(match[1] < len(text)-1 && text[match[1]] == '.' && isDigit(text[match[1]+1])) ||
+			(match[0] > 1 && text[match[0]-1] == '.' && isDigit(text[match[0]-2])) {
+			continue
+		}
+		builder.WriteString(escapeSSML(text[last:match[0]]))
+		fmt.Fprintf(&builder,
+			`<say-as interpret-as="cardinal">%s</say-as>`, text[match[0]:match[1]],
+		)
+		last = match[1]
+	}
+	builder.WriteString(escapeSSML(text[last:]))
+	return builder.String()
+}
+
+// Method returns how a path is read, e.g. "home slash notes dot txt".
+func spokenPath(path string) string {
+	replacer := strings.NewReplacer(
+		"~", " home ", "/", " slash ", ".", " dot ", "_", " underscore ", "-", " dash ",
+	)
+	return strings.Join(strings.Fields(replacer.Replace(path)), " ")
+}
+
+// Method validates SSML: it must be well formed, have a single <speak> root
+// and only use supported elements.
+//
+// Parameters:
+//  - ssml: The SSML document.
+//
+// Returns:
+//  - error: Description of the first problem found, if any.
+func ValidateSSML(ssml string) error {
+	decoder := xml.NewDecoder(strings.NewReader(ssml))
+	depth, roots := 0, 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("malformed SSML: %v", err)
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			name := element.Name.Local
+			if depth == 0 {
+				roots++
+				if name != "speak" || roots > 1 {
+					return fmt.Errorf("SSML must have a single <speak> root element")
+				}
+			}
+			if !ssmlElements[name] {
+				return fmt.Errorf("unsupported SSML element <%s>", name)
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 && strings.TrimSpace(string(element)) != "" {
+				return fmt.Errorf("text outside of the <speak> element")
+			}
+		}
+	}
+	if roots == 0 {
+		return fmt.Errorf("SSML must have a single <speak> root element")
+	}
+	return nil
+}
+
+// Method returns the text spoken by SSML, for engines without SSML support.
+// Substitutions are read by their alias; malformed SSML has its tags
+// stripped.
+func SSMLText(ssml string) string {
+	if ValidateSSML(ssml) != nil {
+		return stripSSML(ssml)
+	}
+	decoder := xml.NewDecoder(strings.NewReader(ssml))
+	var builder strings.Builder
+	alias := 0 // Depth inside a <sub> whose text is replaced by its alias
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			if alias > 0 {
+				alias++
+			} else if element.Name.Local == "sub" {
+				for _, attr := range element.Attr {
+					if attr.Name.Local == "alias" {
+						builder.WriteString(attr.Value)
+						alias = 1
+					}
+				}
+			}
+		case xml.EndElement:
+			if alias > 0 {
+				alias--
+			}
+		case xml.CharData:
+			if alias == 0 {
+				builder.Write(element)
+			}
+		}
+	}
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
+
+// Method returns the text of SSML as displayed, with all tags removed.
+func stripSSML(ssml string) string {
+	return strings.Join(strings.Fields(
+		html.UnescapeString(ssmlTagRegexp.ReplaceAllString(ssml, "")),
+	), " ")
+}
+
+// Method escapes text for use in SSML.
+func escapeSSML(text string) string {
+	var builder strings.Builder
+	xml.EscapeText(&builder, []byte(text))
+	return builder.String()
+}
+
+// Method reports whether a byte can be part of a word.
+func isWordByte(b byte) bool {
+	return b == '_' || isDigit(b) || (b|0x20 >= 'a' && b|0x20 <= 'z')
+}
+
+// Method reports whether a byte is an ASCII digit.
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
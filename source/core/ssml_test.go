@@ -0,0 +1,120 @@
+package core
+
+import (
+	"sync"
+	"context"
+	"testing"
+	"path/filepath"
+)
+
+// ssmlRecorder records what it is asked to speak, as SSML or as text.
+type ssmlRecorder struct {
+	mutex  sync.Mutex
+	spoken []string
+}
+
+func (r *ssmlRecorder) Synthesize(_ context.Context, text string, _ Voice, _ int) ([]byte, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.spoken = append(r.spoken, "text: "+text)
+	return nil, nil
+}
+
+func (r *ssmlRecorder) SynthesizeSSML(_ context.Context, ssml string, _ Voice, _ int) ([]byte, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.spoken = append(r.spoken, "ssml: "+ssml)
+	return nil, nil
+}
+
+func (r *ssmlRecorder) Close() error {
+	return nil
+}
+
+func TestScriptSSML(t *testing.T) {
+	tests := []struct {
+		message string
+		role    string
+		want    string
+	}{
+		{"Fish & chips <3", "body", "<speak>Fish &amp; chips &lt;<say-as interpret-as=\"cardinal\">3</say-as></speak>"},
+		{"Run `ls` or `grep`.", "body", "<speak>Run <say-as interpret-as=\"characters\">ls</say-as> or grep.</speak>"},
+		{"Open ~/notes_1.txt", "body", "<speak>Open <sub alias=\"home slash notes underscore 1 dot txt\">~/notes_1.txt</sub></speak>"},
+		{"Version 1.2.3 on x86, 42 files", "body", "<speak>Version 1.2.3 on x86, <say-as interpret-as=\"cardinal\">42</say-as> files</speak>"},
+		{"Read and/or write", "body", "<speak>Read and/or write</speak>"},
+		{"Hi", "intro", "<speak><prosody rate=\"108%\">Hi</prosody></speak>"},
+		{"Bye", "conclusion", "<speak><break time=\"400ms\"/><prosody rate=\"96%\">Bye</prosody></speak>"},
+	}
+	for _, test := range tests {
+		got := ScriptSSML(test.message, test.role)
+		if got != test.want {
+			t.Errorf("ScriptSSML(%q, %q) = %q, want %q", test.message, test.role, got, test.want)
+		}
+		if err := ValidateSSML(got); err != nil {
+			t.Errorf("ScriptSSML(%q, %q) is invalid: %v", test.message, test.role, err)
+		}
+	}
+}
+
+func TestSSMLText(t *testing.T) {
+	tests := []struct {
+		name  string
+		ssml  string
+		valid bool
+		want  string
+	}{
+		{"plain", "<speak>Hello <break time=\"1s\"/>world</speak>", true, "Hello world"},
+		{"escaped", "<speak>Fish &amp; chips</speak>", true, "Fish & chips"},
+		{"alias", "<speak>Open <sub alias=\"slash tmp\">/tmp</sub></speak>", true, "Open slash tmp"},
+		{"malformed", "<speak>Fish & chips</speak>", false, "Fish & chips"},
+		{"unclosed", "<speak><prosody rate=\"90%\">Slow</speak>", false, "Slow"},
+		{"unsupported element", "<speak><audio src=\"x\"/>Hi</speak>", false, "Hi"},
+		{"no root", "Hi <break/>", false, "Hi"},
+		{"text outside", "<speak>Hi</speak> there", false, "Hi there"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := ValidateSSML(test.ssml); (err == nil) != test.valid {
+				t.Errorf("ValidateSSML: got %v, want valid %v", err, test.valid)
+			}
+			if got := SSMLText(test.ssml); got != test.want {
+				t.Errorf("SSMLText: got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSpeakSSMLFallsBackToText(t *testing.T) {
+	tests := []struct {
+		name string
+		ssml string
+		text string
+		want string
+	}{
+		{"valid", "<speak>Hi <break/>there</speak>", "", "ssml: <speak>Hi <break/>there</speak>"},
+		{"invalid", "<speak>Fish & chips</speak>", "", "text: Fish & chips"},
+		{"invalid with text", "<speak><wrong/>Hi</speak>", "Hello", "text: Hello"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			audio, err := NewFileAudioIO(nil, filepath.Join(t.TempDir(), "out"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorder := &ssmlRecorder{}
+			kai := &Kai{
+				Context:     context.Background(),
+				Config:      &Config{},
+				SampleRate:  16000,
+				Audio:       audio,
+				Synthesizer: recorder,
+			}
+			if err := kai.SpeakSSML(test.ssml, test.text); err != nil {
+				t.Fatal(err)
+			}
+			if len(recorder.spoken) != 1 || recorder.spoken[0] != test.want {
+				t.Errorf("spoke %q, want %q", recorder.spoken, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,427 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+	"bytes"
+	"errors"
+	"context"
+	"strings"
+	// Text-to-Speech
+	texttospeech "cloud.google.com/go/texttospeech/apiv1"
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+	// gRPC status codes
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	// Local utilities
+	"kai/source/utils"
+)
+
+// Marks failures of a text-to-speech engine that retrying will not fix,
+// e.g. missing credentials or a missing binary.
+var errEngineUnavailable = errors.New("text-to-speech engine unavailable")
+
+// How long an engine that failed for another reason is skipped.
+const engineRetryDelay = time.Minute
+
+// Voice selects how synthesized speech sounds.
+type Voice struct {
+	// BCP-47 code of the language spoken
+	Language     string
+	// Engine specific voice name; the engine's default if empty
+	Name         string
+	// "male", "female" or "neutral"; a preference when no name is given
+	Gender       string
+	// Speed, 1 being normal
+	SpeakingRate float64
+	// Pitch shift in semitones
+	Pitch        float64
+	// Volume change in dB
+	VolumeGainDb float64
+}
+
+// VoiceInfo describes a voice offered by a synthesizer.
+type VoiceInfo struct {
+	Name       string
+	Languages  []string
+	Gender     string
+	SampleRate int
+}
+
+// VoiceLister is implemented by synthesizers that can list their voices.
+type VoiceLister interface {
+	ListVoices(ctx context.Context, language string) ([]VoiceInfo, error)
+}
+
+// Google voice genders by the names used in the configuration.
+var ssmlGenders = map[string]texttospeechpb.SsmlVoiceGender{
+	"male":    texttospeechpb.SsmlVoiceGender_MALE,
+	"female":  texttospeechpb.SsmlVoiceGender_FEMALE,
+	"neutral": texttospeechpb.SsmlVoiceGender_NEUTRAL,
+}
+
+// Synthesizer converts text to 16-bit mono PCM audio at the requested
+// sample rate. Engines that do not produce audio return no data and present
+// the text some other way.
+type Synthesizer interface {
+	Synthesize(ctx context.Context, text string, voice Voice, sampleRate int) ([]byte, error)
+	Close() error
+}
+
+// SSMLSynthesizer is implemented by synthesizers that accept SSML markup.
+// The SSML has been validated with ValidateSSML.
+type SSMLSynthesizer interface {
+	SynthesizeSSML(ctx context.Context, ssml string, voice Voice, sampleRate int) ([]byte, error)
+}
+
+// Method creates the synthesizer selected in the configuration. Every
+// engine falls back to text output when it fails; the automatic engine
+// tries Google Cloud, then piper and espeak-ng if installed.
+//
+// Parameters:
+//  - config: The text-to-speech configuration.
+//
+// Returns:
+//  - Synthesizer: The selected synthesizer.
+//  - error: Error if the engine is unknown or misconfigured.
+func NewSynthesizer(config TextToSpeechConfig) (Synthesizer, error) {
+	var engines []Synthesizer
+	switch config.Engine {
+	case "", "auto":
+		engines = append(engines, &GoogleSynthesizer{})
+		for _, engine := range []string{"piper", "espeak"} {
+			offline := config
+			offline.Engine = engine
+			if synthesizer, err := NewOfflineSynthesizer(offline); err == nil {
+				engines = append(engines, synthesizer)
+			}
+		}
+	case "google":
+		engines = append(engines, &GoogleSynthesizer{})
+	case "piper", "espeak":
+		synthesizer, err := NewOfflineSynthesizer(config)
+		if err != nil {
+			return nil, err
+		}
+		engines = append(engines, synthesizer)
+	case "text":
+		return &TextSynthesizer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown text-to-speech engine %q", config.Engine)
+	}
+	return &FallbackSynthesizer{
+		Engines: append(engines, &TextSynthesizer{}),
+	}, nil
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// FallbackSynthesizer uses the first engine that works. An engine that is
+// unavailable, e.g. for missing credentials, is skipped from then on, so the
+// failure is reported once instead of on every message. An engine that
+// fails for another reason, e.g. a network error, is skipped for a while
+// and then tried again. It is safe for concurrent use.
+type FallbackSynthesizer struct {
+	Engines     []Synthesizer
+	mutex       sync.Mutex
+	unavailable map[int]bool      // Engines skipped from now on, by index
+	retryAt     map[int]time.Time // When failed engines are tried again
+}
+
+// Method synthesizes the text with the current engine, moving on to the
+// next engine on failure.
+func (s *FallbackSynthesizer) Synthesize(
+	ctx context.Context,
+	text string,
+	voice Voice,
+	sampleRate int,
+) ([]byte, error) {
+	audio, _, err := s.synthesizeText(ctx, text, voice, sampleRate)
+	return audio, err
+}
+
+// Method synthesizes the text like Synthesize, and also returns the engine
+// that produced the audio.
+func (s *FallbackSynthesizer) synthesizeText(
+	ctx context.Context,
+	text string,
+	voice Voice,
+	sampleRate int,
+) ([]byte, Synthesizer, error) {
+	return s.run(ctx, func(engine Synthesizer) ([]byte, error) {
+		return engine.Synthesize(ctx, text, voice, sampleRate)
+	})
+}
+
+// Method synthesizes SSML with the current engine. Engines without SSML
+// support speak its text, and an engine that rejects the markup gets a
+// second chance with the text before it is skipped.
+func (s *FallbackSynthesizer) SynthesizeSSML(
+	ctx context.Context,
+	ssml string,
+	voice Voice,
+	sampleRate int,
+) ([]byte, error) {
+	audio, _, err := s.synthesizeSSML(ctx, ssml, voice, sampleRate)
+	return audio, err
+}
+
+// Method synthesizes SSML like SynthesizeSSML, and also returns the engine
+// that produced the audio.
+func (s *FallbackSynthesizer) synthesizeSSML(
+	ctx context.Context,
+	ssml string,
+	voice Voice,
+	sampleRate int,
+) ([]byte, Synthesizer, error) {
+	return s.run(ctx, func(engine Synthesizer) ([]byte, error) {
+		if ssmlEngine, ok := engine.(SSMLSynthesizer); ok {
+			audio, err := ssmlEngine.SynthesizeSSML(ctx, ssml, voice, sampleRate)
+			if err == nil || ctx.Err() != nil {
+				return audio, err
+			}
+			log.Printf("SSML rejected by %T, speaking plain text: %v", engine, err)
+		}
+		return engine.Synthesize(ctx, SSMLText(ssml), voice, sampleRate)
+	})
+}
+
+// Method runs a synthesis with the current engine, moving on to the next
+// engine on failure. The last engine's error is returned as is, along with
+// the engine that ran last.
+func (s *FallbackSynthesizer) run(
+	ctx context.Context,
+	synthesize func(engine Synthesizer) ([]byte, error),
+) ([]byte, Synthesizer, error) {
+	if len(s.Engines) == 0 {
+		return nil, nil, fmt.Errorf("no text-to-speech engine available")
+	}
+	for index := s.next(0); ; index = s.next(index + 1) {
+		engine := s.Engines[index]
+		audio, err := synthesize(engine)
+		if err == nil || ctx.Err() != nil || index == len(s.Engines)-1 {
+			return audio, engine, err
+		}
+		s.skip(index, err)
+	}
+}
+
+// Method returns the index of the first engine to try from the given
+// index on. The last engine is never skipped.
+func (s *FallbackSynthesizer) next(index int) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	for index < len(s.Engines)-1 && (s.unavailable[index] || now.Before(s.retryAt[index])) {
+		index++
+	}
+	return index
+}
+
+// Method skips a failed engine: from now on if it is unavailable, and
+// until it is due to be retried otherwise.
+func (s *FallbackSynthesizer) skip(index int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	engine := s.Engines[index]
+	if errors.Is(err, errEngineUnavailable) {
+		// Concurrent syntheses may fail on the same engine; report it once
+		if !s.unavailable[index] {
+			log.Printf("Text-to-speech engine %T failed, falling back: %v", engine, err)
+			if s.unavailable == nil {
+				s.unavailable = map[int]bool{}
+			}
+			s.unavailable[index] = true
+		}
+		return
+	}
+	log.Printf(
+		"Text-to-speech engine %T failed, falling back for %v: %v",
+		engine, engineRetryDelay, err,
+	)
+	if s.retryAt == nil {
+		s.retryAt = map[int]time.Time{}
+	}
+	s.retryAt[index] = time.Now().Add(engineRetryDelay)
+}
+
+// Method returns the engine the next synthesis is tried with first.
+func (s *FallbackSynthesizer) Current() Synthesizer {
+	return s.Engines[s.next(0)]
+}
+
+// Method lists the voices of the first engine that can list them.
+func (s *FallbackSynthesizer) ListVoices(
+	ctx context.Context,
+	language string,
+) ([]VoiceInfo, error) {
+	err := fmt.Errorf("no text-to-speech engine can list voices")
+	for _, engine := range s.Engines {
+		if lister, ok := engine.(VoiceLister); ok {
+			var voices []VoiceInfo
+			if voices, err = lister.ListVoices(ctx, language); err == nil {
+				return voices, nil
+			}
+		}
+	}
+	return nil, err
+}
+
+// Method closes all engines.
+func (s *FallbackSynthesizer) Close() error {
+	var firstErr error
+	for _, engine := range s.Engines {
+		if err := engine.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// GoogleSynthesizer uses the Google Cloud Text-to-Speech API. The client is
+// created on first use and reused afterwards.
+type GoogleSynthesizer struct {
+	mutex  sync.Mutex
+	client *texttospeech.Client
+}
+
+// Method synthesizes the text with the Google Cloud Text-to-Speech API.
+func (s *GoogleSynthesizer) Synthesize(
+	ctx context.Context,
+	text string,
+	voice Voice,
+	sampleRate int,
+) ([]byte, error) {
+	return s.synthesize(ctx, &texttospeechpb.SynthesisInput{
+		InputSource: &texttospeechpb.SynthesisInput_Text{Text: text},
+	}, voice, sampleRate)
+}
+
+// Method synthesizes SSML with the Google Cloud Text-to-Speech API.
+func (s *GoogleSynthesizer) SynthesizeSSML(
+	ctx context.Context,
+	ssml string,
+	voice Voice,
+	sampleRate int,
+) ([]byte, error) {
+	return s.synthesize(ctx, &texttospeechpb.SynthesisInput{
+		InputSource: &texttospeechpb.SynthesisInput_Ssml{Ssml: ssml},
+	}, voice, sampleRate)
+}
+
+// Method sends a synthesis request for text or SSML input.
+func (s *GoogleSynthesizer) synthesize(
+	ctx context.Context,
+	input *texttospeechpb.SynthesisInput,
+	voice Voice,
+	sampleRate int,
+) ([]byte, error) {
+	client, err := s.ttsClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Perform the text-to-speech request
+	req := &texttospeechpb.SynthesizeSpeechRequest{
+		Input: input,
+		Voice: &texttospeechpb.VoiceSelectionParams{
+			LanguageCode: voice.Language,
+			Name:         voice.Name,
+			SsmlGender:   ssmlGenders[voice.Gender],
+		},
+		AudioConfig: &texttospeechpb.AudioConfig{
+			AudioEncoding:   texttospeechpb.AudioEncoding_LINEAR16,
+			SpeakingRate:    voice.SpeakingRate,
+			Pitch:           voice.Pitch,
+			VolumeGainDb:    voice.VolumeGainDb,
+			SampleRateHertz: int32(sampleRate),
+		},
+	}
+	resp, err := client.SynthesizeSpeech(ctx, req)
+	if err != nil {
+		// Rejected credentials are not accepted on the next attempt either
+		switch status.Code(err) {
+		case codes.Unauthenticated, codes.PermissionDenied:
+			return nil, fmt.Errorf("%w: %v", errEngineUnavailable, err)
+		}
+		return nil, fmt.Errorf("failed to synthesize speech: %v", err)
+	}
+	// LINEAR16 audio comes with a WAV header, which would play as a click
+	if bytes.HasPrefix(resp.AudioContent, []byte("RIFF")) {
+		samples, _, err := utils.DecodeWav(resp.AudioContent)
+		if err != nil {
+			return nil, err
+		}
+		return utils.SamplesToBytes(samples), nil
+	}
+	return resp.AudioContent, nil
+}
+
+// Method lists the voices offered by the Google Cloud Text-to-Speech API,
+// optionally only those speaking the given language.
+func (s *GoogleSynthesizer) ListVoices(
+	ctx context.Context,
+	language string,
+) ([]VoiceInfo, error) {
+	client, err := s.ttsClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.ListVoices(ctx, &texttospeechpb.ListVoicesRequest{
+		LanguageCode: language,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list voices: %v", err)
+	}
+	voices := make([]VoiceInfo, 0, len(resp.Voices))
+	for _, voice := range resp.Voices {
+		voices = append(voices, VoiceInfo{
+			Name:       voice.Name,
+			Languages:  voice.LanguageCodes,
+			Gender:     strings.ToLower(voice.SsmlGender.String()),
+			SampleRate: int(voice.NaturalSampleRateHertz),
+		})
+	}
+	sort.Slice(voices, func(i, j int) bool {
+		return voices[i].Name < voices[j].Name
+	})
+	return voices, nil
+}
+
+// Method closes the text-to-speech client if it was created.
+func (s *GoogleSynthesizer) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.client == nil {
+		return nil
+	}
+	err := s.client.Close()
+	s.client = nil
+	return err
+}
+
+// Method returns the shared text-to-speech client, creating it if needed.
+func (s *GoogleSynthesizer) ttsClient(
+	ctx context.Context,
+) (*texttospeech.Client, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.client == nil {
+		client, err := texttospeech.NewClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: failed to create text-to-speech client: %v", errEngineUnavailable, err,
+			)
+		}
+		s.client = client
+	}
+	return s.client, nil
+}
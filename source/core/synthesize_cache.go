@@ -0,0 +1,337 @@
+package core
+
+import (
+	"os"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+	"context"
+	"strings"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"container/list"
+)
+
+// Directory of the speech cache when none is configured.
+const defaultSpeechCacheDir = "data/tts_cache"
+
+// Size cap of the speech cache when none is configured, in megabytes.
+const defaultSpeechCacheSizeMB = 64
+
+// Extension of cached audio files, which hold raw 16-bit mono PCM.
+const speechCacheExtension = ".pcm"
+
+// CachingSynthesizer keeps synthesized audio on disk so phrases Kai says
+// again and again, such as greetings and closing questions, play instantly
+// and without a network round trip. Files are named by a hash of the input,
+// the voice parameters and the engine that produced the audio, and the
+// least recently used ones are removed when the cache outgrows its size
+// cap. It is safe for concurrent use.
+type CachingSynthesizer struct {
+	Synthesizer Synthesizer
+	dir         string
+	maxBytes    int64
+	mutex       sync.Mutex
+	size        int64
+	order       *list.List               // Most recently used first
+	entries     map[string]*list.Element // Cache entries by key
+}
+
+// An audio file in the speech cache.
+type speechCacheEntry struct {
+	key  string
+	size int64
+}
+
+// Method wraps a synthesizer with the speech cache, indexing the files
+// already in the cache directory by their modification time.
+//
+// Parameters:
+//  - synthesizer: The synthesizer producing uncached audio.
+//  - config: The text-to-speech configuration, with the cache settings.
+//
+// Returns:
+//  - Synthesizer: The caching synthesizer, or the synthesizer itself if
+//    the cache is disabled.
+//  - error: Error if the cache directory cannot be created or read.
+func NewCachingSynthesizer(
+	synthesizer Synthesizer,
+	config TextToSpeechConfig,
+) (Synthesizer, error) {
+	if config.CacheSizeMB < 0 {
+		return synthesizer, nil
+	}
+	sizeMB := config.CacheSizeMB
+	if sizeMB == 0 {
+		sizeMB = defaultSpeechCacheSizeMB
+	}
+	dir := config.CacheDir
+	if dir == "" {
+		dir = defaultSpeechCacheDir
+	}
+	dir = expandPath(dir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return synthesizer, fmt.Errorf("failed to create speech cache: %w", err)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return synthesizer, fmt.Errorf("failed to read speech cache: %w", err)
+	}
+	cache := &CachingSynthesizer{
+		Synthesizer: synthesizer,
+		dir:         dir,
+		maxBytes:    int64(sizeMB) << 20,
+		order:       list.New(),
+		entries:     make(map[string]*list.Element),
+	}
+	// Index the existing files, most recently used first
+	var infos []os.FileInfo
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), speechCacheExtension) {
+			continue
+		}
+		if info, err := file.Info(); err == nil && info.Mode().IsRegular() {
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().After(infos[j].ModTime())
+	})
+	for _, info := range infos {
+		key := strings.TrimSuffix(info.Name(), speechCacheExtension)
+		entry := &speechCacheEntry{key: key, size: info.Size()}
+		cache.entries[key] = cache.order.PushBack(entry)
+		cache.size += entry.size
+	}
+	cache.evict()
+	return cache, nil
+}
+
+// Method returns cached audio for the text, or synthesizes and caches it.
+func (c *CachingSynthesizer) Synthesize(
+	ctx context.Context,
+	text string,
+	voice Voice,
+	sampleRate int,
+) ([]byte, error) {
+	return c.cached("text", text, voice, sampleRate, func() ([]byte, Synthesizer, error) {
+		if fallback, ok := c.Synthesizer.(*FallbackSynthesizer); ok {
+			return fallback.synthesizeText(ctx, text, voice, sampleRate)
+		}
+		audio, err := c.Synthesizer.Synthesize(ctx, text, voice, sampleRate)
+		return audio, c.Synthesizer, err
+	})
+}
+
+// Method returns cached audio for the SSML, or synthesizes and caches it.
+// Engines without SSML support speak its text.
+func (c *CachingSynthesizer) SynthesizeSSML(
+	ctx context.Context,
+	ssml string,
+	voice Voice,
+	sampleRate int,
+) ([]byte, error) {
+	ssmlSynthesizer, ok := c.Synthesizer.(SSMLSynthesizer)
+	if !ok {
+		return c.Synthesize(ctx, SSMLText(ssml), voice, sampleRate)
+	}
+	return c.cached("ssml", ssml, voice, sampleRate, func() ([]byte, Synthesizer, error) {
+		if fallback, ok := c.Synthesizer.(*FallbackSynthesizer); ok {
+			return fallback.synthesizeSSML(ctx, ssml, voice, sampleRate)
+		}
+		audio, err := ssmlSynthesizer.SynthesizeSSML(ctx, ssml, voice, sampleRate)
+		return audio, c.Synthesizer, err
+	})
+}
+
+// Method returns the cached audio for text or SSML without synthesizing,
+// e.g. to speak a known phrase while no engine produces audio. Audio of
+// any engine will do.
+//
+// Parameters:
+//  - text: The plain text.
+//  - ssml: The SSML document, looked up instead of the text if given.
+//  - voice: The voice the audio was synthesized in.
+//  - sampleRate: The sample rate of the audio.
+//
+// Returns:
+//  - []byte: The cached audio, or nil if it is not cached.
+func (c *CachingSynthesizer) Lookup(text, ssml string, voice Voice, sampleRate int) []byte {
+	kind, input := "text", text
+	if ssml != "" {
+		kind, input = "ssml", ssml
+		if _, ok := c.Synthesizer.(SSMLSynthesizer); !ok {
+			kind, input = "text", SSMLText(ssml)
+		}
+	}
+	engines := []Synthesizer{c.Synthesizer}
+	if fallback, ok := c.Synthesizer.(*FallbackSynthesizer); ok {
+		engines = fallback.Engines
+	}
+	for _, engine := range engines {
+		if audio := c.load(speechCacheKey(kind, engine, input, voice, sampleRate)); audio != nil {
+			return audio
+		}
+	}
+	return nil
+}
+
+// Method lists the voices of the wrapped synthesizer.
+func (c *CachingSynthesizer) ListVoices(
+	ctx context.Context,
+	language string,
+) ([]VoiceInfo, error) {
+	lister, ok := c.Synthesizer.(VoiceLister)
+	if !ok {
+		return nil, fmt.Errorf("the text-to-speech engine cannot list voices")
+	}
+	return lister.ListVoices(ctx, language)
+}
+
+// Method closes the wrapped synthesizer; cached files are kept.
+func (c *CachingSynthesizer) Close() error {
+	return c.Synthesizer.Close()
+}
+
+// Method returns the cached audio of the current engine for an input, or
+// synthesizes it and stores it under the engine that produced it, which
+// differs from the current engine when it fell back. Empty audio, as
+// written by the text engine, is not cached.
+//
+// Parameters:
+//  - kind: "text" or "ssml".
+//  - input: The text or SSML.
+//  - voice: The voice to speak in.
+//  - sampleRate: The sample rate of the audio.
+//  - synthesize: Synthesizes the input and returns the engine that did.
+//
+// Returns:
+//  - []byte: The audio.
+//  - error: Error if synthesis failed.
+func (c *CachingSynthesizer) cached(
+	kind, input string,
+	voice Voice,
+	sampleRate int,
+	synthesize func() ([]byte, Synthesizer, error),
+) ([]byte, error) {
+	current := c.Synthesizer
+	if fallback, ok := current.(*FallbackSynthesizer); ok {
+		current = fallback.Current()
+	}
+	if audio := c.load(speechCacheKey(kind, current, input, voice, sampleRate)); audio != nil {
+		return audio, nil
+	}
+	audio, engine, err := synthesize()
+	if err != nil || len(audio) == 0 {
+		return audio, err
+	}
+	key := speechCacheKey(kind, engine, input, voice, sampleRate)
+	if err := c.store(key, audio); err != nil {
+		log.Printf("Failed to cache speech: %v", err)
+	}
+	return audio, nil
+}
+
+// Method reads cached audio and marks it as recently used. Files removed
+// behind the cache's back are dropped from the index.
+func (c *CachingSynthesizer) load(key string) []byte {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	path := c.path(key)
+	audio, err := os.ReadFile(path)
+	if err != nil || len(audio) == 0 {
+		c.remove(element)
+		return nil
+	}
+	c.order.MoveToFront(element)
+	// Keep the order across restarts
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return audio
+}
+
+// Method writes audio to the cache, then removes the least recently used
+// files until the cache fits its size cap.
+func (c *CachingSynthesizer) store(key string, audio []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if int64(len(audio)) > c.maxBytes {
+		return nil
+	}
+	if element, ok := c.entries[key]; ok {
+		// Synthesized concurrently by another worker
+		c.order.MoveToFront(element)
+		return nil
+	}
+	// Write to a temporary file first so a crash never leaves partial audio
+	tmp, err := os.CreateTemp(c.dir, ".speech-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(audio); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		return err
+	}
+	entry := &speechCacheEntry{key: key, size: int64(len(audio))}
+	c.entries[key] = c.order.PushFront(entry)
+	c.size += entry.size
+	c.evict()
+	return nil
+}
+
+// Method removes the least recently used files while the cache is over its
+// size cap. The mutex must be held.
+func (c *CachingSynthesizer) evict() {
+	for c.size > c.maxBytes && c.order.Len() > 0 {
+		c.remove(c.order.Back())
+	}
+}
+
+// Method removes a cache entry and its file. The mutex must be held.
+func (c *CachingSynthesizer) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*speechCacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+	if err := os.Remove(c.path(entry.key)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove cached speech: %v", err)
+	}
+}
+
+// Method returns the path of the cached audio for a key.
+func (c *CachingSynthesizer) path(key string) string {
+	return filepath.Join(c.dir, key+speechCacheExtension)
+}
+
+// Method returns the cache key of an input: a hash of its kind, the engine
+// speaking it, its content and everything that changes how it sounds.
+func speechCacheKey(kind string, engine Synthesizer, input string, voice Voice, sampleRate int) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00%s\x00%s\x00%s\x00%s\x00%g\x00%g\x00%g\x00%d",
+		kind, speechEngineName(engine), input, voice.Language, voice.Name, voice.Gender,
+		voice.SpeakingRate, voice.Pitch, voice.VolumeGainDb, sampleRate,
+	)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Method names a text-to-speech engine: its type, and for the offline
+// engines the binary's engine and voice model, which share a type.
+func speechEngineName(engine Synthesizer) string {
+	if offline, ok := engine.(*OfflineSynthesizer); ok {
+		return fmt.Sprintf("%s %s", offline.Engine, offline.Model)
+	}
+	return fmt.Sprintf("%T", engine)
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"os"
+	"slices"
+	"errors"
+	"context"
+	"testing"
+)
+
+// countingSynthesizer speaks a byte per character and counts its calls.
+type countingSynthesizer struct {
+	calls int
+}
+
+func (s *countingSynthesizer) Synthesize(_ context.Context, text string, _ Voice, _ int) ([]byte, error) {
+	s.calls++
+	return []byte(text), nil
+}
+
+func (s *countingSynthesizer) Close() error {
+	return nil
+}
+
+func TestSpeechCacheKeepsEnginesApart(t *testing.T) {
+	failure := errors.New("connection reset")
+	primary := &failingSynthesizer{name: "primary", err: failure}
+	fallback := &FallbackSynthesizer{Engines: []Synthesizer{
+		primary, &countingSynthesizer{}, &TextSynthesizer{},
+	}}
+	synthesizer, err := NewCachingSynthesizer(fallback, TextToSpeechConfig{CacheDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	speak := func() string {
+		audio, err := synthesizer.Synthesize(context.Background(), "Hi", Voice{}, 16000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(audio)
+	}
+	if speak() == "primary" {
+		t.Fatal("the failing primary engine spoke")
+	}
+	// Once the primary engine is back, the fallback's audio is not reused
+	primary.err = nil
+	fallback.retryAt = nil
+	if speak() != "primary" {
+		t.Error("after the primary engine recovered: spoken by the fallback engine")
+	}
+	primary.err = failure
+	if speak() != "primary" {
+		t.Error("cached audio: spoken by the fallback engine")
+	}
+	cache := synthesizer.(*CachingSynthesizer)
+	if string(cache.Lookup("Hi", "", Voice{}, 16000)) != "primary" {
+		t.Error("looked up the fallback engine's audio, want the primary engine's")
+	}
+}
+
+func TestSpeechCacheEviction(t *testing.T) {
+	tests := []struct {
+		name   string
+		spoken []string // Phrases spoken in order
+		cached []string // Phrases left in the cache, most recently used first
+		calls  int      // Phrases synthesized rather than played from the cache
+	}{
+		{"fits", []string{"aaaa", "bbbb", "cc"}, []string{"cc", "bbbb", "aaaa"}, 3},
+		{"repeated", []string{"aaaa", "aaaa", "aaaa"}, []string{"aaaa"}, 1},
+		{"least recently used evicted", []string{"aaaa", "bbbb", "aaaa", "ccc"}, []string{"ccc", "aaaa"}, 3},
+		{"several evicted", []string{"aaa", "bbb", "ccc", "dddddddd"}, []string{"dddddddd"}, 4},
+		{"evicted spoken again", []string{"aaaa", "bbbb", "ccc", "aaaa"}, []string{"aaaa", "ccc"}, 4},
+		{"larger than the cache", []string{"aaaa", "bbbbbbbbbbb"}, []string{"aaaa"}, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			engine := &countingSynthesizer{}
+			synthesizer, err := NewCachingSynthesizer(engine, TextToSpeechConfig{CacheDir: dir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cache := synthesizer.(*CachingSynthesizer)
+			cache.maxBytes = 10
+			for _, phrase := range test.spoken {
+				if _, err := cache.Synthesize(context.Background(), phrase, Voice{}, 16000); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var cached []string
+			size := int64(0)
+			for element := cache.order.Front(); element != nil; element = element.Next() {
+				entry := element.Value.(*speechCacheEntry)
+				audio, err := os.ReadFile(cache.path(entry.key))
+				if err != nil {
+					t.Fatal(err)
+				}
+				cached = append(cached, string(audio))
+				size += int64(len(audio))
+			}
+			if !slices.Equal(cached, test.cached) {
+				t.Errorf("cached %q, want %q", cached, test.cached)
+			}
+			if cache.size != size || len(cache.entries) != len(cached) {
+				t.Errorf("accounted %d bytes in %d entries, want %d in %d",
+					cache.size, len(cache.entries), size, len(cached),
+				)
+			}
+			if files, _ := os.ReadDir(dir); len(files) != len(cached) {
+				t.Errorf("%d files in the cache directory, want %d", len(files), len(cached))
+			}
+			if engine.calls != test.calls {
+				t.Errorf("synthesized %d times, want %d", engine.calls, test.calls)
+			}
+			// A restart indexes the same files
+			reopened, err := NewCachingSynthesizer(engine, TextToSpeechConfig{CacheDir: dir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := reopened.(*CachingSynthesizer).size; got != size {
+				t.Errorf("after a restart: accounted %d bytes, want %d", got, size)
+			}
+			for _, phrase := range test.cached {
+				if audio := reopened.(*CachingSynthesizer).Lookup(phrase, "", Voice{}, 16000); string(audio) != phrase {
+					t.Errorf("after a restart: %q is not cached", phrase)
+				}
+			}
+		})
+	}
+}
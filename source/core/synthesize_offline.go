@@ -0,0 +1,180 @@
+package core
+
+import (
+	"os"
+	"fmt"
+	"io/fs"
+	"bytes"
+	"errors"
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"path/filepath"
+	// Local utilities
+	"kai/source/utils"
+)
+
+// OfflineSynthesizer speaks locally by running the piper or espeak-ng
+// command line binary and reading the WAV file it writes.
+type OfflineSynthesizer struct {
+	Engine string
+	Binary string
+	Model  string
+	Args   []string
+}
+
+// Method creates an offline synthesizer, checking that the binary exists
+// and, for piper, that the voice model exists.
+//
+// Parameters:
+//  - config: The text-to-speech configuration.
+//
+// Returns:
+//  - *OfflineSynthesizer: The synthesizer.
+//  - error: Error if the binary or the model cannot be found.
+func NewOfflineSynthesizer(config TextToSpeechConfig) (*OfflineSynthesizer, error) {
+	binary := config.Binary
+	if binary == "" {
+		binary = map[string]string{
+			"piper":  "piper",
+			"espeak": "espeak-ng",
+		}[config.Engine]
+	}
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("%s binary not found: %w", config.Engine, err)
+	}
+	if config.Engine == "piper" {
+		if config.Model == "" {
+			return nil, fmt.Errorf("piper requires a voice model path")
+		}
+		if _, err := os.Stat(expandPath(config.Model)); err != nil {
+			return nil, fmt.Errorf("piper model not found: %w", err)
+		}
+	}
+	return &OfflineSynthesizer{
+		Engine: config.Engine,
+		Binary: path,
+		Model:  expandPath(config.Model),
+		Args:   config.Args,
+	}, nil
+}
+
+// Method runs the engine's binary to write the speech to a temporary WAV
+// file and resamples it to the requested sample rate.
+func (s *OfflineSynthesizer) Synthesize(
+	ctx context.Context,
+	text string,
+	voice Voice,
+	sampleRate int,
+) ([]byte, error) {
+	return s.synthesize(ctx, text, false, voice, sampleRate)
+}
+
+// Method synthesizes SSML. espeak-ng reads the markup itself; piper speaks
+// the text of the SSML.
+func (s *OfflineSynthesizer) SynthesizeSSML(
+	ctx context.Context,
+	ssml string,
+	voice Voice,
+	sampleRate int,
+) ([]byte, error) {
+	if s.Engine != "espeak" {
+		return s.synthesize(ctx, SSMLText(ssml), false, voice, sampleRate)
+	}
+	return s.synthesize(ctx, ssml, true, voice, sampleRate)
+}
+
+// Method runs the engine on text, or on SSML if markup is set.
+func (s *OfflineSynthesizer) synthesize(
+	ctx context.Context,
+	text string,
+	markup bool,
+	voice Voice,
+	sampleRate int,
+) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "kai-tts-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	wavFile := filepath.Join(dir, "output.wav")
+	cmd := exec.CommandContext(ctx, s.Binary, s.arguments(wavFile, text, markup, voice)...)
+	if s.Engine == "piper" {
+		// Piper reads the text from standard input
+		cmd.Stdin = strings.NewReader(text)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// The binary was removed since the engine was selected
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s binary not found: %v", errEngineUnavailable, s.Engine, err)
+		}
+		return nil, fmt.Errorf(
+			"%s failed: %v: %s", s.Engine, err, strings.TrimSpace(stderr.String()),
+		)
+	}
+	data, err := os.ReadFile(wavFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read synthesized audio: %v", err)
+	}
+	samples, rate, err := utils.DecodeWav(data)
+	if err != nil {
+		return nil, err
+	}
+	samples = utils.ApplyGain(samples, voice.VolumeGainDb)
+	return utils.SamplesToBytes(utils.Resample(samples, rate, sampleRate)), nil
+}
+
+// Method has nothing to release; each synthesis runs its own process.
+func (s *OfflineSynthesizer) Close() error {
+	return nil
+}
+
+// Method builds the command line arguments for the engine.
+func (s *OfflineSynthesizer) arguments(
+	wavFile, text string,
+	markup bool,
+	voice Voice,
+) []string {
+	var args []string
+	switch s.Engine {
+	case "piper":
+		args = []string{"--model", s.Model, "--output_file", wavFile}
+		if voice.SpeakingRate > 0 {
+			// Piper stretches phonemes instead of setting a rate
+			args = append(args, "--length_scale", formatFloat(1/voice.SpeakingRate))
+		}
+	case "espeak":
+		// espeak-ng names voices by lowercase language, e.g. "es" or "en-us"
+		args = []string{"-w", wavFile}
+		if markup {
+			args = append(args, "-m")
+		}
+		if language := strings.ToLower(voice.Language); language != "" {
+			args = append(args, "-v", language)
+		}
+		// Words per minute, 175 by default
+		if voice.SpeakingRate > 0 {
+			args = append(args, "-s", strconv.Itoa(int(175*voice.SpeakingRate)))
+		}
+		// Pitch from 0 to 99, 50 by default; map the ±20 semitones onto it
+		if voice.Pitch != 0 {
+			pitch := min(max(int(50+voice.Pitch*2.5), 0), 99)
+			args = append(args, "-p", strconv.Itoa(pitch))
+		}
+	}
+	args = append(args, s.Args...)
+	if s.Engine == "espeak" {
+		// End option parsing so text starting with a dash is spoken
+		args = append(args, "--", text)
+	}
+	return args
+}
+
+// Method formats a number for a command line argument.
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 3, 64)
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"fmt"
+	"errors"
+	"context"
+	"testing"
+)
+
+// failingSynthesizer fails with its error while it is set, and speaks its
+// name otherwise.
+type failingSynthesizer struct {
+	name string
+	err  error
+}
+
+func (s *failingSynthesizer) Synthesize(context.Context, string, Voice, int) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []byte(s.name), nil
+}
+
+func (s *failingSynthesizer) Close() error {
+	return nil
+}
+
+func TestFallbackSynthesizerSkipsFailedEngines(t *testing.T) {
+	unavailable := fmt.Errorf("%w: no credentials", errEngineUnavailable)
+	tests := []struct {
+		name      string
+		err       error
+		fallback  string // Who speaks while the primary engine fails
+		recovered string // Who speaks once it works again
+	}{
+		{"unavailable engine is dropped", unavailable, "backup", "backup"},
+		{"failing engine is retried later", errors.New("connection reset"), "backup", "primary"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			primary := &failingSynthesizer{name: "primary", err: test.err}
+			fallback := &FallbackSynthesizer{Engines: []Synthesizer{
+				primary, &failingSynthesizer{name: "backup"}, &TextSynthesizer{},
+			}}
+			speak := func() string {
+				audio, err := fallback.Synthesize(context.Background(), "Hi", Voice{}, 16000)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return string(audio)
+			}
+			if got := speak(); got != test.fallback {
+				t.Errorf("while failing: spoken by %q, want %q", got, test.fallback)
+			}
+			primary.err = nil
+			if got := speak(); got != "backup" {
+				t.Errorf("right after failing: spoken by %q, want backup", got)
+			}
+			// Let the retry delay pass
+			for index, retryAt := range fallback.retryAt {
+				fallback.retryAt[index] = retryAt.Add(-engineRetryDelay)
+			}
+			if got := speak(); got != test.recovered {
+				t.Errorf("after %v: spoken by %q, want %q", engineRetryDelay, got, test.recovered)
+			}
+		})
+	}
+}
+
+func TestFallbackSynthesizerKeepsLastEngine(t *testing.T) {
+	failure := errors.New("broken")
+	fallback := &FallbackSynthesizer{Engines: []Synthesizer{
+		&failingSynthesizer{err: failure}, &failingSynthesizer{err: failure},
+	}}
+	for i := 0; i < 2; i++ {
+		if _, err := fallback.Synthesize(context.Background(), "Hi", Voice{}, 16000); err != failure {
+			t.Errorf("attempt %d: got %v, want the last engine's error", i+1, err)
+		}
+	}
+	if next := fallback.next(0); next != 1 {
+		t.Errorf("next engine is %d, want the last one", next)
+	}
+}
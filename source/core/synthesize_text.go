@@ -0,0 +1,47 @@
+package core
+
+import (
+	"io"
+	"os"
+	"fmt"
+	"context"
+)
+
+// TextSynthesizer is the silent engine: instead of producing audio it
+// writes the text, for machines without speech credentials or an audio
+// device.
+type TextSynthesizer struct {
+	// Where the text is written; standard output if nil
+	Output io.Writer
+}
+
+// Method writes the text and returns no audio.
+func (s *TextSynthesizer) Synthesize(
+	_ context.Context,
+	text string,
+	_ Voice,
+	_ int,
+) ([]byte, error) {
+	output := s.Output
+	if output == nil {
+		output = os.Stdout
+	}
+	fmt.Fprintf(output, "Kai: %s\n", text)
+	return nil, nil
+}
+
+// Method writes the text of the SSML, as it would be displayed, and
+// returns no audio.
+func (s *TextSynthesizer) SynthesizeSSML(
+	ctx context.Context,
+	ssml string,
+	voice Voice,
+	sampleRate int,
+) ([]byte, error) {
+	return s.Synthesize(ctx, stripSSML(ssml), voice, sampleRate)
+}
+
+// Method has nothing to release.
+func (s *TextSynthesizer) Close() error {
+	return nil
+}
@@ -0,0 +1,270 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"strings"
+	"encoding/json"
+	// Local imports
+	"kai/source/mcp"
+)
+
+// Largest tool or resource output fed back into the AI.
+const maxToolOutputBytes = 64 * 1024
+
+// Guards the registration of the MCP response types, which happens once at
+// most, when the first server connects.
+var registerMCPHandlersOnce sync.Once
+
+// Method registers the "tool" and "resource" response types backed by MCP
+// servers. They are only offered to the model once a server has connected,
+// so it is never told about tools it cannot call.
+func registerMCPHandlers() {
+	serverField := HandlerField{
+		Name:        "server",
+		Type:        "string",
+		Description: "The name of the server, as listed under external tools.",
+		Required:    true,
+	}
+	RegisterHandler(ResponseHandler{
+		Type:        "tool",
+		Description: "Calls one of the external tools listed in the system " +
+			"instructions. Prefer a tool over a shell command when one fits. " +
+			"The result will be sent back to you.",
+		Fields: []HandlerField{
+			serverField,
+			{
+				Name:        "name",
+				Type:        "string",
+				Description: "The name of the tool.",
+				Required:    true,
+			},
+			{
+				Name:        "arguments",
+				Type:        "object",
+				Description: "The arguments, following the tool's input schema.",
+			},
+		},
+		Execute: processTool,
+	})
+	RegisterHandler(ResponseHandler{
+		Type:        "resource",
+		Description: "Reads one of the external resources listed in the " +
+			"system instructions. The content will be sent back to you.",
+		Fields: []HandlerField{
+			serverField,
+			{
+				Name:        "uri",
+				Type:        "string",
+				Description: "The URI of the resource.",
+				Required:    true,
+			},
+		},
+		Execute: processResource,
+	})
+}
+
+// Method handles the processing of a "tool" response item by calling the
+// tool on its MCP server and feeding the result back into the AI. The call
+// is abandoned when the turn is interrupted.
+//
+// Parameters:
+//  - kai: The AI system handling the request.
+//  - data: The JSON-encoded data holding the server, tool and arguments.
+//  - branchCount: The current branch count to manage recursion.
+//
+// Returns:
+//  - bool: True if the result was fed back into the AI.
+func processTool(kai *Kai, data json.RawMessage, branchCount int) bool {
+	var toolData struct {
+		Server    string          `json:"server"`
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(data, &toolData); err != nil {
+		log.Printf("Failed to parse tool data: %v", err)
+		return false
+	}
+	client, err := kai.mcpServer(toolData.Server)
+	if err != nil {
+		kai.handleAIResponse(err.Error(), branchCount)
+		return true
+	}
+	result, err := client.CallTool(kai.currentTurn(), toolData.Name, toolData.Arguments)
+	if err != nil {
+		kai.handleAIResponse(fmt.Sprintf(
+			"Tool %s on %s failed: %v. " +
+			"Please analyze the error and generate a new solution.",
+			toolData.Name, toolData.Server, err,
+		), branchCount)
+		return true
+	}
+	status := "returned"
+	if result.IsError {
+		status = "reported an error"
+	}
+	kai.handleAIResponse(fmt.Sprintf(
+		"Tool %s on %s %s: %s\n" +
+		"Please analyze the result and provide a suitable response.",
+		toolData.Name, toolData.Server, status,
+		truncateOutput(result.Text()),
+	), branchCount)
+	return true
+}
+
+// Method handles the processing of a "resource" response item by reading
+// the resource from its MCP server and feeding the content back into the AI.
+// Reading is abandoned when the turn is interrupted.
+//
+// Parameters:
+//  - kai: The AI system handling the request.
+//  - data: The JSON-encoded data holding the server and resource URI.
+//  - branchCount: The current branch count to manage recursion.
+//
+// Returns:
+//  - bool: True if the result was fed back into the AI.
+func processResource(kai *Kai, data json.RawMessage, branchCount int) bool {
+	var resourceData struct {
+		Server string `json:"server"`
+		URI    string `json:"uri"`
+	}
+	if err := json.Unmarshal(data, &resourceData); err != nil {
+		log.Printf("Failed to parse resource data: %v", err)
+		return false
+	}
+	client, err := kai.mcpServer(resourceData.Server)
+	if err != nil {
+		kai.handleAIResponse(err.Error(), branchCount)
+		return true
+	}
+	contents, err := client.ReadResource(kai.currentTurn(), resourceData.URI)
+	if err != nil {
+		kai.handleAIResponse(fmt.Sprintf(
+			"Reading resource %s on %s failed: %v. " +
+			"Please analyze the error and generate a new solution.",
+			resourceData.URI, resourceData.Server, err,
+		), branchCount)
+		return true
+	}
+	var text strings.Builder
+	for _, content := range contents {
+		if content.Text != "" {
+			text.WriteString(content.Text)
+		} else {
+			fmt.Fprintf(&text, "[binary content %s]", content.MimeType)
+		}
+		text.WriteString("\n")
+	}
+	kai.handleAIResponse(fmt.Sprintf(
+		"Contents of resource %s on %s:\n%s" +
+		"Please analyze the content and provide a suitable response.",
+		resourceData.URI, resourceData.Server,
+		truncateOutput(text.String()),
+	), branchCount)
+	return true
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Method connects to the configured MCP servers and registers the response
+// types that use them. Servers that fail to connect are skipped.
+//
+// Parameters:
+//  - servers: The configured servers.
+func (kai *Kai) connectMCPServers(servers []mcp.ServerConfig) {
+	kai.MCPServers = map[string]*mcp.Client{}
+	for _, server := range servers {
+		client, err := mcp.Connect(kai.Context, server)
+		if err != nil {
+			log.Printf("Failed to connect to MCP server: %v", err)
+			continue
+		}
+		kai.MCPServers[server.Name] = client
+	}
+	if len(kai.MCPServers) == 0 {
+		return
+	}
+	registerMCPHandlersOnce.Do(registerMCPHandlers)
+}
+
+// Method describes the tools and resources of the connected servers, in the
+// order they were configured.
+//
+// Parameters:
+//  - servers: The configured servers.
+//
+// Returns:
+//  - string: The catalog of external tools and resources.
+func (kai *Kai) describeMCPServers(servers []mcp.ServerConfig) string {
+	var catalog strings.Builder
+	catalog.WriteString(
+		"External tools and resources, usable with the 'tool' and " +
+		"'resource' response types:\n",
+	)
+	for _, server := range servers {
+		client, connected := kai.MCPServers[server.Name]
+		if !connected {
+			continue
+		}
+		fmt.Fprintf(&catalog, "Server '%s':\n", client.Name)
+		if client.Instructions != "" {
+			fmt.Fprintf(&catalog, "  Instructions: %s\n", client.Instructions)
+		}
+		for _, tool := range client.Tools {
+			fmt.Fprintf(&catalog, "  - tool '%s': %s\n", tool.Name, tool.Description)
+			if len(tool.InputSchema) > 0 {
+				fmt.Fprintf(&catalog, "    input schema: %s\n", tool.InputSchema)
+			}
+		}
+		for _, resource := range client.Resources {
+			fmt.Fprintf(
+				&catalog, "  - resource '%s' (%s): %s\n",
+				resource.URI, resource.Name, resource.Description,
+			)
+		}
+	}
+	return catalog.String()
+}
+
+// Method returns the connected MCP server with the given name.
+//
+// Parameters:
+//  - name: The configured name of the server.
+//
+// Returns:
+//  - *mcp.Client: The client for the server.
+//  - error: An error message suitable for the AI if the server is unknown.
+func (kai *Kai) mcpServer(name string) (*mcp.Client, error) {
+	if client, exists := kai.MCPServers[name]; exists {
+		return client, nil
+	}
+	names := make([]string, 0, len(kai.MCPServers))
+	for serverName := range kai.MCPServers {
+		names = append(names, serverName)
+	}
+	return nil, fmt.Errorf(
+		"MCP server '%s' is not connected. Connected servers: [%s]. " +
+		"Please choose another way to resolve the user's request.",
+		name, strings.Join(names, ", "),
+	)
+}
+
+// Method closes the connections to all MCP servers.
+func (kai *Kai) closeMCPServers() {
+	for name, client := range kai.MCPServers {
+		if err := client.Close(); err != nil {
+			log.Printf("Failed to close MCP server %s: %v", name, err)
+		}
+	}
+}
+
+// Method truncates output fed back into the AI.
+func truncateOutput(output string) string {
+	if len(output) <= maxToolOutputBytes {
+		return output
+	}
+	return output[:maxToolOutputBytes] + "\n(output truncated)"
+}
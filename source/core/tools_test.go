@@ -0,0 +1,20 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"strings"
+)
+
+func TestMCPHandlersRequireAServer(t *testing.T) {
+	kai := &Kai{Context: context.Background()}
+	kai.connectMCPServers(nil)
+	for _, itemType := range []string{"tool", "resource"} {
+		if _, exists := LookupHandler(itemType); exists {
+			t.Errorf("%q is registered without any MCP server", itemType)
+		}
+	}
+	if strings.Contains(ProtocolDescription(), "'tool'") {
+		t.Error("the primer offers tools without any MCP server")
+	}
+}
@@ -0,0 +1,199 @@
+package core
+
+import (
+	"os"
+	"fmt"
+	"log"
+	"time"
+	"bytes"
+	"errors"
+	"context"
+	"os/exec"
+	"strings"
+	"path/filepath"
+	// Local utilities
+	"kai/source/utils"
+)
+
+// Longest audio sent to the recognizer at once; the synchronous Google API
+// accepts about a minute.
+const maxRecognitionChunk = 50 * time.Second
+
+// End of a chunk searched for the quietest moment to split at, so words are
+// not cut in half.
+const chunkSplitWindow = 5 * time.Second
+
+// Audio file extensions decoded with ffmpeg.
+var ffmpegExtensions = map[string]bool{
+	".flac": true, ".ogg": true, ".oga": true, ".opus": true,
+	".mp3": true, ".m4a": true, ".webm": true,
+}
+
+// Method reports whether a file is audio Kai can transcribe, going by its
+// extension.
+func IsAudioFile(path string) bool {
+	extension := strings.ToLower(filepath.Ext(path))
+	return extension == ".wav" || ffmpegExtensions[extension]
+}
+
+// Method creates a Kai instance that can only transcribe audio files, for
+// the transcribe command. It needs neither the Gemini API nor sound
+// hardware.
+//
+// Parameters:
+//  - config: The app configuration.
+//
+// Returns:
+//  - *Kai: The Kai instance.
+//  - error: Error if the configured recognizer cannot be created.
+func InitializeTranscriber(config *Config) (*Kai, error) {
+	recognizer, err := NewRecognizer(config.SpeechToText, config.Language)
+	if err != nil {
+		return nil, err
+	}
+	kai := &Kai{
+		Config:     config,
+		Context:    context.Background(),
+		Recognizer: recognizer,
+	}
+	kai.SampleRate = kai.audioConfig().SampleRate
+	return kai, nil
+}
+
+// Method transcribes an audio file of any length, e.g. a recorded meeting or
+// a voice memo. WAV files are read directly; FLAC, OGG and other formats
+// are decoded with ffmpeg. The audio is preprocessed and split into chunks
+// at quiet moments, each short enough for the recognizer.
+//
+// Parameters:
+//  - ctx: Cancels the transcription.
+//  - path: The path of the audio file.
+//  - progress: Called before each chunk with its number and the number of
+//    chunks; may be nil.
+//
+// Returns:
+//  - Transcript: The text of all chunks and the first detected language.
+//  - error: Error if the file cannot be decoded or recognition fails.
+func (kai *Kai) TranscribeFile(
+	ctx context.Context,
+	path string,
+	progress func(chunk, chunks int),
+) (Transcript, error) {
+	if kai.Recognizer == nil {
+		return Transcript{}, fmt.Errorf("no speech recognizer configured")
+	}
+	samples, sampleRate, err := decodeAudioFile(ctx, expandPath(path))
+	if err != nil {
+		return Transcript{}, err
+	}
+	if kai.preprocessing() {
+		preprocessor := NewAudioPreprocessor(sampleRate)
+		samples = append(preprocessor.Process(samples), preprocessor.Flush()...)
+		sampleRate = recognitionSampleRate
+	}
+	chunks := splitAudio(samples, sampleRate, maxRecognitionChunk)
+	var result Transcript
+	var texts []string
+	for i, chunk := range chunks {
+		if progress != nil {
+			progress(i+1, len(chunks))
+		}
+		transcript, err := kai.Recognizer.Recognize(
+			ctx, utils.SamplesToBytes(chunk), sampleRate,
+		)
+		if errors.Is(err, ErrNoSpeech) {
+			// A pause in the recording
+			continue
+		}
+		if err != nil {
+			return Transcript{}, fmt.Errorf(
+				"failed to transcribe part %d of %d: %w", i+1, len(chunks), err,
+			)
+		}
+		texts = append(texts, strings.TrimSpace(transcript.Text))
+		if result.Language == "" {
+			result.Language = transcript.Language
+		}
+	}
+	if len(texts) == 0 {
+		return Transcript{}, ErrNoSpeech
+	}
+	result.Text = strings.Join(texts, " ")
+	return result, nil
+}
+
+// Method decodes an audio file to mono samples.
+//
+// Parameters:
+//  - ctx: Cancels decoding.
+//  - path: The path of the audio file.
+//
+// Returns:
+//  - []int16: The mono samples.
+//  - int: Their sample rate.
+//  - error: Error if the file cannot be read or decoded.
+func decodeAudioFile(ctx context.Context, path string) ([]int16, int, error) {
+	extension := strings.ToLower(filepath.Ext(path))
+	if extension == ".wav" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		samples, sampleRate, err := utils.DecodeWav(data)
+		if err == nil {
+			return samples, sampleRate, nil
+		}
+		// e.g. 24-bit or floating point WAV
+		log.Printf("Decoding %s with ffmpeg: %v", filepath.Base(path), err)
+	} else if !ffmpegExtensions[extension] {
+		return nil, 0, fmt.Errorf("unsupported audio file type %q", extension)
+	}
+	return decodeWithFFmpeg(ctx, path)
+}
+
+// Method decodes an audio file to 16 kHz mono samples with ffmpeg.
+func decodeWithFFmpeg(ctx context.Context, path string) ([]int16, int, error) {
+	binary, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, 0, fmt.Errorf(
+			"decoding %s files requires ffmpeg: %v", filepath.Ext(path), err,
+		)
+	}
+	cmd := exec.CommandContext(ctx, binary,
+		"-nostdin", "-loglevel", "error", "-i", path,
+		"-f", "s16le", "-acodec", "pcm_s16le",
+		"-ac", "1", "-ar", fmt.Sprint(recognitionSampleRate), "-",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, 0, fmt.Errorf(
+			"ffmpeg failed: %v: %s", err, strings.TrimSpace(stderr.String()),
+		)
+	}
+	return utils.BytesToSamples(output), recognitionSampleRate, nil
+}
+
+// Method splits audio into chunks no longer than the maximum, ending each
+// chunk at the quietest 20 ms near its end.
+func splitAudio(samples []int16, sampleRate int, maximum time.Duration) [][]int16 {
+	maxSamples := durationSamples(maximum, sampleRate)
+	window := durationSamples(chunkSplitWindow, sampleRate)
+	frame := max(durationSamples(preprocessFrame, sampleRate), 1)
+	var chunks [][]int16
+	for len(samples) > maxSamples {
+		split, quietest := maxSamples, -1.0
+		for end := maxSamples; end >= maxSamples-window && end-frame >= 0; end -= frame {
+			if level := rms(samples[end-frame : end]); quietest < 0 || level < quietest {
+				split, quietest = end-frame/2, level
+			}
+		}
+		chunks = append(chunks, samples[:split])
+		samples = samples[split:]
+	}
+	if len(samples) > 0 {
+		chunks = append(chunks, samples)
+	}
+	return chunks
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+)
+
+// Method starts processing a response as a new turn, interrupting the
+// previous turn first. Turns run one at a time: the new turn waits until
+// the interrupted turn has unwound, which is quick because its speech has
+// stopped and its remaining items are dropped. A turn ends once its queued
+// speech has played.
+//
+// Returns:
+//  - context.Context: Cancelled when the turn is interrupted.
+//  - func(): Ends the turn; must be called when the response is processed.
+func (kai *Kai) beginTurn() (context.Context, func()) {
+	kai.Interrupt()
+	kai.turnLock.Lock()
+	ctx, cancel := context.WithCancel(kai.Context)
+	kai.turnMutex.Lock()
+	kai.turnContext, kai.turnCancel = ctx, cancel
+	kai.turnMutex.Unlock()
+	return ctx, func() {
+		// The turn lasts until its speech has been played
+		kai.waitForSpeech(ctx)
+		cancel()
+		kai.turnMutex.Lock()
+		kai.turnContext, kai.turnCancel = nil, nil
+		kai.turnMutex.Unlock()
+		kai.turnLock.Unlock()
+	}
+}
+
+// Method interrupts the current turn: speech stops immediately, and the
+// items still queued in the turn's response are dropped, script items
+// included. Command outputs are no longer fed back to the model.
+func (kai *Kai) Interrupt() {
+	kai.turnMutex.Lock()
+	defer kai.turnMutex.Unlock()
+	if kai.turnCancel != nil {
+		kai.turnCancel()
+	}
+	kai.StopSpeaking()
+}
+
+// Method stops the speech playing right now and drops the speech queued
+// after it, without interrupting the turn, e.g. when the user starts
+// answering a question before it was read out.
+func (kai *Kai) StopSpeaking() {
+	kai.speech.mutex.Lock()
+	defer kai.speech.mutex.Unlock()
+	if kai.speech.cancel != nil {
+		kai.speech.cancel()
+		kai.speech.generation, kai.speech.cancel = nil, nil
+	}
+}
+
+// Method returns the context of the current turn, which speech is bound to.
+// Outside of a turn it is Kai's context.
+func (kai *Kai) currentTurn() context.Context {
+	kai.turnMutex.Lock()
+	defer kai.turnMutex.Unlock()
+	if kai.turnContext == nil {
+		return kai.Context
+	}
+	return kai.turnContext
+}
+
+// Method reports whether the current turn has been interrupted.
+func (kai *Kai) interrupted() bool {
+	return kai.currentTurn().Err() != nil
+}
+
+// Method returns the context queued speech is bound to. It is shared by
+// all speech until StopSpeaking, and cancelled with the turn it was created
+// in.
+func (kai *Kai) speechContext() context.Context {
+	turn := kai.currentTurn()
+	kai.speech.mutex.Lock()
+	defer kai.speech.mutex.Unlock()
+	generation := kai.speech.generation
+	// Start a new generation after StopSpeaking or in a new turn
+	if generation == nil || generation.Err() != nil || kai.speech.parent != turn {
+		if kai.speech.cancel != nil {
+			kai.speech.cancel()
+		}
+		kai.speech.generation, kai.speech.cancel = context.WithCancel(turn)
+		kai.speech.parent = turn
+	}
+	return kai.speech.generation
+}
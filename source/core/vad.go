@@ -0,0 +1,184 @@
+package core
+
+import (
+	"math"
+	"time"
+)
+
+// Default voice activity detection settings.
+const (
+	defaultVADThreshold    = 500.0
+	defaultVADSilence      = 800 * time.Millisecond
+	defaultVADMaxUtterance = 30 * time.Second
+	// Length of the frames speech is detected on
+	vadFrame               = 20 * time.Millisecond
+	// Quiet audio kept before the onset and after the end of speech, so
+	// soft consonants are not clipped
+	vadPadding             = 200 * time.Millisecond
+)
+
+// VoiceActivityDetector separates speech from silence in a stream of 16-bit
+// mono samples using an energy gate. Leading and trailing silence is
+// trimmed, an utterance ends after a configurable pause, and utterances are
+// capped at a maximum length.
+type VoiceActivityDetector struct {
+	frameSize   int
+	threshold   float64
+	silence     int // Quiet frames that end an utterance
+	padding     int // Quiet frames kept around speech
+	maxSamples  int
+	frame       []int16
+	preRoll     [][]int16 // Quiet frames before the onset
+	trailing    [][]int16 // Quiet frames since speech last paused
+	length      int       // Samples emitted for the current utterance
+	speaking    bool
+	truncated   bool
+}
+
+// Method creates a detector for the given sample rate and settings. Zero
+// settings select the defaults.
+func NewVoiceActivityDetector(
+	sampleRate int,
+	config VADConfig,
+) *VoiceActivityDetector {
+	threshold := config.Threshold
+	if threshold <= 0 {
+		threshold = defaultVADThreshold
+	}
+	silence := time.Duration(config.SilenceMs) * time.Millisecond
+	if silence <= 0 {
+		silence = defaultVADSilence
+	}
+	maxUtterance := time.Duration(config.MaxUtteranceMs) * time.Millisecond
+	if maxUtterance <= 0 {
+		maxUtterance = defaultVADMaxUtterance
+	}
+	return &VoiceActivityDetector{
+		frameSize:  max(durationSamples(vadFrame, sampleRate), 1),
+		threshold:  threshold,
+		silence:    max(int(silence/vadFrame), 1),
+		padding:    int(vadPadding / vadFrame),
+		maxSamples: durationSamples(maxUtterance, sampleRate),
+	}
+}
+
+// Method feeds samples into the detector. It returns the samples that
+// belong to the current utterance, which may include buffered padding, and
+// whether the utterance has ended. The detector resets itself after an
+// utterance ends, so it can be used on a continuous stream.
+//
+// Parameters:
+//  - samples: The next samples of the stream. The slice is not retained.
+//
+// Returns:
+//  - []int16: Speech samples to append to the utterance; may be empty.
+//  - bool: True once a pause or the length limit ended the utterance.
+func (vad *VoiceActivityDetector) Process(samples []int16) ([]int16, bool) {
+	var voiced []int16
+	for _, sample := range samples {
+		vad.frame = append(vad.frame, sample)
+		if len(vad.frame) < vad.frameSize {
+			continue
+		}
+		frame := append([]int16(nil), vad.frame...)
+		vad.frame = vad.frame[:0]
+		loud := rms(frame) > vad.threshold
+		switch {
+		case loud && !vad.speaking:
+			// Onset: keep the quiet lead-in
+			vad.speaking, vad.truncated = true, false
+			for _, quiet := range vad.preRoll {
+				voiced = vad.emit(voiced, quiet)
+			}
+			vad.preRoll = nil
+			voiced = vad.emit(voiced, frame)
+		case loud:
+			// Speech resumed after a short pause
+			for _, quiet := range vad.trailing {
+				voiced = vad.emit(voiced, quiet)
+			}
+			vad.trailing = nil
+			voiced = vad.emit(voiced, frame)
+		case vad.speaking:
+			vad.trailing = append(vad.trailing, frame)
+		default:
+			vad.preRoll = append(vad.preRoll, frame)
+			if len(vad.preRoll) > vad.padding {
+				vad.preRoll = vad.preRoll[1:]
+			}
+		}
+		if !vad.speaking {
+			continue
+		}
+		if vad.length >= vad.maxSamples {
+			vad.truncated = true
+			vad.reset()
+			return voiced, true
+		}
+		if len(vad.trailing) >= vad.silence {
+			// Keep a short tail and drop the rest of the pause
+			for _, quiet := range vad.trailing[:min(vad.padding, len(vad.trailing))] {
+				voiced = vad.emit(voiced, quiet)
+			}
+			vad.reset()
+			return voiced, true
+		}
+	}
+	return voiced, false
+}
+
+// Method reports whether the last utterance was cut at the length limit.
+func (vad *VoiceActivityDetector) Truncated() bool {
+	return vad.truncated
+}
+
+// Method returns the maximum number of samples in an utterance.
+func (vad *VoiceActivityDetector) MaxSamples() int {
+	return vad.maxSamples
+}
+
+// Method appends a frame to the voiced samples, respecting the length limit.
+func (vad *VoiceActivityDetector) emit(voiced, frame []int16) []int16 {
+	room := vad.maxSamples - vad.length
+	if room <= 0 {
+		return voiced
+	}
+	if len(frame) > room {
+		frame = frame[:room]
+	}
+	vad.length += len(frame)
+	return append(voiced, frame...)
+}
+
+// Method prepares the detector for the next utterance.
+func (vad *VoiceActivityDetector) reset() {
+	vad.speaking = false
+	vad.trailing = nil
+	vad.preRoll = nil
+	vad.length = 0
+}
+
+// Method returns the root mean square amplitude of the samples.
+func rms(samples []int16) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, sample := range samples {
+		sum += float64(sample) * float64(sample)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+// Method returns the number of samples in a duration.
+func durationSamples(duration time.Duration, sampleRate int) int {
+	return int(int64(sampleRate) * int64(duration) / int64(time.Second))
+}
+
+// Method returns the duration of a number of samples.
+func samplesDuration(samples, sampleRate int) time.Duration {
+	if sampleRate <= 0 {
+		return 0
+	}
+	return time.Duration(int64(samples) * int64(time.Second) / int64(sampleRate))
+}
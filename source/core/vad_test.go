@@ -0,0 +1,68 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+// Method builds a signal from alternating quiet and loud durations in
+// milliseconds, starting with a quiet one, at 1 sample per millisecond.
+func vadSignal(durations ...int) []int16 {
+	var samples []int16
+	for i, duration := range durations {
+		var amplitude int16
+		if i%2 == 1 {
+			amplitude = 2000
+		}
+		for j := 0; j < duration; j++ {
+			// Alternate the sign so the signal has no DC offset
+			samples = append(samples, amplitude*int16(1-2*(j%2)))
+		}
+	}
+	return samples
+}
+
+func TestVoiceActivityDetector(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     VADConfig
+		signal     []int16
+		utterances []int // Lengths of the ended utterances
+		pending    int   // Samples of an utterance that has not ended
+		truncated  bool
+	}{
+		{"silence", VADConfig{}, vadSignal(2000), nil, 0, false},
+		{"quiet noise", VADConfig{Threshold: 2500}, vadSignal(500, 300, 1000), nil, 0, false},
+		{"speech padded", VADConfig{}, vadSignal(500, 300, 1000), []int{700}, 0, false},
+		{"short pause", VADConfig{}, vadSignal(500, 200, 300, 200, 1000), []int{1100}, 0, false},
+		{"two utterances", VADConfig{SilenceMs: 300}, vadSignal(100, 200, 400, 200, 400), []int{500, 500}, 0, false},
+		{"not ended", VADConfig{}, vadSignal(500, 300, 300), nil, 500, false},
+		{"too long", VADConfig{MaxUtteranceMs: 500}, vadSignal(0, 2000), []int{500, 500, 500, 500}, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vad := NewVoiceActivityDetector(1000, test.config)
+			var utterances []int
+			pending := 0
+			// Feed a frame at a time, as the rest of the samples passed in is
+			// dropped when an utterance ends
+			for start := 0; start < len(test.signal); start += 20 {
+				voiced, ended := vad.Process(test.signal[start:min(start+20, len(test.signal))])
+				pending += len(voiced)
+				if ended {
+					utterances = append(utterances, pending)
+					pending = 0
+				}
+			}
+			if !slices.Equal(utterances, test.utterances) || pending != test.pending {
+				t.Errorf(
+					"got utterances %v and %d pending samples, want %v and %d",
+					utterances, pending, test.utterances, test.pending,
+				)
+			}
+			if vad.Truncated() != test.truncated {
+				t.Errorf("truncated = %v, want %v", vad.Truncated(), test.truncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"time"
+	"sync"
+	"errors"
+	"regexp"
+	"context"
+	"strings"
+	"encoding/json"
+)
+
+// Time to answer a spoken approval if the configuration does not set one.
+const defaultApprovalTimeout = 10 * time.Second
+
+// Answers to a spoken approval, as lowercase words. Anything else is
+// unclear; only the exact approval phrase runs the commands.
+var (
+	approvalPhrases = []string{"yes proceed"}
+	cancelPhrases   = []string{"cancel", "no", "no cancel", "stop", "abort"}
+)
+
+// Commands that only read, run without spoken approval, and git
+// subcommands that only read.
+var (
+	readOnlyCommands = map[string]bool{
+		"cat": true, "date": true, "df": true, "du": true, "echo": true,
+		"file": true, "find": true, "free": true, "grep": true, "head": true,
+		"hostname": true, "id": true, "less": true, "ls": true, "ps": true,
+		"pwd": true, "stat": true, "tail": true, "tree": true, "uname": true,
+		"uptime": true, "wc": true, "which": true, "whoami": true,
+	}
+	readOnlyGitCommands = map[string]bool{
+		"diff": true, "log": true, "show": true, "status": true,
+	}
+)
+
+// Commands that destroy data or take the system down.
+var destructiveCommands = map[string]bool{
+	"chmod": true, "chown": true, "dd": true, "kill": true, "killall": true,
+	"mkfs": true, "poweroff": true, "reboot": true, "rm": true, "rmdir": true,
+	"shred": true, "shutdown": true, "truncate": true,
+}
+
+// Options that make a read-only command change files.
+var writingOptions = map[string]bool{"-delete": true, "-exec": true, "-execdir": true}
+
+// Redirections that write nowhere: to /dev/null or to another descriptor.
+// Any other redirection writes to a file.
+var discardRedirectRegexp = regexp.MustCompile(`[0-9&]?>>?\s*(/dev/null|&[0-9-])`)
+
+// Method marks whether the user's latest request was spoken, so approvals
+// are asked for by voice.
+func (kai *Kai) SetVoiceInput(spoken bool) {
+	kai.voiceInput.Store(spoken)
+}
+
+// Method reports whether the user's latest request was spoken.
+func (kai *Kai) VoiceInput() bool {
+	return kai.voiceInput.Load()
+}
+
+// Method asks for approval by voice: the summary and risk are spoken, then
+// the microphone listens for an explicit "yes, proceed" or "cancel". An
+// unclear answer is asked once more; silence, an interrupted turn or an
+// error cancel. With speaker identification, only the voice of the user who
+// made the request approves; anyone may cancel. An approval too short to
+// recognize their voice is asked for once more, and both answers are
+// recognized together.
+//
+// Parameters:
+//  - summary: A short description of what will run.
+//  - risk: The risk level, e.g. "high"; not mentioned if empty.
+//
+// Returns:
+//  - bool: True only if the user said "yes, proceed" in time.
+func (kai *Kai) approveByVoice(summary, risk string) bool {
+	ctx := kai.currentTurn()
+	requester := kai.verifiedSpeaker()
+	timeout := defaultApprovalTimeout
+	if kai.Config != nil && kai.Config.Approval.TimeoutMs > 0 {
+		timeout = time.Duration(kai.Config.Approval.TimeoutMs) * time.Millisecond
+	}
+	prompt := strings.TrimSpace(summary)
+	if risk != "" {
+		prompt += fmt.Sprintf(" This is %s risk.", risk)
+	}
+	prompt += " Say \"yes, proceed\" to run it, or \"cancel\"."
+	// Approvals whose voice has not been recognized yet
+	var approvals []byte
+	askedAgain := false
+	for attempt := 0; attempt < 2; attempt++ {
+		if kai.OnVoiceApproval != nil {
+			kai.OnVoiceApproval(prompt)
+		}
+		kai.sayApproval(prompt)
+		// Keep Kai's own voice out of the recording
+		kai.waitForSpeech(ctx)
+		answer, err := kai.listenForAnswer(ctx, timeout)
+		if kai.OnVoiceApproval != nil {
+			kai.OnVoiceApproval("")
+		}
+		if err != nil {
+			log.Printf("No spoken approval: %v", err)
+			break
+		}
+		switch matchApproval(answer.text) {
+		case "approve":
+			approvals = append(approvals, answer.audio...)
+			switch kai.answeredBy(requester, approvals, answer.sampleRate) {
+			case voiceMatches:
+				return true
+			case voiceUnclear:
+				log.Println("Approval too short to recognize the voice")
+				if !askedAgain {
+					askedAgain = true
+					attempt--
+				}
+				prompt = "I couldn't recognize your voice from that. " +
+					"Say \"yes, proceed\" once more, or \"cancel\"."
+				continue
+			}
+			approvals = nil
+			log.Println("Ignored an approval in another voice than the request's")
+			prompt = "Only the person who asked can approve this. " +
+				"Say \"yes, proceed\" or \"cancel\"."
+			continue
+		case "cancel":
+			kai.sayApproval("Cancelled.")
+			return false
+		}
+		log.Printf("Unclear approval %q", answer.text)
+		prompt = "I didn't catch that. Say \"yes, proceed\" or \"cancel\"."
+	}
+	kai.sayApproval("No approval, so I cancelled it.")
+	return false
+}
+
+// Method asks for approval by voice before a command runs or a file is
+// changed, if the user spoke the request. The risk is classified and
+// spoken; read-only commands are low risk and run without asking. Plans are
+// approved as a whole when they are reviewed.
+//
+// Parameters:
+//  - itemType: The type of the response item.
+//  - data: The JSON-encoded data of the item.
+//
+// Returns:
+//  - bool: False if the item needed spoken approval and did not get it.
+func (kai *Kai) approveItemByVoice(itemType string, data json.RawMessage) bool {
+	if !kai.VoiceInput() {
+		return true
+	}
+	var item struct {
+		Command string `json:"command"`
+		Path    string `json:"path"`
+	}
+	if err := json.Unmarshal(data, &item); err != nil {
+		return false
+	}
+	switch itemType {
+	case "command":
+		risk := commandRisk(item.Command)
+		if risk == planRiskLevels[0] {
+			return true
+		}
+		return kai.approveByVoice(fmt.Sprintf("I'm about to run: %s.", item.Command), risk)
+	case "file_write":
+		return kai.approveByVoice(fmt.Sprintf("I'm about to overwrite %s.", item.Path), "high")
+	case "file_append":
+		return kai.approveByVoice(fmt.Sprintf("I'm about to add to %s.", item.Path), "medium")
+	case "file_patch":
+		return kai.approveByVoice(fmt.Sprintf("I'm about to edit %s.", item.Path), "medium")
+	}
+	return true
+}
+
+// Method classifies the risk of a shell command line as the highest risk
+// of the commands it runs, like the risk of a plan is that of its riskiest
+// step. Commands that only read are low risk, unknown ones medium, and
+// those that destroy data or run as another user high. Redirecting output
+// into a file makes a command at least medium risk.
+//
+// Parameters:
+//  - line: The shell command line.
+//
+// Returns:
+//  - string: The risk level, one of planRiskLevels.
+func commandRisk(line string) string {
+	highest := 0
+	line = discardRedirectRegexp.ReplaceAllString(line, "")
+	if strings.Contains(line, ">") {
+		highest = 1
+	}
+	for _, command := range commandSeparatorRegexp.Split(line, -1) {
+		words := strings.Fields(command)
+		// Skip prefixes and variable assignments up to the command name
+		for len(words) > 0 && (commandPrefixes[words[0]] || strings.Contains(words[0], "=")) {
+			if words[0] == "sudo" || words[0] == "doas" {
+				highest = 2
+			}
+			words = words[1:]
+		}
+		if len(words) == 0 {
+			continue
+		}
+		highest = max(highest, commandRiskLevel(words))
+	}
+	return planRiskLevels[highest]
+}
+
+// Method returns the index in planRiskLevels of a single command's risk.
+func commandRiskLevel(words []string) int {
+	switch {
+	case destructiveCommands[words[0]]:
+		return 2
+	case words[0] == "git" && len(words) > 1 && readOnlyGitCommands[words[1]]:
+		return 0
+	case !readOnlyCommands[words[0]]:
+		return 1
+	}
+	for _, word := range words[1:] {
+		if writingOptions[word] {
+			return 1
+		}
+	}
+	return 0
+}
+// Method speaks a message of the approval dialog.
+func (kai *Kai) sayApproval(message string) {
+	if err := kai.Speak(message); err != nil {
+		log.Printf("Failed to speak: %v", err)
+	}
+}
+
+// A spoken answer: its transcript and the preprocessed recording, so the
+// voice can be checked.
+type spokenAnswer struct {
+	text       string
+	audio      []byte
+	sampleRate int
+}
+
+// Method records and transcribes a single answer: recording ends after the
+// first pause in speech, or with an error if nothing is said in time.
+//
+// Parameters:
+//  - ctx: Cancels listening, e.g. when the turn is interrupted.
+//  - timeout: How long to wait for the answer to start.
+//
+// Returns:
+//  - spokenAnswer: The transcribed answer.
+//  - error: Error if no answer was given or it could not be transcribed.
+func (kai *Kai) listenForAnswer(
+	ctx context.Context,
+	timeout time.Duration,
+) (spokenAnswer, error) {
+	var config VADConfig
+	if kai.Config != nil {
+		config = kai.Config.VAD
+	}
+	config.AutoStop = true
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	closeStop := func() { stopOnce.Do(func() { close(stop) }) }
+	defer closeStop()
+	// The timeout only applies until the answer starts
+	timer := time.AfterFunc(timeout, closeStop)
+	defer timer.Stop()
+	go func() {
+		select {
+		case <-ctx.Done():
+			closeStop()
+		case <-stop:
+		}
+	}()
+	vad := NewVoiceActivityDetector(kai.SampleRate, config)
+	var audioData []int16
+	err := kai.capture(stop, func(samples []int16) bool {
+		voiced, ended := vad.Process(samples)
+		if len(voiced) > 0 {
+			timer.Stop()
+		}
+		audioData = append(audioData, voiced...)
+		return !ended && len(audioData) < vad.MaxSamples()
+	})
+	if err != nil {
+		return spokenAnswer{}, err
+	}
+	if ctx.Err() != nil {
+		return spokenAnswer{}, ctx.Err()
+	}
+	if len(audioData) == 0 {
+		return spokenAnswer{}, errors.New("timed out")
+	}
+	// Recognized without identifying the speaker, so an answer does not
+	// switch profiles in the middle of a turn
+	audio, sampleRate := kai.preprocess(convertToBytes(audioData), kai.SampleRate)
+	transcript, err := kai.Recognizer.Recognize(ctx, audio, sampleRate)
+	if err != nil {
+		return spokenAnswer{}, err
+	}
+	return spokenAnswer{transcript.Text, audio, sampleRate}, nil
+}
+
+// Method matches an answer to a spoken approval strictly: it approves only
+// if it is exactly "yes, proceed", ignoring case and punctuation, and
+// cancels only on a known cancel phrase.
+//
+// Parameters:
+//  - answer: The transcribed answer.
+//
+// Returns:
+//  - string: "approve", "cancel", or an empty string if unclear.
+func matchApproval(answer string) string {
+	words := strings.Join(wordRegexp.FindAllString(strings.ToLower(answer), -1), " ")
+	for _, phrase := range approvalPhrases {
+		if words == phrase {
+			return "approve"
+		}
+	}
+	for _, phrase := range cancelPhrases {
+		if words == phrase {
+			return "cancel"
+		}
+	}
+	return ""
+}
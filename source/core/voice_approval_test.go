@@ -0,0 +1,153 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"path/filepath"
+	// Local utilities
+	"kai/source/utils"
+)
+
+// Method creates a Kai that hears a spoken answer for every transcript and
+// speaks into files. The answers are tones of the given frequency, standing
+// for a voice, long enough to identify the speaker.
+func newVoiceApprovalKai(
+	t *testing.T,
+	voice float64,
+	transcripts ...string,
+) (*Kai, *FakeRecognizer) {
+	dir := t.TempDir()
+	var inputs []string
+	for i := range transcripts {
+		input := filepath.Join(dir, string(rune('a'+i))+".wav")
+		answer := toneSamples(voice, 24000, 16000, 1600)
+		if err := utils.WriteWavFile(input, utils.SamplesToBytes(answer), 16000); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, input)
+	}
+	audio, err := NewFileAudioIO(inputs, filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recognizer := &FakeRecognizer{Transcripts: transcripts}
+	kai := &Kai{
+		Context:     context.Background(),
+		Config:      &Config{},
+		SampleRate:  16000,
+		Audio:       audio,
+		Recognizer:  recognizer,
+		Synthesizer: toneSynthesizer{},
+	}
+	return kai, recognizer
+}
+
+func TestApproveItemByVoice(t *testing.T) {
+	remove := `{"command": "rm -r build"}`
+	tests := []struct {
+		name     string
+		itemType string
+		data     string
+		spoken   bool
+		answers  []string
+		want     bool
+	}{
+		{"typed request", "command", remove, false, nil, true},
+		{"approved", "command", remove, true, []string{"Yes, proceed."}, true},
+		{"cancelled", "command", remove, true, []string{"cancel"}, false},
+		{"unclear twice", "command", remove, true, []string{"sure", "go ahead"}, false},
+		{"unclear then approved", "file_write", remove, true, []string{"sure", "yes proceed"}, true},
+		{"no answer", "file_patch", remove, true, nil, false},
+		{"reading needs no approval", "file_read", remove, true, nil, true},
+		{"read-only command needs no approval", "command", `{"command": "ls -la | head"}`, true, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kai, recognizer := newVoiceApprovalKai(t, 440, test.answers...)
+			kai.Config.Approval.TimeoutMs = 100
+			kai.SetVoiceInput(test.spoken)
+			if got := kai.approveItemByVoice(test.itemType, []byte(test.data)); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+			if len(recognizer.Transcripts) != 0 {
+				t.Errorf("%d answers were not heard", len(recognizer.Transcripts))
+			}
+		})
+	}
+}
+
+func TestCommandRisk(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"ls -la", "low"},
+		{"git status && git log --oneline | head -5", "low"},
+		{"grep -r TODO . 2>/dev/null", "low"},
+		{"find . -name '*.log' 2>&1 | wc -l", "low"},
+		{"find . -name '*.log' -delete", "medium"},
+		{"ls > files.txt", "medium"},
+		{"make test", "medium"},
+		{"git push", "medium"},
+		{"ls && rm -r build", "high"},
+		{"sudo ls /root", "high"},
+		{"LANG=C cat notes | xargs echo", "medium"},
+	}
+	for _, test := range tests {
+		if got := commandRisk(test.command); got != test.want {
+			t.Errorf("commandRisk(%q) = %q, want %q", test.command, got, test.want)
+		}
+	}
+}
+
+// Method returns the voice embedding of a tone standing for a voice.
+func voiceEmbedding(t *testing.T, kai *Kai, voice float64) []float64 {
+	audio, sampleRate := kai.preprocess(
+		utils.SamplesToBytes(toneSamples(voice, 24000, kai.SampleRate, 0)), kai.SampleRate,
+	)
+	embedding, err := SpeakerEmbedding(utils.BytesToSamples(audio), sampleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return embedding
+}
+
+func TestApprovalRequiresRequesterVoice(t *testing.T) {
+	const ana, ben, stranger = 220, 1500, 700
+	tests := []struct {
+		name      string
+		requester string
+		voice     float64
+		answers   []string
+		want      bool
+	}{
+		{"requester approves", "Ana", ana, []string{"yes proceed"}, true},
+		{"someone else approves", "Ana", ben, []string{"yes proceed", "yes proceed"}, false},
+		{"someone else cancels", "Ana", ben, []string{"cancel"}, false},
+		{"unrecognized requester approves", "", stranger, []string{"yes proceed"}, true},
+		{"enrolled user approves for a stranger", "", ana, []string{"yes proceed", "yes proceed"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kai, recognizer := newVoiceApprovalKai(t, test.voice, test.answers...)
+			kai.Config.Approval.TimeoutMs = 100
+			kai.Config.Speakers.Enabled = true
+			kai.speakers = []SpeakerProfile{
+				{Name: "Ana", Embedding: voiceEmbedding(t, kai, ana)},
+				{Name: "Ben", Embedding: voiceEmbedding(t, kai, ben)},
+			}
+			for i := range kai.speakers {
+				if kai.speakers[i].Name == test.requester {
+					kai.activeSpeaker, kai.activeVerified = &kai.speakers[i], true
+				}
+			}
+			kai.SetVoiceInput(true)
+			if got := kai.approveByVoice("I'm about to run: make.", ""); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+			if len(recognizer.Transcripts) != 0 {
+				t.Errorf("%d answers were not heard", len(recognizer.Transcripts))
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package core
+
+import (
+	"flag"
+	"math"
+	"context"
+	"testing"
+	"math/rand"
+	"path/filepath"
+	// Local utilities
+	"kai/source/utils"
+)
+
+// Regenerates the voice fixtures in testdata/voices.
+var updateVoices = flag.Bool("update-voices", false, "regenerate the voice fixtures")
+
+// Sample rate of the voice fixtures.
+const voiceFixtureRate = 11025
+
+// A synthetic voice: the pitch of its glottal pulses and the length of its
+// vocal tract relative to an adult male's, which scales the formants.
+type fixtureVoice struct {
+	pitch float64
+	tract float64
+}
+
+// Formants of the vowels of an adult male voice, in Hz.
+var vowelFormants = map[byte][4]float64{
+	'a': {730, 1090, 2440, 3400},
+	'e': {530, 1840, 2480, 3500},
+	'i': {270, 2290, 3010, 3600},
+	'o': {570, 840, 2410, 3400},
+	'u': {300, 870, 2240, 3300},
+}
+
+// The fixtures: who speaks, the vowels they say and how long each lasts.
+var voiceFixtures = []struct {
+	file   string
+	voice  fixtureVoice
+	vowels string
+	vowel  float64 // Seconds per vowel
+}{
+	{"ana-enroll-1.wav", fixtureVoice{210, 0.85}, "aeiou", 0.3},
+	{"ana-enroll-2.wav", fixtureVoice{210, 0.85}, "uoiea", 0.3},
+	{"ana-request.wav", fixtureVoice{210, 0.85}, "oaeiua", 0.3},
+	{"ana-yes.wav", fixtureVoice{210, 0.85}, "eoi", 0.15},
+	{"ben-enroll-1.wav", fixtureVoice{110, 1}, "aeiou", 0.3},
+	{"ben-enroll-2.wav", fixtureVoice{110, 1}, "uoiea", 0.3},
+	{"ben-request.wav", fixtureVoice{110, 1}, "oaeiua", 0.3},
+	{"ben-yes.wav", fixtureVoice{110, 1}, "eoi", 0.15},
+	// A stranger whose voice is close to Ben's
+	{"cy-request.wav", fixtureVoice{125, 0.94}, "oaeiua", 0.3},
+}
+
+// Method synthesizes vowels with a source-filter model: glottal pulses
+// with jitter and vibrato, shaped by four formant resonators, preceded and
+// followed by silence.
+func synthesizeVowels(voice fixtureVoice, vowels string, seconds float64, seed int64) []int16 {
+	random := rand.New(rand.NewSource(seed))
+	var speech []float64
+	phase := 0.0
+	for v := 0; v < len(vowels); v++ {
+		length := int(seconds * voiceFixtureRate)
+		signal := make([]float64, length)
+		glottis := 0.0
+		for i := range signal {
+			progress := float64(i) / float64(length)
+			pitch := voice.pitch * (1 + 0.08*math.Sin(2*math.Pi*progress) + 0.01*random.NormFloat64())
+			pulse := 0.02 * random.NormFloat64()
+			if phase += pitch / voiceFixtureRate; phase >= 1 {
+				phase--
+				pulse++
+			}
+			glottis = 0.97*glottis + pulse
+			signal[i] = glottis
+		}
+		for k, formant := range vowelFormants[vowels[v]] {
+			frequency := formant / voice.tract
+			bandwidth := 60 + 30*float64(k)
+			c := -math.Exp(-2 * math.Pi * bandwidth / voiceFixtureRate)
+			b := 2 * math.Exp(-math.Pi*bandwidth/voiceFixtureRate) * math.Cos(2*math.Pi*frequency/voiceFixtureRate)
+			last, beforeLast := 0.0, 0.0
+			for i, x := range signal {
+				y := (1-b-c)*x + b*last + c*beforeLast
+				beforeLast, last = last, y
+				signal[i] = y
+			}
+		}
+		fade := 0.03 * voiceFixtureRate
+		for i := range signal {
+			signal[i] *= math.Min(1, math.Min(float64(i), float64(length-i))/fade)
+		}
+		speech = append(speech, signal...)
+	}
+	peak := 0.0
+	for _, x := range speech {
+		peak = math.Max(peak, math.Abs(x))
+	}
+	silence := voiceFixtureRate / 4
+	samples := make([]int16, silence+len(speech)+silence)
+	for i, x := range speech {
+		samples[silence+i] = int16(x / peak * 12000)
+	}
+	return samples
+}
+
+// Method returns the path of a voice fixture, regenerating the fixtures
+// first if asked to.
+func voiceFixture(t *testing.T, file string) string {
+	t.Helper()
+	if *updateVoices {
+		for i, fixture := range voiceFixtures {
+			samples := synthesizeVowels(fixture.voice, fixture.vowels, fixture.vowel, int64(i+1))
+			path := filepath.Join("testdata", "voices", fixture.file)
+			if err := utils.WriteWavFile(path, utils.SamplesToBytes(samples), voiceFixtureRate); err != nil {
+				t.Fatal(err)
+			}
+		}
+		*updateVoices = false
+	}
+	return filepath.Join("testdata", "voices", file)
+}
+
+// Method creates a Kai with speaker identification that records the given
+// fixtures in order, and enrolls Ana with full and Ben with chat
+// permissions from their enrollment fixtures.
+func newVoicesKai(t *testing.T, files ...string) *Kai {
+	t.Helper()
+	enrollment := []string{"ana-enroll-1.wav", "ana-enroll-2.wav", "ben-enroll-1.wav", "ben-enroll-2.wav"}
+	var inputs []string
+	for _, file := range append(enrollment, files...) {
+		inputs = append(inputs, voiceFixture(t, file))
+	}
+	dir := t.TempDir()
+	audio, err := NewFileAudioIO(inputs, filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kai := &Kai{
+		Context:     context.Background(),
+		SampleRate:  16000,
+		Audio:       audio,
+		Synthesizer: toneSynthesizer{},
+		Config: &Config{Speakers: SpeakerConfig{
+			Enabled:      true,
+			ProfilesFile: filepath.Join(dir, "speakers.json"),
+		}},
+	}
+	for _, profile := range []SpeakerProfile{
+		{Name: "Ana", Policy: PolicyFull},
+		{Name: "Ben", Policy: PolicyChat},
+	} {
+		var recordings [][]byte
+		for i := 0; i < 2; i++ {
+			recording, err := kai.Listen(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recordings = append(recordings, recording)
+		}
+		if err := kai.EnrollSpeaker(profile, recordings); err != nil {
+			t.Fatalf("enrolling %s: %v", profile.Name, err)
+		}
+	}
+	return kai
+}
+
+// Method records the next fixture as a spoken request and identifies its
+// speaker.
+func identifyNext(t *testing.T, kai *Kai) string {
+	t.Helper()
+	recording, err := kai.Listen(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	audio, sampleRate := kai.preprocess(recording, kai.SampleRate)
+	return kai.identifySpeaker(audio, sampleRate)
+}
+
+func TestSpeakerIdentificationOnFixtures(t *testing.T) {
+	kai := newVoicesKai(t, "ana-request.wav", "ben-request.wav", "cy-request.wav")
+	for _, want := range []string{"Ana", "Ben", ""} {
+		if got := identifyNext(t, kai); got != want {
+			t.Errorf("identified %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSpeakerSimilarityMargins(t *testing.T) {
+	kai := newVoicesKai(t, "ana-request.wav", "ben-request.wav", "cy-request.wav")
+	profiles := kai.SpeakerProfiles()
+	var embeddings [][]float64
+	for range []string{"ana", "ben", "cy"} {
+		recording, err := kai.Listen(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		audio, sampleRate := kai.preprocess(recording, kai.SampleRate)
+		embedding, err := SpeakerEmbedding(utils.BytesToSamples(audio), sampleRate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		embeddings = append(embeddings, embedding)
+	}
+	tests := []struct {
+		name    string
+		request int
+		profile int
+		same    bool
+	}{
+		{"Ana matches Ana", 0, 0, true},
+		{"Ben matches Ben", 1, 1, true},
+		{"Ana differs from Ben", 0, 1, false},
+		{"Ben differs from Ana", 1, 0, false},
+		{"stranger differs from Ben", 2, 1, false},
+		{"stranger differs from Ana", 2, 0, false},
+	}
+	for _, test := range tests {
+		similarity := embeddingSimilarity(embeddings[test.request], profiles[test.profile].Embedding)
+		if (similarity >= defaultSpeakerThreshold) != test.same {
+			t.Errorf("%s: similarity %.3f against threshold %.2f", test.name, similarity, defaultSpeakerThreshold)
+		}
+	}
+}
+
+func TestShortRequestUsesUnknownPolicy(t *testing.T) {
+	kai := newVoicesKai(t, "ana-request.wav", "ben-yes.wav")
+	if got := identifyNext(t, kai); got != "Ana" {
+		t.Fatalf("identified %q, want Ana", got)
+	}
+	kai.speakerNote()
+	if err := kai.checkPermission("command"); err != nil {
+		t.Fatalf("Ana refused: %v", err)
+	}
+	// Too short to tell who said it: the conversation stays with Ana, but
+	// her permissions do not apply
+	if got := identifyNext(t, kai); got != "Ana" {
+		t.Errorf("conversation switched to %q after a short request", got)
+	}
+	if note := kai.speakerNote(); note != "" {
+		t.Errorf("got note %q, want the conversation to continue", note)
+	}
+	if err := kai.checkPermission("command"); err == nil {
+		t.Error("a short request ran with the previous speaker's permissions")
+	}
+	if err := kai.checkPermission("plan"); err != nil {
+		t.Errorf("a short request was refused a plan under the review policy: %v", err)
+	}
+}
+
+func TestApprovalVoiceOnFixtures(t *testing.T) {
+	tests := []struct {
+		name      string
+		requester string
+		answers   []string
+		want      bool
+	}{
+		{"requester approves briskly twice", "Ana", []string{"ana-yes.wav", "ana-yes.wav"}, true},
+		{"requester approves at length", "Ana", []string{"ana-request.wav"}, true},
+		{"someone else approves", "Ana", []string{"ben-request.wav", "ben-request.wav"}, false},
+		{"someone else approves briskly", "Ana", []string{"ben-yes.wav", "ben-yes.wav", "ben-yes.wav"}, false},
+		{"brisk approval of an unrecognized request", "", []string{"ben-yes.wav"}, true},
+		{"enrolled user approves for a stranger", "", []string{"ana-request.wav", "ana-request.wav"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kai := newVoicesKai(t, test.answers...)
+			transcripts := make([]string, len(test.answers))
+			for i := range transcripts {
+				transcripts[i] = "yes, proceed"
+			}
+			kai.Recognizer = &FakeRecognizer{Transcripts: transcripts}
+			kai.Config.Approval.TimeoutMs = 100
+			kai.SetVoiceInput(true)
+			for i, profile := range kai.speakers {
+				if profile.Name == test.requester {
+					kai.activeSpeaker, kai.activeVerified = &kai.speakers[i], true
+				}
+			}
+			if got := kai.approveByVoice("I'm about to run: make.", ""); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
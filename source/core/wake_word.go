@@ -0,0 +1,377 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"time"
+	"errors"
+	"regexp"
+	"context"
+	"strings"
+	// Local utilities
+	"kai/source/utils"
+)
+
+// Hands-free listening states reported to the UI.
+const (
+	WakeWordListening  = "listening"  // Waiting for the wake word
+	WakeWordAwake      = "awake"      // Wake word heard, capturing the request
+	WakeWordProcessing = "processing" // Transcribing a segment
+	WakeWordStopped    = "stopped"    // Microphone closed
+)
+
+// Wake word timing.
+const (
+	// Segments shorter than this are clicks or coughs, not the wake word
+	minWakeWordSegment = 250 * time.Millisecond
+	// The start of a segment searched for the wake word; longer segments are
+	// requests spoken in the same breath
+	wakeWordWindow     = 2 * time.Second
+	// How long to wait for the request after a lone wake word
+	wakeWordFollowUp   = 6 * time.Second
+)
+
+// ErrCloudWakeWord is returned when the wake word could only be detected by
+// uploading the microphone's audio to a cloud recognizer.
+var ErrCloudWakeWord = errors.New(
+	"hands-free mode needs an offline speech engine to listen for the wake " +
+	"word; configure whisper or vosk under speech_to_text or " +
+	"wake_word.speech_to_text, or set wake_word.allow_cloud to send what " +
+	"the microphone hears to the cloud recognizer",
+)
+
+// Splits transcripts into lowercase words.
+var wordRegexp = regexp.MustCompile(`[\p{L}\p{N}']+`)
+
+// KeywordSpotter decides whether a short speech segment contains the wake
+// word. It returns the words spoken after the wake word, if any.
+type KeywordSpotter interface {
+	Spot(ctx context.Context, segment []byte, sampleRate int) (bool, string, error)
+}
+
+// WakeWordEvents receives the state changes and captured requests of the
+// hands-free listener.
+type WakeWordEvents struct {
+	OnState     func(state string)
+	OnUtterance func(transcript Transcript)
+}
+
+// Method listens continuously for the wake word until the context is
+// cancelled. Speech is segmented by voice activity detection, which gates
+// on energy, and the start of each segment is checked by the local keyword
+// spotter. Only the request following the wake word is transcribed with
+// Kai's recognizer and passed to OnUtterance; a segment longer than the
+// spotted start is transcribed whole. Audio captured while Kai is speaking
+// is ignored.
+//
+// Parameters:
+//  - ctx: Cancelling the context closes the microphone.
+//  - events: Callbacks for state changes and captured requests.
+//
+// Returns:
+//  - error: ErrCloudWakeWord if no local spotter is available, or an error
+//    encountered while capturing audio.
+func (kai *Kai) ListenForWakeWord(ctx context.Context, events WakeWordEvents) error {
+	spotter, err := kai.wakeWordSpotter()
+	if err != nil {
+		return err
+	}
+	defer kai.closeSpotter(spotter)
+	report := func(state string) {
+		if events.OnState != nil {
+			events.OnState(state)
+		}
+	}
+	vad := NewVoiceActivityDetector(kai.SampleRate, kai.Config.VAD)
+	var segment []int16
+	segments := make(chan []byte, 4)
+	// Handle segments apart from capture so the microphone is never blocked
+	go func() {
+		awake := false
+		var followUp <-chan time.Time
+		for {
+			var segment []byte
+			select {
+			case next, ok := <-segments:
+				if !ok {
+					return
+				}
+				segment = next
+			case <-followUp:
+				// No request followed the wake word
+				awake, followUp = false, nil
+				report(WakeWordListening)
+				continue
+			}
+			report(WakeWordProcessing)
+			if awake {
+				awake, followUp = false, nil
+				transcript, err := kai.Recognize(segment)
+				if err == nil && transcript.Text != "" && events.OnUtterance != nil {
+					events.OnUtterance(transcript)
+				}
+				report(WakeWordListening)
+				continue
+			}
+			duration := samplesDuration(len(segment)/2, kai.SampleRate)
+			if duration < minWakeWordSegment {
+				report(WakeWordListening)
+				continue
+			}
+			start := segment[:min(len(segment), durationSamples(wakeWordWindow, kai.SampleRate)*2)]
+			heard, request, err := spotter.Spot(ctx, start, kai.SampleRate)
+			if err != nil {
+				log.Printf("Wake word detection failed: %v", err)
+			}
+			switch {
+			case heard && len(start) < len(segment):
+				// "Kai, open my browser and ..." in a long breath
+				kai.recognizeWakeRequest(segment, events)
+				report(WakeWordListening)
+			case heard && request != "":
+				// "Kai, open my browser" in a single breath
+				if events.OnUtterance != nil {
+					events.OnUtterance(Transcript{Text: request})
+				}
+				report(WakeWordListening)
+			case heard:
+				awake, followUp = true, time.After(wakeWordFollowUp)
+				report(WakeWordAwake)
+			default:
+				report(WakeWordListening)
+			}
+		}
+	}()
+	defer close(segments)
+	report(WakeWordListening)
+	defer report(WakeWordStopped)
+	return kai.capture(ctx.Done(), func(samples []int16) bool {
+		voiced, ended := vad.Process(samples)
+		segment = append(segment, voiced...)
+		if !ended {
+			return true
+		}
+		completed := segment
+		segment = nil
+		// Drop Kai's own voice picked up by the microphone
+		if kai.IsSpeaking() {
+			return true
+		}
+		select {
+		case segments <- utils.SamplesToBytes(completed):
+		default:
+			log.Println("Dropping speech segment, recognizer is busy")
+		}
+		return true
+	})
+}
+
+// Method transcribes a segment that starts with the wake word with Kai's
+// recognizer and passes the words after the wake word to OnUtterance.
+//
+// Parameters:
+//  - segment: The whole segment.
+//  - events: The callbacks of the hands-free listener.
+func (kai *Kai) recognizeWakeRequest(segment []byte, events WakeWordEvents) {
+	transcript, err := kai.Recognize(segment)
+	if err != nil {
+		log.Printf("Failed to transcribe the request: %v", err)
+		return
+	}
+	var phrase string
+	if kai.Config != nil {
+		phrase = kai.Config.WakeWord.Phrase
+	}
+	// Keep the whole transcript if the recognizer heard the wake word
+	// differently than the spotter
+	if heard, request := matchWakeWord(transcript.Text, phrase); heard {
+		transcript.Text = request
+	}
+	if transcript.Text != "" && events.OnUtterance != nil {
+		events.OnUtterance(transcript)
+	}
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Method reports whether hands-free mode can be used, i.e. whether the wake
+// word can be detected without uploading idle audio.
+//
+// Returns:
+//  - error: ErrCloudWakeWord or a misconfigured wake word engine, if any.
+func (kai *Kai) CheckHandsFree() error {
+	spotter, err := kai.wakeWordSpotter()
+	if err == nil {
+		kai.closeSpotter(spotter)
+	}
+	return err
+}
+
+// Method releases the engine of a spotter created by wakeWordSpotter.
+func (kai *Kai) closeSpotter(spotter KeywordSpotter) {
+	if owned, ok := spotter.(*TranscriptSpotter); ok && spotter != kai.KeywordSpotter {
+		owned.Recognizer.Close()
+	}
+}
+
+// Method selects the keyword spotter: the one set on Kai, a transcript
+// spotter with the offline engine configured for the wake word or with
+// Kai's recognizer if it is offline. A cloud recognizer is only used if the
+// configuration explicitly allows it.
+//
+// Returns:
+//  - KeywordSpotter: The spotter.
+//  - error: ErrCloudWakeWord, or an error creating the wake word engine.
+func (kai *Kai) wakeWordSpotter() (KeywordSpotter, error) {
+	if kai.KeywordSpotter != nil {
+		return kai.KeywordSpotter, nil
+	}
+	var config WakeWordConfig
+	if kai.Config != nil {
+		config = kai.Config.WakeWord
+	}
+	spotter := &TranscriptSpotter{Kai: kai, Phrase: config.Phrase}
+	switch {
+	case config.SpeechToText.Engine != "":
+		var language LanguageConfig
+		if kai.Config != nil {
+			language = kai.Config.Language
+		}
+		recognizer, err := NewRecognizer(config.SpeechToText, language)
+		if err != nil {
+			return nil, fmt.Errorf("wake word engine: %w", err)
+		}
+		if !isLocalRecognizer(recognizer) && !config.AllowCloud {
+			recognizer.Close()
+			return nil, ErrCloudWakeWord
+		}
+		spotter.Recognizer = recognizer
+	case isLocalRecognizer(kai.Recognizer) || config.AllowCloud:
+		spotter.Recognizer = nopCloseRecognizer{kai.Recognizer}
+	default:
+		return nil, ErrCloudWakeWord
+	}
+	return spotter, nil
+}
+
+// Method reports whether a recognizer runs on the local machine.
+func isLocalRecognizer(recognizer Recognizer) bool {
+	switch recognizer.(type) {
+	case *OfflineRecognizer, *FakeRecognizer:
+		return true
+	}
+	return false
+}
+
+// Shares Kai's recognizer with a spotter without letting it close it.
+type nopCloseRecognizer struct {
+	Recognizer
+}
+
+// Method leaves the shared recognizer open.
+func (nopCloseRecognizer) Close() error {
+	return nil
+}
+
+// TranscriptSpotter detects the wake word by transcribing short segments
+// with a local recognizer and matching the first words against the phrase.
+type TranscriptSpotter struct {
+	Kai        *Kai
+	Phrase     string
+	// Transcribes the segments; an offline engine unless cloud spotting
+	// was allowed
+	Recognizer Recognizer
+}
+
+// Method transcribes the segment and looks for the wake word at its start.
+func (s *TranscriptSpotter) Spot(
+	ctx context.Context,
+	segment []byte,
+	sampleRate int,
+) (bool, string, error) {
+	segment, sampleRate = s.Kai.preprocess(segment, sampleRate)
+	transcript, err := s.Recognizer.Recognize(ctx, segment, sampleRate)
+	if err != nil {
+		return false, "", err
+	}
+	if transcript.Language != "" {
+		s.Kai.setLanguage(transcript.Language)
+	}
+	heard, request := matchWakeWord(transcript.Text, s.Phrase)
+	if heard && request != "" {
+		s.Kai.identifySpeaker(segment, sampleRate)
+	}
+	return heard, request, nil
+}
+
+// Method reports whether a transcript starts with the wake word, allowing a
+// leading "hey" or "ok" and near misses such as "kay" for "kai". The words
+// after the wake word are returned as the request.
+//
+// Parameters:
+//  - transcript: The transcribed segment.
+//  - phrase: The wake word; "kai" if empty.
+//
+// Returns:
+//  - bool: True if the wake word was found.
+//  - string: The rest of the transcript after the wake word.
+func matchWakeWord(transcript, phrase string) (bool, string) {
+	if phrase == "" {
+		phrase = "kai"
+	}
+	wake := wordRegexp.FindAllString(strings.ToLower(phrase), -1)
+	words := wordRegexp.FindAllString(strings.ToLower(transcript), -1)
+	// Skip greetings before the wake word
+	start := 0
+	for start < len(words) && start < 2 && isWakeGreeting(words[start]) {
+		start++
+	}
+	if len(wake) == 0 || len(words)-start < len(wake) {
+		return false, ""
+	}
+	for i, word := range wake {
+		if levenshtein(words[start+i], word) > len(word)/3 {
+			return false, ""
+		}
+	}
+	// Return the original wording after the wake word
+	originals := wordRegexp.FindAllStringIndex(transcript, -1)
+	rest := start + len(wake)
+	if rest >= len(originals) {
+		return true, ""
+	}
+	return true, strings.TrimSpace(transcript[originals[rest][0]:])
+}
+
+// Method reports whether a word commonly precedes the wake word.
+func isWakeGreeting(word string) bool {
+	switch word {
+	case "hey", "hi", "ok", "okay", "hello":
+		return true
+	}
+	return false
+}
+
+// Method computes the edit distance between two words.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current := make([]int, len(rb)+1)
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(rb)]
+}
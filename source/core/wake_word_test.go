@@ -0,0 +1,155 @@
+package core
+
+import (
+	"time"
+	"errors"
+	"context"
+	"testing"
+	"path/filepath"
+	// Local utilities
+	"kai/source/utils"
+)
+
+func TestWakeWordSpotter(t *testing.T) {
+	offline := &OfflineRecognizer{Engine: "vosk"}
+	tests := []struct {
+		name       string
+		recognizer Recognizer
+		config     WakeWordConfig
+		local      bool
+		err        error
+	}{
+		{"cloud recognizer", &GoogleRecognizer{}, WakeWordConfig{}, false, ErrCloudWakeWord},
+		{"cloud allowed", &GoogleRecognizer{}, WakeWordConfig{AllowCloud: true}, false, nil},
+		{"offline recognizer", offline, WakeWordConfig{}, true, nil},
+		{
+			"dedicated local engine", &GoogleRecognizer{},
+			WakeWordConfig{SpeechToText: SpeechToTextConfig{Engine: "fake"}}, true, nil,
+		},
+		{
+			"dedicated cloud engine", offline,
+			WakeWordConfig{SpeechToText: SpeechToTextConfig{Engine: "google"}}, false, ErrCloudWakeWord,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kai := &Kai{Recognizer: test.recognizer, Config: &Config{WakeWord: test.config}}
+			spotter, err := kai.wakeWordSpotter()
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if err != nil {
+				if kai.CheckHandsFree() == nil {
+					t.Error("CheckHandsFree allows hands-free mode")
+				}
+				return
+			}
+			defer kai.closeSpotter(spotter)
+			recognizer := spotter.(*TranscriptSpotter).Recognizer
+			if wrapped, ok := recognizer.(nopCloseRecognizer); ok {
+				recognizer = wrapped.Recognizer
+			}
+			if isLocalRecognizer(recognizer) != test.local {
+				t.Errorf("spotter uses %T", recognizer)
+			}
+		})
+	}
+}
+
+func TestWakeWordSpotterFromKai(t *testing.T) {
+	custom := &TranscriptSpotter{Recognizer: &FakeRecognizer{}}
+	kai := &Kai{Recognizer: &GoogleRecognizer{}, KeywordSpotter: custom, Config: &Config{}}
+	spotter, err := kai.wakeWordSpotter()
+	if err != nil || spotter != custom {
+		t.Errorf("got %v, %v, want the spotter set on Kai", spotter, err)
+	}
+}
+
+func TestMatchWakeWord(t *testing.T) {
+	tests := []struct {
+		transcript string
+		phrase     string
+		heard      bool
+		request    string
+	}{
+		{"Kai", "", true, ""},
+		{"Hey Kai, open my browser", "", true, "open my browser"},
+		{"okay kay list files", "kai", true, "list files"},
+		{"I said hi to Kai", "", false, ""},
+		{"computer start", "computer", true, "start"},
+		{"hey jarvis", "computer", false, ""},
+		{"", "", false, ""},
+	}
+	for _, test := range tests {
+		heard, request := matchWakeWord(test.transcript, test.phrase)
+		if heard != test.heard || request != test.request {
+			t.Errorf(
+				"matchWakeWord(%q, %q) = %v, %q, want %v, %q",
+				test.transcript, test.phrase, heard, request, test.heard, test.request,
+			)
+		}
+	}
+}
+
+// spotterFunc spots the wake word with a function.
+type spotterFunc func(segment []byte, sampleRate int) (bool, string, error)
+
+func (spot spotterFunc) Spot(_ context.Context, segment []byte, sampleRate int) (bool, string, error) {
+	return spot(segment, sampleRate)
+}
+
+func TestWakeWordInLongSegment(t *testing.T) {
+	tests := []struct {
+		name    string
+		seconds float64
+		want    string
+	}{
+		{"short segment", 1, "open the"},
+		{"long segment", 4, "open the browser and play some music"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			input := filepath.Join(dir, "request.wav")
+			request := toneSamples(440, int(test.seconds*16000), 16000, 8000)
+			if err := utils.WriteWavFile(input, utils.SamplesToBytes(request), 16000); err != nil {
+				t.Fatal(err)
+			}
+			audio, err := NewFileAudioIO([]string{input}, filepath.Join(dir, "out"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The spotter only hears the start of the request
+			var spotted time.Duration
+			kai := &Kai{
+				Context:    context.Background(),
+				Config:     &Config{},
+				SampleRate: 16000,
+				Audio:      audio,
+				Recognizer: &FakeRecognizer{Transcripts: []string{"Kai, open the browser and play some music"}},
+				KeywordSpotter: spotterFunc(func(segment []byte, sampleRate int) (bool, string, error) {
+					spotted = samplesDuration(len(segment)/2, sampleRate)
+					return true, "open the", nil
+				}),
+			}
+			utterances := make(chan string, 1)
+			err = kai.ListenForWakeWord(context.Background(), WakeWordEvents{
+				OnUtterance: func(transcript Transcript) { utterances <- transcript.Text },
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case got := <-utterances:
+				if got != test.want {
+					t.Errorf("got request %q, want %q", got, test.want)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("no request was passed on")
+			}
+			if spotted > wakeWordWindow {
+				t.Errorf("spotted the wake word in %v, want at most %v", spotted, wakeWordWindow)
+			}
+		})
+	}
+}
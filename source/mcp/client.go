@@ -0,0 +1,271 @@
+package mcp
+
+import (
+	"fmt"
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+)
+
+// Default timeout applied to every request without a deadline.
+const requestTimeout = 60 * time.Second
+
+// ServerConfig describes how to reach a Model Context Protocol server.
+// Servers with a Command are started as subprocesses and spoken to over
+// stdio; servers with a URL use the streamable HTTP transport.
+type ServerConfig struct {
+	Name    string            `json:"name"`
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Transport carries JSON-RPC messages between the client and a server.
+type Transport interface {
+	// Sends a request and waits for the matching response
+	Call(ctx context.Context, request *Message) (*Message, error)
+	// Sends a notification, which has no response
+	Notify(ctx context.Context, notification *Message) error
+	Close() error
+}
+
+// Client is a connection to a single MCP server. The tools and resources
+// discovered on connection are cached.
+type Client struct {
+	Name         string
+	Instructions string
+	Tools        []Tool
+	Resources    []Resource
+	transport    Transport
+	capabilities map[string]json.RawMessage
+	nextID       atomic.Int64
+}
+
+// Method connects to the server described by the configuration, performs the
+// initialization handshake and discovers its tools and resources.
+//
+// Parameters:
+//  - ctx: The context for the connection.
+//  - config: The server configuration.
+//
+// Returns:
+//  - *Client: The connected client.
+//  - error: Error encountered while connecting, if any.
+func Connect(ctx context.Context, config ServerConfig) (*Client, error) {
+	var transport Transport
+	var err error
+	switch {
+	case config.Command != "":
+		transport, err = NewStdioTransport(config.Command, config.Args, config.Env)
+	case config.URL != "":
+		transport = NewHTTPTransport(config.URL, config.Headers)
+	default:
+		return nil, fmt.Errorf("server %q has neither a command nor a url", config.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{Name: config.Name, transport: transport}
+	if err := client.initialize(ctx); err != nil {
+		transport.Close()
+		return nil, fmt.Errorf("failed to initialize %q: %w", config.Name, err)
+	}
+	if err := client.Refresh(ctx); err != nil {
+		transport.Close()
+		return nil, fmt.Errorf("failed to list capabilities of %q: %w", config.Name, err)
+	}
+	return client, nil
+}
+
+// Method closes the connection to the server.
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
+
+// Method re-discovers the server's tools and resources.
+//
+// Parameters:
+//  - ctx: The context for the requests.
+//
+// Returns:
+//  - error: Error encountered while listing, if any.
+func (c *Client) Refresh(ctx context.Context) error {
+	if _, ok := c.capabilities["tools"]; ok {
+		tools, err := c.ListTools(ctx)
+		if err != nil {
+			return err
+		}
+		c.Tools = tools
+	}
+	if _, ok := c.capabilities["resources"]; ok {
+		resources, err := c.ListResources(ctx)
+		if err != nil {
+			return err
+		}
+		c.Resources = resources
+	}
+	return nil
+}
+
+// Method lists every tool of the server, following pagination cursors.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		var page listToolsResult
+		if err := c.call(ctx, "tools/list", cursorParams(cursor), &page); err != nil {
+			return nil, err
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// Method lists every resource of the server, following pagination cursors.
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	var resources []Resource
+	cursor := ""
+	for {
+		var page listResourcesResult
+		if err := c.call(ctx, "resources/list", cursorParams(cursor), &page); err != nil {
+			return nil, err
+		}
+		resources = append(resources, page.Resources...)
+		if page.NextCursor == "" {
+			return resources, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// Method calls a tool with the given arguments.
+//
+// Parameters:
+//  - ctx: The context for the request.
+//  - name: The name of the tool.
+//  - arguments: The JSON-encoded arguments object, may be empty.
+//
+// Returns:
+//  - *ToolResult: The result of the call. Tool failures are reported through
+//    IsError rather than an error.
+//  - error: Error encountered while calling, if any.
+func (c *Client) CallTool(
+	ctx context.Context,
+	name string,
+	arguments json.RawMessage,
+) (*ToolResult, error) {
+	if len(arguments) == 0 || string(arguments) == "null" {
+		arguments = json.RawMessage("{}")
+	}
+	params := map[string]any{"name": name, "arguments": arguments}
+	var result ToolResult
+	if err := c.call(ctx, "tools/call", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Method reads a resource by URI.
+//
+// Parameters:
+//  - ctx: The context for the request.
+//  - uri: The URI of the resource.
+//
+// Returns:
+//  - []ResourceContents: The contents of the resource.
+//  - error: Error encountered while reading, if any.
+func (c *Client) ReadResource(
+	ctx context.Context,
+	uri string,
+) ([]ResourceContents, error) {
+	var result readResourceResult
+	if err := c.call(ctx, "resources/read", map[string]string{"uri": uri}, &result); err != nil {
+		return nil, err
+	}
+	return result.Contents, nil
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Method performs the initialize request and sends the initialized
+// notification.
+func (c *Client) initialize(ctx context.Context) error {
+	params := initializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      implementation{Name: "kai", Version: "1.0.0"},
+	}
+	var result initializeResult
+	if err := c.call(ctx, "initialize", params, &result); err != nil {
+		return err
+	}
+	c.capabilities = result.Capabilities
+	c.Instructions = result.Instructions
+	return c.transport.Notify(ctx, &Message{
+		JSONRPC: jsonRPCVersion,
+		Method:  "notifications/initialized",
+	})
+}
+
+// Method sends a request and decodes its result.
+func (c *Client) call(
+	ctx context.Context,
+	method string,
+	params any,
+	result any,
+) error {
+	encodedParams, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s params: %w", method, err)
+	}
+	id := json.RawMessage(fmt.Sprintf("%d", c.nextID.Add(1)))
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+	}
+	response, err := c.transport.Call(ctx, &Message{
+		JSONRPC: jsonRPCVersion,
+		ID:      &id,
+		Method:  method,
+		Params:  encodedParams,
+	})
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", method, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("%s failed: %w", method, response.Error)
+	}
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+// Method builds the params of a paginated list request.
+func cursorParams(cursor string) map[string]string {
+	if cursor == "" {
+		return map[string]string{}
+	}
+	return map[string]string{"cursor": cursor}
+}
+
+// Method builds the response to a request sent by the server. Only ping is
+// supported; other methods are rejected.
+func replyToServer(request *Message) *Message {
+	reply := &Message{JSONRPC: jsonRPCVersion, ID: request.ID}
+	if request.Method == "ping" {
+		reply.Result = json.RawMessage("{}")
+	} else {
+		reply.Error = &RPCError{Code: -32601, Message: "method not found"}
+	}
+	return reply
+}
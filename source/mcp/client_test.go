@@ -0,0 +1,135 @@
+package mcp
+
+import (
+	"fmt"
+	"context"
+	"testing"
+	"encoding/json"
+)
+
+// Tools of the stub server, served two per page.
+var stubTools = []Tool{
+	{Name: "echo", Description: "Echoes its arguments"},
+	{Name: "add", Description: "Adds two numbers"},
+	{Name: "fail", Description: "Always reports an error"},
+}
+
+// Method answers a request like a small MCP server with tools and
+// resources. Notifications get no answer.
+func stubServer(request *Message) *Message {
+	if request.ID == nil {
+		return nil
+	}
+	response := &Message{JSONRPC: jsonRPCVersion, ID: request.ID}
+	var result any
+	switch request.Method {
+	case "initialize":
+		result = initializeResult{
+			ProtocolVersion: ProtocolVersion,
+			Capabilities: map[string]json.RawMessage{
+				"tools":     json.RawMessage("{}"),
+				"resources": json.RawMessage("{}"),
+			},
+			ServerInfo:   implementation{Name: "stub", Version: "1.0.0"},
+			Instructions: "Use echo for testing.",
+		}
+	case "tools/list":
+		var params struct {
+			Cursor string `json:"cursor"`
+		}
+		json.Unmarshal(request.Params, &params)
+		start := 0
+		fmt.Sscanf(params.Cursor, "page-%d", &start)
+		end := min(start+2, len(stubTools))
+		page := listToolsResult{Tools: stubTools[start:end]}
+		if end < len(stubTools) {
+			page.NextCursor = fmt.Sprintf("page-%d", end)
+		}
+		result = page
+	case "resources/list":
+		result = listResourcesResult{
+			Resources: []Resource{{URI: "memo://greeting", Name: "greeting"}},
+		}
+	case "resources/read":
+		var params struct {
+			URI string `json:"uri"`
+		}
+		json.Unmarshal(request.Params, &params)
+		if params.URI != "memo://greeting" {
+			response.Error = &RPCError{Code: -32002, Message: "resource not found"}
+			return response
+		}
+		result = readResourceResult{Contents: []ResourceContents{
+			{URI: params.URI, MimeType: "text/plain", Text: "Hello"},
+		}}
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		json.Unmarshal(request.Params, &params)
+		text := params.Name + " " + string(params.Arguments)
+		result = ToolResult{
+			Content: []Content{{Type: "text", Text: text}},
+			IsError: params.Name == "fail",
+		}
+	default:
+		response.Error = &RPCError{Code: -32601, Message: "method not found"}
+		return response
+	}
+	response.Result, _ = json.Marshal(result)
+	return response
+}
+
+// Method exercises a connected client against the stub server.
+func testClient(t *testing.T, config ServerConfig) {
+	t.Helper()
+	ctx := context.Background()
+	client, err := Connect(ctx, config)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer client.Close()
+	if client.Instructions != "Use echo for testing." {
+		t.Errorf("instructions are %q", client.Instructions)
+	}
+	// Every page of tools is listed
+	if len(client.Tools) != len(stubTools) {
+		t.Fatalf("got %d tools, want %d: %+v", len(client.Tools), len(stubTools), client.Tools)
+	}
+	for i, tool := range client.Tools {
+		if tool.Name != stubTools[i].Name {
+			t.Errorf("tool %d is %q, want %q", i, tool.Name, stubTools[i].Name)
+		}
+	}
+	if len(client.Resources) != 1 || client.Resources[0].URI != "memo://greeting" {
+		t.Errorf("unexpected resources: %+v", client.Resources)
+	}
+	// Tool calls
+	result, err := client.CallTool(ctx, "echo", json.RawMessage(`{"text":"hi"}`))
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+	if result.IsError || result.Text() != `echo {"text":"hi"}` {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	result, err = client.CallTool(ctx, "echo", nil)
+	if err != nil || result.Text() != "echo {}" {
+		t.Errorf("call without arguments: %+v, %v", result, err)
+	}
+	result, err = client.CallTool(ctx, "fail", nil)
+	if err != nil || !result.IsError {
+		t.Errorf("tool errors should be reported through IsError: %+v, %v", result, err)
+	}
+	// Resources
+	contents, err := client.ReadResource(ctx, "memo://greeting")
+	if err != nil {
+		t.Fatalf("ReadResource: %v", err)
+	}
+	if len(contents) != 1 || contents[0].Text != "Hello" {
+		t.Errorf("unexpected contents: %+v", contents)
+	}
+	if _, err := client.ReadResource(ctx, "memo://missing"); err == nil {
+		t.Error("reading a missing resource should fail")
+	}
+}
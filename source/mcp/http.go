@@ -0,0 +1,191 @@
+package mcp
+
+import (
+	"io"
+	"fmt"
+	"sync"
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"net/http"
+	"encoding/json"
+)
+
+// Header carrying the session ID assigned by a streamable HTTP server.
+const sessionHeader = "Mcp-Session-Id"
+
+// HTTPTransport speaks to a server over the streamable HTTP transport. Each
+// message is POSTed to the endpoint, and the server answers either with a
+// JSON body or with a server-sent event stream.
+type HTTPTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	mutex   sync.Mutex
+	session string
+}
+
+// Method creates a transport for the given endpoint.
+//
+// Parameters:
+//  - url: The MCP endpoint of the server.
+//  - headers: Extra headers sent with every request, e.g. authorization.
+//
+// Returns:
+//  - *HTTPTransport: The transport.
+func NewHTTPTransport(url string, headers map[string]string) *HTTPTransport {
+	return &HTTPTransport{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{},
+	}
+}
+
+// Method sends a request and waits for the response with the same ID.
+func (t *HTTPTransport) Call(
+	ctx context.Context,
+	request *Message,
+) (*Message, error) {
+	resp, err := t.post(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	contentType := resp.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		return t.readEventStream(ctx, resp.Body, request)
+	}
+	var response Message
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &response, nil
+}
+
+// Method sends a notification.
+func (t *HTTPTransport) Notify(ctx context.Context, notification *Message) error {
+	resp, err := t.post(ctx, notification)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Method ends the session on the server, if one was assigned.
+func (t *HTTPTransport) Close() error {
+	t.mutex.Lock()
+	session := t.session
+	t.mutex.Unlock()
+	if session == "" {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	t.setHeaders(req, session)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Method POSTs a message and checks the status of the reply. The session ID
+// assigned by the server is remembered for later requests.
+func (t *HTTPTransport) post(
+	ctx context.Context,
+	message *Message,
+) (*http.Response, error) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, t.url, bytes.NewReader(data),
+	)
+	if err != nil {
+		return nil, err
+	}
+	t.mutex.Lock()
+	session := t.session
+	t.mutex.Unlock()
+	t.setHeaders(req, session)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach server: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf(
+			"server returned %s: %s", resp.Status, strings.TrimSpace(string(body)),
+		)
+	}
+	if id := resp.Header.Get(sessionHeader); id != "" {
+		t.mutex.Lock()
+		t.session = id
+		t.mutex.Unlock()
+	}
+	return resp, nil
+}
+
+// Method applies the configured headers and the session ID to a request.
+func (t *HTTPTransport) setHeaders(req *http.Request, session string) {
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	if session != "" {
+		req.Header.Set(sessionHeader, session)
+	}
+}
+
+// Method reads server-sent events until the response to the request
+// arrives. Requests sent by the server on the stream are answered.
+func (t *HTTPTransport) readEventStream(
+	ctx context.Context,
+	body io.Reader,
+	request *Message,
+) (*Message, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxStdioMessageSize)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data:") {
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			continue
+		}
+		if line != "" || data.Len() == 0 {
+			continue
+		}
+		// A blank line ends the event
+		var message Message
+		err := json.Unmarshal([]byte(data.String()), &message)
+		data.Reset()
+		if err != nil {
+			continue
+		}
+		if message.isResponse() && string(*message.ID) == string(*request.ID) {
+			return &message, nil
+		}
+		if message.isRequest() {
+			if err := t.Notify(ctx, replyToServer(&message)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read event stream: %w", err)
+	}
+	return nil, fmt.Errorf("event stream ended without a response")
+}
@@ -0,0 +1,79 @@
+package mcp
+
+import (
+	"fmt"
+	"context"
+	"testing"
+	"net/http"
+	"encoding/json"
+	"net/http/httptest"
+)
+
+// Method serves the stub server over the streamable HTTP transport. Tool
+// calls are answered with an event stream that first pings the client, the
+// rest with JSON bodies.
+func stubHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var request Message
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.Method == "initialize" {
+			w.Header().Set(sessionHeader, "session-1")
+		} else if r.Header.Get(sessionHeader) != "session-1" {
+			http.Error(w, "missing session", http.StatusBadRequest)
+			return
+		}
+		response := stubServer(&request)
+		switch {
+		case response == nil || (request.ID != nil && request.Method == ""):
+			// Notifications and answers to server requests
+			w.WriteHeader(http.StatusAccepted)
+		case request.Method == "tools/call":
+			w.Header().Set("Content-Type", "text/event-stream")
+			ping, _ := json.Marshal(Message{
+				JSONRPC: jsonRPCVersion, ID: rawID(`"ping-1"`), Method: "ping",
+			})
+			data, _ := json.Marshal(response)
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", ping)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+		}
+	}
+}
+
+// Method returns a JSON-RPC ID.
+func rawID(id string) *json.RawMessage {
+	raw := json.RawMessage(id)
+	return &raw
+}
+
+func TestHTTPTransport(t *testing.T) {
+	server := httptest.NewServer(stubHTTPHandler())
+	defer server.Close()
+	testClient(t, ServerConfig{
+		Name:    "stub",
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	})
+}
+
+func TestHTTPTransportRejected(t *testing.T) {
+	server := httptest.NewServer(stubHTTPHandler())
+	defer server.Close()
+	_, err := Connect(context.Background(), ServerConfig{Name: "stub", URL: server.URL})
+	if err == nil {
+		t.Fatal("connecting without authorization should fail")
+	}
+}
@@ -0,0 +1,141 @@
+package mcp
+
+import (
+	"fmt"
+	"encoding/json"
+)
+
+// Protocol revision requested during initialization.
+const ProtocolVersion = "2025-03-26"
+
+// JSON-RPC version used by every message.
+const jsonRPCVersion = "2.0"
+
+// Message is a JSON-RPC 2.0 request, notification or response. Requests
+// carry an ID and a method, notifications only a method, and responses an
+// ID with either a result or an error.
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError is the error object of a JSON-RPC response.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Error returns the error code and message.
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// Method reports whether the message is a response to a request.
+func (m *Message) isResponse() bool {
+	return m.ID != nil && m.Method == ""
+}
+
+// Method reports whether the message is a request sent by the server.
+func (m *Message) isRequest() bool {
+	return m.ID != nil && m.Method != ""
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Tool is a tool exposed by a server.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+}
+
+// Resource is a resource exposed by a server.
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// Content is one item of a tool result.
+type Content struct {
+	Type     string            `json:"type"`
+	Text     string            `json:"text,omitempty"`
+	MimeType string            `json:"mimeType,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+// ResourceContents holds the contents of a resource, as text or as
+// base64-encoded binary data.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// ToolResult is the result of a tool call.
+type ToolResult struct {
+	Content []Content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+// Text joins the textual content of the result. Non-text content is
+// summarized by its type.
+func (r *ToolResult) Text() string {
+	var text string
+	for i, content := range r.Content {
+		if i > 0 {
+			text += "\n"
+		}
+		switch {
+		case content.Type == "text":
+			text += content.Text
+		case content.Resource != nil && content.Resource.Text != "":
+			text += content.Resource.Text
+		default:
+			text += fmt.Sprintf("[%s content %s]", content.Type, content.MimeType)
+		}
+	}
+	return text
+}
+
+// Payloads of the methods used by the client.
+type initializeParams struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    map[string]any `json:"capabilities"`
+	ClientInfo      implementation `json:"clientInfo"`
+}
+
+type initializeResult struct {
+	ProtocolVersion string                     `json:"protocolVersion"`
+	Capabilities    map[string]json.RawMessage `json:"capabilities"`
+	ServerInfo      implementation             `json:"serverInfo"`
+	Instructions    string                     `json:"instructions,omitempty"`
+}
+
+type implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type listToolsResult struct {
+	Tools      []Tool `json:"tools"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type listResourcesResult struct {
+	Resources  []Resource `json:"resources"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+type readResourceResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
@@ -0,0 +1,205 @@
+package mcp
+
+import (
+	"io"
+	"os"
+	"fmt"
+	"log"
+	"sync"
+	"bufio"
+	"bytes"
+	"time"
+	"context"
+	"os/exec"
+	"encoding/json"
+)
+
+// Largest message accepted from a stdio server.
+const maxStdioMessageSize = 16 * 1024 * 1024
+
+// StdioTransport runs a server as a subprocess and exchanges
+// newline-delimited JSON-RPC messages over its stdin and stdout.
+type StdioTransport struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	writeMu sync.Mutex
+	mutex   sync.Mutex
+	pending map[string]chan *Message
+	done    chan struct{}
+	logged  chan struct{} // Closed once stderr has been read to the end
+	err     error
+}
+
+// Method starts the server process and begins reading its output.
+//
+// Parameters:
+//  - command: The executable to run.
+//  - args: The arguments of the executable.
+//  - env: Extra environment variables for the process.
+//
+// Returns:
+//  - *StdioTransport: The running transport.
+//  - error: Error encountered while starting the process, if any.
+func NewStdioTransport(
+	command string,
+	args []string,
+	env map[string]string,
+) (*StdioTransport, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = os.Environ()
+	for key, value := range env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stdout: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stderr: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", command, err)
+	}
+	transport := &StdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: map[string]chan *Message{},
+		done:    make(chan struct{}),
+		logged:  make(chan struct{}),
+	}
+	go transport.readLoop(stdout)
+	go func() {
+		defer close(transport.logged)
+		logServerOutput(command, stderr)
+	}()
+	return transport, nil
+}
+
+// Method sends a request and waits for the response with the same ID.
+func (t *StdioTransport) Call(
+	ctx context.Context,
+	request *Message,
+) (*Message, error) {
+	key := string(*request.ID)
+	responses := make(chan *Message, 1)
+	t.mutex.Lock()
+	t.pending[key] = responses
+	t.mutex.Unlock()
+	defer func() {
+		t.mutex.Lock()
+		delete(t.pending, key)
+		t.mutex.Unlock()
+	}()
+	if err := t.write(request); err != nil {
+		return nil, err
+	}
+	select {
+	case response := <-responses:
+		return response, nil
+	case <-t.done:
+		return nil, fmt.Errorf("server exited: %v", t.err)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Method sends a notification.
+func (t *StdioTransport) Notify(_ context.Context, notification *Message) error {
+	return t.write(notification)
+}
+
+// Method closes the server's stdin and waits for it to exit, killing it if
+// it does not.
+func (t *StdioTransport) Close() error {
+	t.stdin.Close()
+	select {
+	case <-t.done:
+	case <-time.After(2 * time.Second):
+		t.cmd.Process.Kill()
+		<-t.done
+	}
+	return nil
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Method writes a single message followed by a newline.
+func (t *StdioTransport) write(message *Message) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to server: %w", err)
+	}
+	return nil
+}
+
+// Method reads messages from the server until its output closes, routing
+// responses to their callers and answering server requests.
+func (t *StdioTransport) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxStdioMessageSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var message Message
+		if err := json.Unmarshal(line, &message); err != nil {
+			log.Printf("Ignoring malformed MCP message: %v", err)
+			continue
+		}
+		switch {
+		case message.isResponse():
+			t.deliver(&message)
+		case message.isRequest():
+			if err := t.write(replyToServer(&message)); err != nil {
+				log.Printf("Failed to answer MCP server request: %v", err)
+			}
+		}
+	}
+	t.err = scanner.Err()
+	if t.err != nil {
+		// Keep the server from blocking on a full pipe so it can exit
+		io.Copy(io.Discard, stdout)
+	}
+	// Wait closes the pipes, so stderr must be read to the end first
+	<-t.logged
+	if waitErr := t.cmd.Wait(); t.err == nil {
+		t.err = waitErr
+	}
+	close(t.done)
+}
+
+// Method hands a response to the call waiting for it. Responses nobody is
+// waiting for, e.g. repeated ones or those of calls that gave up, are
+// dropped rather than blocking the read loop.
+func (t *StdioTransport) deliver(response *Message) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	select {
+	case t.pending[string(*response.ID)] <- response:
+	default:
+		log.Printf("Ignoring unexpected MCP response %s", *response.ID)
+	}
+}
+
+// Method forwards the server's stderr to the log.
+func logServerOutput(command string, stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		log.Printf("[%s] %s", command, scanner.Text())
+	}
+	// Drain what is left after an overlong line
+	io.Copy(io.Discard, stderr)
+}
@@ -0,0 +1,91 @@
+package mcp
+
+import (
+	"os"
+	"fmt"
+	"time"
+	"bufio"
+	"context"
+	"strings"
+	"testing"
+	"encoding/json"
+)
+
+// Environment variable that makes the test binary act as a stdio server.
+const stubServerEnv = "KAI_MCP_STUB_SERVER"
+
+// Runs the stub server instead of the tests when started by a stdio test.
+func TestMain(m *testing.M) {
+	if os.Getenv(stubServerEnv) != "" {
+		serveStdio()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// Method serves the stub server over stdin and stdout, logging every
+// request to stderr. Calls of the "repeat" tool get stray responses.
+func serveStdio() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var request Message
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "request %s\n", request.Method)
+		if response := stubServer(&request); response != nil {
+			data, _ := json.Marshal(response)
+			os.Stdout.Write(append(data, '\n'))
+			// Answer twice, and answer a request that was never sent
+			if strings.Contains(string(request.Params), `"repeat"`) {
+				stray := json.RawMessage("999999")
+				unexpected, _ := json.Marshal(&Message{JSONRPC: jsonRPCVersion, ID: &stray})
+				os.Stdout.Write(append(append(data, '\n'), append(unexpected, '\n')...))
+			}
+		}
+	}
+}
+
+func TestStdioTransport(t *testing.T) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testClient(t, ServerConfig{
+		Name:    "stub",
+		Command: executable,
+		Args:    []string{"-test.run=^$"},
+		Env:     map[string]string{stubServerEnv: "1"},
+	})
+}
+
+func TestStdioTransportIgnoresUnexpectedResponses(t *testing.T) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := Connect(context.Background(), ServerConfig{
+		Name:    "stub",
+		Command: executable,
+		Args:    []string{"-test.run=^$"},
+		Env:     map[string]string{stubServerEnv: "1"},
+	})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, tool := range []string{"repeat", "repeat", "echo"} {
+		if _, err := client.CallTool(ctx, tool, nil); err != nil {
+			t.Fatalf("calling %s: %v", tool, err)
+		}
+	}
+}
+
+func TestStdioTransportServerExit(t *testing.T) {
+	_, err := Connect(context.Background(), ServerConfig{Name: "exits", Command: "true"})
+	if err == nil {
+		t.Fatal("connecting to a server that exits should fail")
+	}
+}
@@ -0,0 +1,125 @@
+package ui
+
+import (
+	"sync"
+	"context"
+	"strings"
+	"image/color"
+	// Fyne
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/widget"
+	"fyne.io/fyne/v2/container"
+	// Local imports
+	"kai/source/core"
+)
+
+// Label of the button that dismisses a question.
+const cancelAsk = "Cancel"
+
+// Presents clarifying questions from Kai on the home screen and collects the
+// answer from an option button, the text entry or the microphone. A Cancel
+// button dismisses the question.
+type askPrompt struct {
+	container *fyne.Container
+	question  *canvas.Text
+	options   *fyne.Container
+	answers   chan string
+	dismissed chan struct{}
+	mutex     sync.Mutex
+	pending   bool
+}
+
+// Method creates a hidden question prompt.
+func newAskPrompt() *askPrompt {
+	prompt := &askPrompt{
+		question:  canvas.NewText("", color.Black),
+		options:   container.NewHBox(),
+		answers:   make(chan string, 1),
+		dismissed: make(chan struct{}, 1),
+	}
+	prompt.question.Alignment = fyne.TextAlignCenter
+	prompt.question.TextStyle = fyne.TextStyle{Bold: true}
+	prompt.question.TextSize = 14
+	prompt.container = container.NewVBox(
+		container.NewCenter(prompt.question),
+		container.NewCenter(prompt.options),
+	)
+	prompt.container.Hide()
+	return prompt
+}
+
+// Method shows the question and blocks until it is answered, dismissed, or
+// the context is cancelled. It matches the signature of Kai.OnAsk.
+func (prompt *askPrompt) ask(ctx context.Context, question core.Question) (string, error) {
+	prompt.question.Text = question.Text
+	prompt.question.Refresh()
+	prompt.options.RemoveAll()
+	cancellable := false
+	for _, option := range question.Options {
+		option := option
+		prompt.options.Add(widget.NewButton(option, func() {
+			prompt.answer(option)
+		}))
+		cancellable = cancellable || strings.EqualFold(option, cancelAsk)
+	}
+	if !cancellable {
+		prompt.options.Add(widget.NewButton(cancelAsk, prompt.dismiss))
+	}
+	// Drop answers that arrived after the previous question ended
+	select {
+	case <-prompt.answers:
+	case <-prompt.dismissed:
+	default:
+	}
+	prompt.setPending(true)
+	prompt.container.Show()
+	defer prompt.container.Hide()
+	select {
+	case answer := <-prompt.answers:
+		return answer, nil
+	case <-prompt.dismissed:
+		return "", core.ErrAskCancelled
+	case <-ctx.Done():
+		prompt.setPending(false)
+		return "", ctx.Err()
+	}
+}
+
+// Method dismisses the pending question, if any.
+func (prompt *askPrompt) dismiss() {
+	prompt.mutex.Lock()
+	defer prompt.mutex.Unlock()
+	if !prompt.pending {
+		return
+	}
+	prompt.pending = false
+	prompt.dismissed <- struct{}{}
+}
+
+// Method delivers an answer to the pending question. It returns false if no
+// question is waiting, in which case the input should be handled normally.
+func (prompt *askPrompt) answer(input string) bool {
+	prompt.mutex.Lock()
+	defer prompt.mutex.Unlock()
+	if !prompt.pending {
+		return false
+	}
+	prompt.pending = false
+	prompt.answers <- input
+	return true
+}
+
+// Method marks whether a question is waiting for an answer.
+func (prompt *askPrompt) setPending(pending bool) {
+	prompt.mutex.Lock()
+	defer prompt.mutex.Unlock()
+	prompt.pending = pending
+}
+
+// Method reports whether a question is waiting for an answer.
+func (prompt *askPrompt) isPending() bool {
+	prompt.mutex.Lock()
+	defer prompt.mutex.Unlock()
+	return prompt.pending
+}
@@ -0,0 +1,65 @@
+package ui
+
+import (
+	"log"
+	"slices"
+	// Fyne
+	"fyne.io/fyne/v2/widget"
+	// Local imports
+	"kai/source/core"
+)
+
+// Label of the option that selects the system's default audio device.
+const defaultDeviceLabel = "System default"
+
+// Method creates the form items choosing the microphone and the speakers.
+// A configured device that is not connected stays selectable, so saving
+// the settings while it is unplugged does not forget it.
+//
+// Parameters:
+//  - state: The application state.
+//  - config: The audio configuration the choices are written to.
+//
+// Returns:
+//  - []*widget.FormItem: The device selection items.
+func createAudioDeviceItems(
+	state *core.AppState,
+	config *core.AudioConfig,
+) []*widget.FormItem {
+	devices, err := state.Kai.ListAudioDevices()
+	if err != nil {
+		log.Printf("Failed to list audio devices: %v", err)
+	}
+	inputs := []string{defaultDeviceLabel}
+	outputs := []string{defaultDeviceLabel}
+	for _, device := range devices {
+		if device.Input && !slices.Contains(inputs, device.Name) {
+			inputs = append(inputs, device.Name)
+		}
+		if device.Output && !slices.Contains(outputs, device.Name) {
+			outputs = append(outputs, device.Name)
+		}
+	}
+	inputSelect := createDeviceSelect(inputs, &config.InputDevice)
+	outputSelect := createDeviceSelect(outputs, &config.OutputDevice)
+	return []*widget.FormItem{
+		widget.NewFormItem("Microphone", inputSelect),
+		widget.NewFormItem("Speakers", outputSelect),
+	}
+}
+
+// Method creates a select bound to a device name, where an empty name is
+// the system default.
+func createDeviceSelect(options []string, device *string) *widget.Select {
+	if *device != "" && !slices.Contains(options, *device) {
+		options = append(options, *device)
+	}
+	deviceSelect := widget.NewSelect(options, func(name string) {
+		if name == defaultDeviceLabel {
+			name = ""
+		}
+		*device = name
+	})
+	deviceSelect.SetSelected(valueOr(*device, defaultDeviceLabel))
+	return deviceSelect
+}
@@ -149,7 +149,9 @@ func initializeKai(
 		return
 	}
 	// Attempt to initialize Kai
-	kai, err := core.InitializeKai(enteredAPIKey, state.HistoryFile)
+	kai, err := core.InitializeKai(
+		enteredAPIKey, state.HistoryFile, state.Config,
+	)
 	if err != nil {
 		displayError("Invalid API Key", errorLabel)
 		return
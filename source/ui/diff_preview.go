@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+	// Fyne
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"fyne.io/fyne/v2/container"
+	// Local imports
+	"kai/source/core"
+)
+
+// Method shows a file change made by Kai as a colored diff in a dialog.
+func showFileChange(window fyne.Window, change core.FileChange) {
+	// Build the diff view
+	diffGrid := widget.NewTextGridFromString(change.Diff)
+	if change.Diff == "" {
+		diffGrid.SetText("(no changes)")
+	}
+	colorDiffLines(diffGrid)
+	// Describe the change
+	summary := fmt.Sprintf("%s: %s", change.Operation, change.Path)
+	if change.Backup != "" {
+		summary += fmt.Sprintf("\nBackup saved to %s", change.Backup)
+	}
+	scroll := container.NewScroll(diffGrid)
+	scroll.SetMinSize(fyne.NewSize(800, 400))
+	content := container.NewBorder(widget.NewLabel(summary), nil, nil, nil, scroll)
+	dialog.ShowCustom("File changed", "Close", content, window)
+}
+
+// Method colors added lines green and removed lines red.
+func colorDiffLines(diffGrid *widget.TextGrid) {
+	added := &widget.CustomTextGridStyle{
+		FGColor: color.NRGBA{R: 0, G: 128, B: 0, A: 255},
+	}
+	removed := &widget.CustomTextGridStyle{
+		FGColor: color.NRGBA{R: 200, G: 0, B: 0, A: 255},
+	}
+	for row, line := range diffGrid.Rows {
+		if len(line.Cells) == 0 {
+			continue
+		}
+		switch line.Cells[0].Rune {
+		case '+':
+			diffGrid.SetRowStyle(row, added)
+		case '-':
+			diffGrid.SetRowStyle(row, removed)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	// Fyne
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	// Local imports
+	"kai/source/core"
+)
+
+// Method transcribes audio files dropped on the window, e.g. recorded
+// meetings or voice memos. Each transcript is placed in the text entry,
+// where the user can say what to do with it before sending it.
+//
+// Parameters:
+//  - window: The window files are dropped on.
+//  - state: The application state.
+//  - textEntry: The text entry receiving the transcripts.
+//  - transcriptionText: The label showing the progress.
+func handleAudioFileDrop(
+	window fyne.Window,
+	state *core.AppState,
+	textEntry *widget.Entry,
+	transcriptionText *canvas.Text,
+) {
+	window.SetOnDropped(func(_ fyne.Position, uris []fyne.URI) {
+		var files []string
+		for _, uri := range uris {
+			if uri.Scheme() == "file" && core.IsAudioFile(uri.Path()) {
+				files = append(files, uri.Path())
+			}
+		}
+		if len(files) == 0 {
+			return
+		}
+		go func() {
+			defer updateTranscriptionText(transcriptionText, "")
+			for _, file := range files {
+				name := filepath.Base(file)
+				transcript, err := state.Kai.TranscribeFile(
+					state.Kai.Context, file, func(chunk, chunks int) {
+						updateTranscriptionText(transcriptionText, fmt.Sprintf(
+							"Transcribing %s (%d/%d)...", name, chunk, chunks,
+						))
+					},
+				)
+				if err != nil {
+					log.Printf("Failed to transcribe %s: %v", file, err)
+					dialog.ShowError(fmt.Errorf("failed to transcribe %s: %v", name, err), window)
+					continue
+				}
+				text := fmt.Sprintf("Transcript of %s: %q", name, transcript.Text)
+				if textEntry.Text != "" {
+					text = textEntry.Text + " " + text
+				}
+				updateTextEntry(textEntry, text)
+			}
+		}()
+	})
+}
@@ -14,6 +14,7 @@ type HoldableImageButton struct {
     onPress   func()
     onRelease func()
     size      fyne.Size
+    disabled  bool
 }
 
 // Method creates a new HoldableImageButton.
@@ -35,16 +36,28 @@ func NewHoldableImageButton(
     return button
 }
 
+// Method disables or enables the button. A disabled button is dimmed and 
+// ignores the mouse.
+func (h *HoldableImageButton) SetDisabled(disabled bool) {
+    h.disabled = disabled
+    if disabled {
+        h.image.Translucency = 0.7
+    } else {
+        h.image.Translucency = 0
+    }
+    h.image.Refresh()
+}
+
 // Method is called when the mouse button is pressed.
 func (h *HoldableImageButton) MouseDown(*desktop.MouseEvent) {
-    if h.onPress != nil {
+    if h.onPress != nil && !h.disabled {
         h.onPress()
     }
 }
 
 // Method is called when the mouse button is released.
 func (h *HoldableImageButton) MouseUp(*desktop.MouseEvent) {
-    if h.onRelease != nil {
+    if h.onRelease != nil && !h.disabled {
         h.onRelease()
     }
 }
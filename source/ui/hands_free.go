@@ -0,0 +1,169 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"context"
+	"image/color"
+	// Fyne
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/widget"
+	"fyne.io/fyne/v2/container"
+	// Local imports
+	"kai/source/core"
+)
+
+// Indicator colors of the hands-free states.
+var handsFreeColors = map[string]color.Color{
+	core.WakeWordListening:  color.NRGBA{R: 0, G: 160, B: 0, A: 255},
+	core.WakeWordAwake:      color.NRGBA{R: 230, G: 140, B: 0, A: 255},
+	core.WakeWordProcessing: color.NRGBA{R: 0, G: 120, B: 220, A: 255},
+	core.WakeWordStopped:    color.NRGBA{R: 160, G: 160, B: 160, A: 255},
+}
+
+// Labels of the hands-free states.
+var handsFreeLabels = map[string]string{
+	core.WakeWordListening:  "Listening for \"%s\"",
+	core.WakeWordAwake:      "Listening...",
+	core.WakeWordProcessing: "Processing...",
+	core.WakeWordStopped:    "Hands-free off",
+}
+
+// Controls hands-free listening on the home screen: a state indicator and a
+// toggle that closes the microphone entirely when muted.
+type handsFreeControl struct {
+	container *fyne.Container
+	indicator *canvas.Circle
+	label     *widget.Label
+	toggle    *widget.Button
+	state     *core.AppState
+	onInput   func(transcript core.Transcript)
+	mutex     sync.Mutex
+	enabled   bool
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// Method creates the hands-free control. Captured requests are passed to
+// onInput.
+func newHandsFreeControl(
+	state *core.AppState,
+	onInput func(transcript core.Transcript),
+) *handsFreeControl {
+	control := &handsFreeControl{
+		indicator: canvas.NewCircle(handsFreeColors[core.WakeWordStopped]),
+		label:     widget.NewLabel(handsFreeLabels[core.WakeWordStopped]),
+		state:     state,
+		onInput:   onInput,
+	}
+	control.indicator.Resize(fyne.NewSize(12, 12))
+	control.toggle = widget.NewButtonWithIcon(
+		"Hands-free", theme.MediaRecordIcon(), control.toggleEnabled,
+	)
+	control.container = container.NewHBox(
+		container.NewCenter(container.NewGridWrap(
+			fyne.NewSize(12, 12), control.indicator,
+		)),
+		control.label,
+		control.toggle,
+	)
+	return control
+}
+
+// Method turns hands-free listening on or off. Turning it off is a hard
+// mute: the microphone stream is closed. It stays off, with an explanation,
+// if the wake word cannot be detected locally.
+func (control *handsFreeControl) toggleEnabled() {
+	control.mutex.Lock()
+	enabling := !control.enabled
+	control.mutex.Unlock()
+	if enabling {
+		if err := control.state.Kai.CheckHandsFree(); err != nil {
+			log.Printf("Hands-free mode unavailable: %v", err)
+			if control.state.Kai.OnAudioWarning != nil {
+				control.state.Kai.OnAudioWarning(
+					"Hands-free mode is off: " + err.Error() + ".",
+				)
+			}
+			return
+		}
+	}
+	control.mutex.Lock()
+	control.enabled = enabling
+	enabled := control.enabled
+	control.mutex.Unlock()
+	if enabled {
+		control.toggle.SetText("Mute")
+		control.toggle.SetIcon(theme.VolumeMuteIcon())
+		control.start()
+	} else {
+		control.toggle.SetText("Hands-free")
+		control.toggle.SetIcon(theme.MediaRecordIcon())
+		control.stop()
+	}
+}
+
+// Method starts listening if hands-free mode is enabled and not already
+// running.
+func (control *handsFreeControl) start() {
+	control.mutex.Lock()
+	defer control.mutex.Unlock()
+	if !control.enabled || control.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	control.cancel, control.done = cancel, done
+	go func() {
+		defer close(done)
+		err := control.state.Kai.ListenForWakeWord(ctx, core.WakeWordEvents{
+			OnState:     control.showState,
+			OnUtterance: control.onInput,
+		})
+		if err != nil {
+			log.Printf("Hands-free listening stopped: %v", err)
+			control.showState(core.WakeWordStopped)
+		}
+	}()
+}
+
+// Method closes the microphone and waits for the listener to exit.
+func (control *handsFreeControl) stop() {
+	control.mutex.Lock()
+	cancel, done := control.cancel, control.done
+	control.cancel, control.done = nil, nil
+	control.mutex.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Method pauses listening while the microphone is used by the push-to-talk
+// button.
+func (control *handsFreeControl) suspend() {
+	control.stop()
+}
+
+// Method resumes listening after push-to-talk, if hands-free is enabled.
+func (control *handsFreeControl) resume() {
+	control.start()
+}
+
+// Method updates the indicator for a hands-free state.
+func (control *handsFreeControl) showState(state string) {
+	control.indicator.FillColor = handsFreeColors[state]
+	control.indicator.Refresh()
+	label := handsFreeLabels[state]
+	if state == core.WakeWordListening {
+		phrase := control.state.Config.WakeWord.Phrase
+		if phrase == "" {
+			phrase = "Kai"
+		}
+		label = fmt.Sprintf(label, phrase)
+	}
+	control.label.SetText(label)
+}
@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"log"
 	"time"
+	"strings"
 	"context"
 	"image/color"
 	// Fyne
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
@@ -22,17 +25,56 @@ func ShowHomeScreen(window fyne.Window, state *core.AppState) {
 	// Set background color
 	backgroundColor := color.NRGBA{R: 253, G: 252, B: 251, A: 255}
 	background := canvas.NewRectangle(backgroundColor)
+	// Tell the user how to fix problems with their microphone, also while
+	// the components below are created
+	state.Kai.OnAudioWarning = func(warning string) {
+		dialog.ShowInformation("Microphone", warning, window)
+	}
 	// Create components
-	instructionText := createGreetingText()
-	textEntryContainer := createTextEntryContainer(state)
+	instructionText := createGreetingText(state.Kai.Capabilities)
+	capabilityText := createCapabilityText(state.Kai.Capabilities)
+	replyText := newReplyText()
+	prompt := newAskPrompt()
+	progress := newPlanProgress()
+	transcriptionText := createTranscriptionText()
+	textEntryContainer := createTextEntryContainer(
+		window, state, prompt, transcriptionText, replyText,
+	)
+	// Preview file changes made by Kai
+	state.Kai.OnFileChange = func(change core.FileChange) {
+		showFileChange(window, change)
+	}
+	// Present clarifying questions above the text entry
+	state.Kai.OnAsk = prompt.ask
+	// Review plans in a dialog and show their progress
+	state.Kai.OnPlanReview = func(ctx context.Context, plan core.Plan) (core.Plan, bool) {
+		return reviewPlan(ctx, window, plan)
+	}
+	state.Kai.OnPlanProgress = progress.update
+	// Show replies that cannot be spoken
+	state.Kai.OnSpeechText = replyText.append
+	// Open the voice settings from the top right corner
+	settingsButton := widget.NewButtonWithIcon(
+		"", theme.SettingsIcon(), func() { showVoiceSettings(window, state) },
+	)
+	// Enroll the users sharing this workstation next to it
+	speakersButton := widget.NewButtonWithIcon(
+		"", theme.AccountIcon(), func() { showSpeakerSettings(window, state) },
+	)
 	// Set the content of the window
 	window.SetContent(
 		container.NewStack(
 			background,
 			container.NewVBox(
+				container.NewHBox(layout.NewSpacer(), speakersButton, settingsButton),
 				layout.NewSpacer(),
 				container.NewCenter(instructionText),
+				capabilityText,
 				layout.NewSpacer(),
+				replyText.container,
+				progress.container,
+				prompt.container,
+				container.NewCenter(transcriptionText),
 				textEntryContainer,
 			),
 		),
@@ -43,50 +85,100 @@ func ShowHomeScreen(window fyne.Window, state *core.AppState) {
 /* ************************************************************************* */
 /* ************************************************************************* */
 
-// Method creates the greeting text label.
-func createGreetingText() *canvas.Text {
-	greetingText := canvas.NewText(
-		"How can I assist you today? Hold the button and speak, " + 
-		"or type a message and press Enter.",
-		color.Black,
-	)
+// Method creates the greeting text label, only mentioning the microphone 
+// if it can be used.
+func createGreetingText(capabilities core.Capabilities) *canvas.Text {
+	greeting := "How can I assist you today? Hold the button and speak, " + 
+		"or type a message and press Enter."
+	if !capabilities.Microphone.Available {
+		greeting = "How can I assist you today? Type a message and press Enter."
+	}
+	greetingText := canvas.NewText(greeting, color.Black)
 	greetingText.Alignment = fyne.TextAlignCenter
 	greetingText.TextStyle = fyne.TextStyle{Bold: true}
 	greetingText.TextSize = 16
 	return greetingText
 }
 
-// Method creates the text entry field and its container.
-func createTextEntryContainer(state *core.AppState) *fyne.Container {
+// Method creates a label explaining which voice features are turned off and 
+// why, hidden if everything is available.
+func createCapabilityText(capabilities core.Capabilities) *widget.Label {
+	var notes []string
+	if !capabilities.Microphone.Available {
+		notes = append(notes, fmt.Sprintf(
+			"Voice input is off: %s.", capabilities.Microphone.Reason,
+		))
+	}
+	if !capabilities.SpeechOutput.Available {
+		notes = append(notes, fmt.Sprintf(
+			"Replies are shown as text: %s.", capabilities.SpeechOutput.Reason,
+		))
+	}
+	label := widget.NewLabel("")
+	label.Alignment = fyne.TextAlignCenter
+	label.Wrapping = fyne.TextWrapWord
+	if len(notes) == 0 {
+		label.Hide()
+		return label
+	}
+	notes = append(notes, "Typing works as usual.")
+	label.SetText(strings.Join(notes, " "))
+	return label
+}
+
+// Method creates the text entry field and its container. Audio files dropped 
+// on the window are transcribed into the text entry.
+func createTextEntryContainer(
+	window fyne.Window,
+	state *core.AppState,
+	prompt *askPrompt,
+	transcriptionText *canvas.Text,
+	replyText *replyText,
+) *fyne.Container {
 	// Create the text entry
 	textEntry := widget.NewEntry()
 	textEntry.SetPlaceHolder("Type your message here...")
 	textEntry.OnSubmitted = func(input string) {
-
-		// TODO: Convert to method
-		// Cancel the previous process if it exists
-		if state.CancelProcessFunc != nil {
-			state.CancelProcessFunc()
+		state.Kai.SetVoiceInput(false)
+		if !prompt.isPending() {
+			// Not spoken, so not from the speaker identified last
+			state.Kai.ForgetSpeaker()
+			replyText.clear()
 		}
-		// Create a new context for the new process
-		ctx, cancel := context.WithCancel(context.Background())
-		state.ProcessContext = ctx
-		state.CancelProcessFunc = cancel
-		// Clear the text field after processing
-		updateTextEntry(textEntry, "")
-		// Process input in a separate goroutine
-		go processUserInput(state, input, textEntry)
-
+		submitUserInput(state, input, textEntry, prompt)
 	}
+	handleAudioFileDrop(window, state, textEntry, transcriptionText)
 	// Set the size of the text entry to be shorter
 	textEntryContainer := container.NewVBox(
 		container.NewPadded(container.NewStack(textEntry)),
 	)
+	// Create the hands-free control, feeding requests like typed input
+	handsFree := newHandsFreeControl(state, func(transcript core.Transcript) {
+		submitTranscript(state, transcript, textEntry, prompt)
+	})
+	microphone := state.Kai.Capabilities.Microphone.Available
+	if !microphone {
+		handsFree.toggle.Disable()
+	} else if state.Config.WakeWord.Enabled {
+		handsFree.toggleEnabled()
+	}
+	// Lend the microphone to spoken approvals and show what to say
+	state.Kai.OnVoiceApproval = func(prompt string) {
+		if prompt != "" {
+			handsFree.suspend()
+		} else {
+			handsFree.resume()
+		}
+		updateTranscriptionText(transcriptionText, prompt)
+	}
 	// Create the Listen button
-	button := createListenButton(state, textEntry)
+	button := createListenButton(
+		state, textEntry, prompt, transcriptionText, handsFree,
+	)
+	button.SetDisabled(!microphone)
 	// Combine the text entry and button in an HBox layout with padding
 	content := container.NewBorder(
-		nil, nil, nil, button,
+		nil, nil, handsFree.container, button,
 		textEntryContainer,
 	)
 	// Align the container to the bottom with padding
@@ -109,6 +201,9 @@ func createTranscriptionText() *canvas.Text {
 func createListenButton(
 	state *core.AppState, 
 	textEntry *widget.Entry,
+	prompt *askPrompt,
+	transcriptionText *canvas.Text,
+	handsFree *handsFreeControl,
 ) *gui_elements.HoldableImageButton {
 	imageResource := loadImageResource("resources/assets/microphone.svg")
 	var pressStartTime time.Time
@@ -118,10 +213,19 @@ func createListenButton(
 		imageResource, fyne.NewSize(40, 40),
 		func() { // Button press event
 			fmt.Println("Button pressed, starting recording...")
+			// Release the microphone held by hands-free listening
+			handsFree.suspend()
+			// Barge in: stop Kai talking over the user. A question's turn
+			// continues, since the recording answers it.
+			if prompt.isPending() {
+				state.Kai.StopSpeaking()
+			} else {
+				state.Kai.Interrupt()
+			}
 			handleListenButtonPress(
 				state, &pressStartTime, 
 				&stopChan, &audioData, 
-				textEntry,
+				textEntry, prompt, transcriptionText, handsFree,
 			)
 		},
 		func() { // Button release event
@@ -140,11 +244,74 @@ func createListenButton(
 func loadImageResource(imageLocation string) fyne.Resource {
 	imageResource, err := fyne.LoadResourceFromPath(imageLocation)
 	if err != nil {
-		log.Fatalf("Failed to load image: %v", err)
+		log.Printf("Failed to load image: %v", err)
+		return theme.BrokenImageIcon()
 	}
 	return imageResource
 }
 
+// Method submits typed or spoken input. A pending question is answered 
+// directly; otherwise the previous request is cancelled and the input is 
+// processed as a new request.
+func submitUserInput(
+	state *core.AppState,
+	input string,
+	textEntry *widget.Entry,
+	prompt *askPrompt,
+) {
+	// Answer a pending question instead of starting a new request
+	if prompt.answer(input) {
+		updateTextEntry(textEntry, "")
+		return
+	}
+	// Cancel the previous process if it exists, silencing Kai mid-sentence
+	if state.CancelProcessFunc != nil {
+		state.CancelProcessFunc()
+	}
+	state.Kai.Interrupt()
+	// Create a new context for the new process
+	ctx, cancel := context.WithCancel(context.Background())
+	state.ProcessContext = ctx
+	state.CancelProcessFunc = cancel
+	// Clear the text field after processing
+	updateTextEntry(textEntry, "")
+	// Process input in a separate goroutine
+	go processUserInput(state, input, textEntry)
+}
+
+// Method submits a spoken request like typed input, so its plans are
+// approved by voice. If the recognizer was unsure what it heard, the
+// candidate transcripts are offered first: the user picks one, says which,
+// or edits the text entry and presses Enter. Answers to a pending question
+// are submitted directly.
+func submitTranscript(
+	state *core.AppState,
+	transcript core.Transcript,
+	textEntry *widget.Entry,
+	prompt *askPrompt,
+) {
+	state.Kai.SetVoiceInput(true)
+	if prompt.isPending() || !state.Kai.NeedsConfirmation(transcript) {
+		submitUserInput(state, transcript.Text, textEntry, prompt)
+		return
+	}
+	// Offer the most likely transcript for editing
+	updateTextEntry(textEntry, transcript.Text)
+	go func() {
+		input, err := state.Kai.ConfirmTranscript(transcript)
+		if err != nil {
+			log.Printf("Failed to confirm the transcript: %v", err)
+			return
+		}
+		if input == "" {
+			// Cancelled; leave the current request alone
+			updateTextEntry(textEntry, "")
+			return
+		}
+		submitUserInput(state, input, textEntry, prompt)
+	}()
+}
+
 // Method processes the user input text.
 func processUserInput(
 	state *core.AppState, 
@@ -162,7 +329,12 @@ func processUserInput(
 			// Send transcription to Gemini API
 			responseJSON, err := state.Kai.Reason(input)
 			if err != nil {
-				log.Fatalf("Failed to send command scan message: %v", err)
+				log.Printf("Failed to send message: %v", err)
+				message := fmt.Sprintf("Sorry, I couldn't reach the AI service: %v", err)
+				if err := state.Kai.Speak(message); err != nil {
+					log.Printf("Failed to speak: %v", err)
+				}
+				return
 			}
 			// Process the JSON response
 			state.Kai.Respond(responseJSON)
@@ -178,6 +350,12 @@ func updateTextEntry(textEntry *widget.Entry, transcript string) {
 	textEntry.Refresh()
 }
 
+// Method updates the live transcription label.
+func updateTranscriptionText(transcriptionText *canvas.Text, transcript string) {
+	transcriptionText.Text = transcript
+	transcriptionText.Refresh()
+}
+
 /* ************************************************************************* */
 /* ************************************************************************* */
 /* ************************************************************************* */
@@ -189,27 +367,44 @@ func handleListenButtonPress(
 	stopChan *chan struct{},
 	audioData *[]byte,
 	textEntry *widget.Entry,
+	prompt *askPrompt,
+	transcriptionText *canvas.Text,
+	handsFree *handsFreeControl,
 ) {
 	*pressStartTime = time.Now()
 	// Create a new stop channel for each recording
 	*stopChan = make(chan struct{})
 	// Start recording audio when the button is pressed
 	go func() {
+		// Transcribe while recording, showing interim results live
+		chunks := make(chan []byte, 1024)
+		var transcript core.Transcript
+		var recognizeErr error
+		recognized := make(chan struct{})
+		go func() {
+			defer close(recognized)
+			transcript, recognizeErr = state.Kai.RecognizeStream(
+				chunks, func(interim string) {
+					updateTranscriptionText(transcriptionText, interim)
+				},
+			)
+		}()
 		var err error
-		*audioData, err = state.Kai.Listen(*stopChan)
+		*audioData, err = state.Kai.ListenStream(*stopChan, chunks)
+		// Give the microphone back to hands-free listening
+		handsFree.resume()
 		if err != nil {
-			log.Fatalf("Failed to record audio: %v", err)
-		}
-
-		// Cancel the previous process if it exists
-		if state.CancelProcessFunc != nil {
-			state.CancelProcessFunc()
+			// Keep the window open; typing still works
+			log.Printf("Failed to record audio: %v", err)
+			<-recognized
+			updateTranscriptionText(transcriptionText, "")
+			if state.Kai.OnAudioWarning != nil {
+				state.Kai.OnAudioWarning(fmt.Sprintf(
+					"Recording failed: %v. You can type your message instead.", err,
+				))
+			}
+			return
 		}
-		
-		// Create a new context for the new process
-		ctx, cancel := context.WithCancel(context.Background())
-		state.ProcessContext = ctx
-		state.CancelProcessFunc = cancel
 
 		// TODO: Testing
 		fmt.Println("Recording complete")
@@ -223,18 +418,17 @@ func handleListenButtonPress(
 
 		// Clear the text field after processing
 		updateTextEntry(textEntry, "")
-		// Transcribe the recorded audio
-		transcript, err := state.Kai.Recognize(*audioData)
-		if err != nil {
-			log.Println("Failed to transcribe audio:", err)
+		// Wait for the final transcript
+		<-recognized
+		updateTranscriptionText(transcriptionText, "")
+		if recognizeErr != nil {
+			log.Println("Failed to transcribe audio:", recognizeErr)
 			// TODO: You might want to add some fallback behavior here, 
 			// such as retrying or notifying the user
 			return
 		}
-
-		// Process the transcription in a separate goroutine
-		go processUserInput(state, transcript, textEntry)
-
+		// Process the transcription like typed input
+		submitTranscript(state, transcript, textEntry, prompt)
 	}()
 }
 
@@ -47,10 +47,12 @@ func primeCommandScan(window fyne.Window, state *core.AppState) {
 	}
 	responseJSON, err := state.Kai.Reason(systemScanPrimer)
 	if err != nil {
-		log.Fatalf("Failed to send command scan message: %v", err)
+		// Chat can still work once the AI service is reachable again
+		log.Printf("Failed to send command scan message: %v", err)
+	} else {
+		// Process the JSON response
+		state.Kai.Respond(responseJSON)
 	}
-	// Process the JSON response
-	state.Kai.Respond(responseJSON)
 	// After the scan, transition to the Home screen
 	ShowHomeScreen(window, state)
 }
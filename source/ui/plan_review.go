@@ -0,0 +1,143 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+	"context"
+	"strings"
+	"image/color"
+	// Fyne
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"fyne.io/fyne/v2/container"
+	// Local imports
+	"kai/source/core"
+)
+
+// Colors used to highlight the risk level of plan steps.
+var riskColors = map[string]color.Color{
+	"low":    color.NRGBA{R: 0, G: 128, B: 0, A: 255},
+	"medium": color.NRGBA{R: 200, G: 120, B: 0, A: 255},
+	"high":   color.NRGBA{R: 200, G: 0, B: 0, A: 255},
+}
+
+// Method shows a plan for review and blocks until the user approves or
+// cancels it, or the context is cancelled because the turn was interrupted.
+// Steps can be edited, reordered and dropped before approval.
+func reviewPlan(ctx context.Context, window fyne.Window, plan core.Plan) (core.Plan, bool) {
+	result := make(chan bool, 1)
+	stepList := container.NewVBox()
+	// Rebuild the step list whenever the plan changes
+	var refresh func()
+	refresh = func() {
+		stepList.RemoveAll()
+		for i := range plan.Steps {
+			stepList.Add(createPlanStepRow(&plan, i, refresh))
+		}
+		stepList.Refresh()
+	}
+	refresh()
+	scroll := container.NewVScroll(stepList)
+	scroll.SetMinSize(fyne.NewSize(800, 400))
+	content := container.NewBorder(
+		widget.NewLabel(plan.Summary), nil, nil, nil, scroll,
+	)
+	review := dialog.NewCustomConfirm(
+		"Review plan", "Approve", "Cancel", content,
+		func(approved bool) { result <- approved },
+		window,
+	)
+	review.Show()
+	select {
+	case approved := <-result:
+		return plan, approved
+	case <-ctx.Done():
+		review.Hide()
+		return plan, false
+	}
+}
+
+// Method creates the row of a single plan step, with its risk, editable
+// commands and controls to move or drop it.
+func createPlanStepRow(plan *core.Plan, index int, refresh func()) fyne.CanvasObject {
+	step := plan.Steps[index]
+	title := canvas.NewText(
+		fmt.Sprintf("%d. %s [%s risk]", index+1, step.Title, step.Risk),
+		riskColors[step.Risk],
+	)
+	title.TextStyle = fyne.TextStyle{Bold: true}
+	commands := widget.NewMultiLineEntry()
+	commands.SetText(strings.Join(step.Commands, "\n"))
+	commands.SetMinRowsVisible(len(step.Commands))
+	commands.OnChanged = func(text string) {
+		plan.Steps[index].Commands = core.ParsePlanCommands(text)
+	}
+	up := widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() {
+		*plan = core.MovePlanStep(*plan, index, index-1)
+		refresh()
+	})
+	down := widget.NewButtonWithIcon("", theme.MoveDownIcon(), func() {
+		*plan = core.MovePlanStep(*plan, index, index+1)
+		refresh()
+	})
+	drop := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+		*plan = core.DropPlanStep(*plan, index)
+		refresh()
+	})
+	if index == 0 {
+		up.Disable()
+	}
+	if index == len(plan.Steps)-1 {
+		down.Disable()
+	}
+	return container.NewBorder(
+		title, nil, nil, container.NewVBox(up, down, drop), commands,
+	)
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Shows the progress of an executing plan on the home screen.
+type planProgress struct {
+	container *fyne.Container
+	label     *widget.Label
+	bar       *widget.ProgressBar
+}
+
+// Method creates a hidden plan progress indicator.
+func newPlanProgress() *planProgress {
+	progress := &planProgress{
+		label: widget.NewLabel(""),
+		bar:   widget.NewProgressBar(),
+	}
+	progress.label.Alignment = fyne.TextAlignCenter
+	progress.container = container.NewVBox(progress.label, progress.bar)
+	progress.container.Hide()
+	return progress
+}
+
+// Method updates the indicator. It matches the signature of
+// Kai.OnPlanProgress.
+func (progress *planProgress) update(step core.PlanProgress) {
+	progress.container.Show()
+	progress.label.SetText(fmt.Sprintf(
+		"Step %d of %d: %s (%s)", step.Step, step.Total, step.Title, step.Status,
+	))
+	completed := step.Step - 1
+	if step.Status != "running" {
+		completed = step.Step
+	}
+	progress.bar.SetValue(float64(completed) / float64(step.Total))
+	// Hide the indicator once the plan has finished or failed
+	if step.Status == "failed" || (step.Status == "done" && step.Step == step.Total) {
+		go func() {
+			<-time.After(3 * time.Second)
+			progress.container.Hide()
+		}()
+	}
+}
@@ -0,0 +1,54 @@
+package ui
+
+import (
+	"sync"
+	"strings"
+	// Fyne
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+	"fyne.io/fyne/v2/container"
+)
+
+// Number of sentences kept on screen; older ones scroll away.
+const maxReplySentences = 8
+
+// Shows Kai's replies on the home screen when they cannot be spoken. The
+// replies of the current request are kept until the next one starts.
+type replyText struct {
+	container *fyne.Container
+	label     *widget.Label
+	mutex     sync.Mutex
+	sentences []string
+}
+
+// Method creates a hidden reply label.
+func newReplyText() *replyText {
+	reply := &replyText{label: widget.NewLabel("")}
+	reply.label.Wrapping = fyne.TextWrapWord
+	reply.container = container.NewPadded(reply.label)
+	reply.container.Hide()
+	return reply
+}
+
+// Method adds a sentence to the reply. It matches the signature of
+// Kai.OnSpeechText.
+func (reply *replyText) append(text string) {
+	reply.mutex.Lock()
+	reply.sentences = append(reply.sentences, strings.TrimSpace(text))
+	if len(reply.sentences) > maxReplySentences {
+		reply.sentences = reply.sentences[len(reply.sentences)-maxReplySentences:]
+	}
+	text = strings.Join(reply.sentences, " ")
+	reply.mutex.Unlock()
+	reply.label.SetText(text)
+	reply.container.Show()
+}
+
+// Method clears the reply before a new request.
+func (reply *replyText) clear() {
+	reply.mutex.Lock()
+	reply.sentences = nil
+	reply.mutex.Unlock()
+	reply.label.SetText("")
+	reply.container.Hide()
+}
@@ -0,0 +1,157 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+	// Fyne
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"fyne.io/fyne/v2/container"
+	// Local imports
+	"kai/source/core"
+)
+
+// Length of each enrollment recording.
+const enrollmentRecording = 5 * time.Second
+
+// Sentences read aloud to enroll a voice, one per recording.
+var enrollmentSentences = []string{
+	"The quick brown fox jumps over the lazy dog near the river bank.",
+	"Please open my project folder and show me the latest changes.",
+	"On Tuesday we measured seven samples before the lab closed at noon.",
+}
+
+// Method shows the enrolled speakers and a form to enroll a new one. Each
+// speaker gets their own conversation history, memory and permission
+// policy, selected by recognizing their voice.
+func showSpeakerSettings(window fyne.Window, state *core.AppState) {
+	speakers := state.Config.Speakers
+	enabled := widget.NewCheck("Recognize speakers by voice", func(checked bool) {
+		speakers.Enabled = checked
+	})
+	enabled.SetChecked(speakers.Enabled)
+	// Enrolled profiles with a button to remove each
+	profiles := container.NewVBox()
+	var refresh func()
+	refresh = func() {
+		profiles.RemoveAll()
+		for _, profile := range state.Kai.SpeakerProfiles() {
+			name := profile.Name
+			policy := profile.Policy
+			if policy == "" {
+				policy = core.PolicyFull
+			}
+			remove := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+				if err := state.Kai.RemoveSpeaker(name); err != nil {
+					dialog.ShowError(err, window)
+				}
+				refresh()
+			})
+			profiles.Add(container.NewBorder(
+				nil, nil, nil, remove,
+				widget.NewLabel(fmt.Sprintf("%s (%s)", name, policy)),
+			))
+		}
+		if len(profiles.Objects) == 0 {
+			profiles.Add(widget.NewLabel("No speakers enrolled yet."))
+		}
+	}
+	refresh()
+	// Enrollment form
+	name := widget.NewEntry()
+	name.SetPlaceHolder("Name")
+	memory := widget.NewMultiLineEntry()
+	memory.SetPlaceHolder("What Kai should know about them")
+	memory.SetMinRowsVisible(2)
+	policy := widget.NewSelect(core.SpeakerPolicies, nil)
+	policy.SetSelected(core.PolicyFull)
+	var recordings [][]byte
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+	var record *widget.Button
+	// The sentences repeat if the user records more than three
+	nextSentence := func() string {
+		return enrollmentSentences[len(recordings)%len(enrollmentSentences)]
+	}
+	showNextSentence := func() {
+		if len(recordings) < len(enrollmentSentences) {
+			status.SetText(fmt.Sprintf("Press Record and read: %q", nextSentence()))
+		} else {
+			status.SetText(fmt.Sprintf(
+				"%d recordings. Press Enroll to save, or Record and read: %q",
+				len(recordings), nextSentence(),
+			))
+		}
+	}
+	showNextSentence()
+	record = widget.NewButtonWithIcon("Record", theme.MediaRecordIcon(), func() {
+		record.Disable()
+		status.SetText(fmt.Sprintf("Recording... read: %q", nextSentence()))
+		go func() {
+			defer record.Enable()
+			stop := make(chan struct{})
+			time.AfterFunc(enrollmentRecording, func() { close(stop) })
+			audioData, err := state.Kai.Listen(stop)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("failed to record: %v", err), window)
+				showNextSentence()
+				return
+			}
+			recordings = append(recordings, audioData)
+			showNextSentence()
+		}()
+	})
+	enroll := widget.NewButton("Enroll", func() {
+		profile := core.SpeakerProfile{
+			Name:   name.Text,
+			Memory: memory.Text,
+			Policy: policy.Selected,
+		}
+		if err := state.Kai.EnrollSpeaker(profile, recordings); err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		name.SetText("")
+		memory.SetText("")
+		recordings = nil
+		showNextSentence()
+		refresh()
+	})
+	form := widget.NewForm(
+		widget.NewFormItem("Name", name),
+		widget.NewFormItem("Memory", memory),
+		widget.NewFormItem("Permissions", policy),
+	)
+	heading := func(text string) *widget.Label {
+		return widget.NewLabelWithStyle(
+			text, fyne.TextAlignLeading, fyne.TextStyle{Bold: true},
+		)
+	}
+	content := container.NewVBox(
+		enabled,
+		heading("Enrolled speakers"),
+		profiles,
+		widget.NewSeparator(),
+		heading("Enroll a speaker"),
+		form,
+		status,
+		container.NewHBox(record, enroll),
+	)
+	settings := dialog.NewCustomConfirm(
+		"Speakers", "Save", "Cancel", content,
+		func(save bool) {
+			if !save {
+				return
+			}
+			state.Config.Speakers = speakers
+			if err := core.SaveConfig(state.ConfigFile, state.Config); err != nil {
+				dialog.ShowError(fmt.Errorf("failed to save settings: %v", err), window)
+			}
+		},
+		window,
+	)
+	settings.Resize(fyne.NewSize(560, 520))
+	settings.Show()
+}
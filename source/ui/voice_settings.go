@@ -0,0 +1,200 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	// Fyne
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"fyne.io/fyne/v2/container"
+	// Local imports
+	"kai/source/core"
+)
+
+// Sample sentences played when previewing a voice, by base language.
+var previewPhrases = map[string]string{
+	"en": "Hi, I'm Kai. How can I help you today?",
+	"es": "Hola, soy Kai. ¿En qué puedo ayudarte hoy?",
+}
+
+// Label of the list entry that selects the language's default voice.
+const defaultVoiceLabel = "Default voice"
+
+// Method shows the voice settings: a browser of the engine's voices with
+// preview playback, the speech parameters and the audio devices. Saving
+// applies the settings to every following synthesis and recording and
+// writes them to the configuration file.
+func showVoiceSettings(window fyne.Window, state *core.AppState) {
+	config := state.Config.Voice
+	audio := state.Config.Audio
+	var voices []core.VoiceInfo
+	// Voice browser, filtered by language
+	status := widget.NewLabel("Loading voices...")
+	voiceList := widget.NewList(
+		func() int { return len(voices) + 1 },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			label := defaultVoiceLabel
+			if id > 0 {
+				voice := voices[id-1]
+				label = fmt.Sprintf(
+					"%s (%s, %d kHz)", voice.Name, voice.Gender, voice.SampleRate/1000,
+				)
+			}
+			item.(*widget.Label).SetText(label)
+		},
+	)
+	selectVoice := func() {
+		voiceList.UnselectAll()
+		for i, voice := range voices {
+			if voice.Name == config.Name {
+				voiceList.Select(i + 1)
+				return
+			}
+		}
+		voiceList.Select(0)
+	}
+	voiceList.OnSelected = func(id widget.ListItemID) {
+		if id == 0 {
+			config.Name = ""
+		} else {
+			config.Name = voices[id-1].Name
+		}
+	}
+	loadVoices := func(language string) {
+		status.SetText("Loading voices...")
+		go func() {
+			listed, err := state.Kai.ListVoices(language)
+			if err != nil {
+				log.Printf("Failed to list voices: %v", err)
+				status.SetText("Voices unavailable: the default voice is used.")
+				listed = nil
+			} else {
+				status.SetText(fmt.Sprintf("%d voices", len(listed)))
+			}
+			voices = listed
+			voiceList.Refresh()
+			selectVoice()
+		}()
+	}
+	languages := append(state.Config.Language.All(), "All languages")
+	languageSelect := widget.NewSelect(languages, func(language string) {
+		if language == "All languages" {
+			language = ""
+		}
+		loadVoices(language)
+	})
+	// Speech parameters
+	genderSelect := widget.NewSelect(
+		[]string{"male", "female", "neutral"},
+		func(gender string) { config.Gender = gender },
+	)
+	genderSelect.SetSelected(valueOr(config.Gender, "male"))
+	rate := createParameterSlider(
+		0.25, 4, 0.05, &config.SpeakingRate, 1, "%.2fx",
+	)
+	pitch := createParameterSlider(
+		-20, 20, 0.5, &config.Pitch, 0, "%+.1f semitones",
+	)
+	volume := createParameterSlider(
+		-16, 16, 0.5, &config.VolumeGainDb, 0, "%+.1f dB",
+	)
+	preview := widget.NewButtonWithIcon("Preview", theme.MediaPlayIcon(), func() {
+		voice := core.Voice{
+			Language:     state.Kai.Language(),
+			Name:         config.Name,
+			Gender:       config.Gender,
+			SpeakingRate: config.SpeakingRate,
+			Pitch:        config.Pitch,
+			VolumeGainDb: config.VolumeGainDb,
+		}
+		// Preview in the voice's own language
+		if language := core.VoiceLanguage(config.Name); language != "" {
+			voice.Language = language
+		}
+		phrase := valueOr(
+			previewPhrases[strings.Split(voice.Language, "-")[0]],
+			previewPhrases["en"],
+		)
+		go func() {
+			if err := state.Kai.PreviewVoice(voice, phrase); err != nil {
+				log.Printf("Failed to preview voice: %v", err)
+			}
+		}()
+	})
+	form := widget.NewForm(
+		widget.NewFormItem("Gender", genderSelect),
+		widget.NewFormItem("Speaking rate", rate),
+		widget.NewFormItem("Pitch", pitch),
+		widget.NewFormItem("Volume", volume),
+	)
+	for _, item := range createAudioDeviceItems(state, &audio) {
+		form.AppendItem(item)
+	}
+	browser := container.NewBorder(
+		container.NewBorder(nil, nil, nil, status, languageSelect),
+		nil, nil, nil, voiceList,
+	)
+	content := container.NewBorder(
+		nil, container.NewVBox(form, preview), nil, nil, browser,
+	)
+	settings := dialog.NewCustomConfirm(
+		"Voice and audio settings", "Save", "Cancel", content,
+		func(save bool) {
+			if !save {
+				return
+			}
+			state.Config.Voice = config
+			state.Config.Audio = audio
+			if err := core.SaveConfig(state.ConfigFile, state.Config); err != nil {
+				dialog.ShowError(fmt.Errorf("failed to save settings: %v", err), window)
+			}
+		},
+		window,
+	)
+	settings.Resize(fyne.NewSize(640, 560))
+	settings.Show()
+	// Start with the voices of the language Kai currently speaks
+	initial := languages[0]
+	for _, language := range languages {
+		if language == state.Kai.Language() {
+			initial = language
+		}
+	}
+	languageSelect.SetSelected(initial)
+}
+
+// Method creates a slider bound to a speech parameter, with a label showing
+// its value. A zero parameter starts at the default value.
+func createParameterSlider(
+	minimum, maximum, step float64,
+	value *float64,
+	defaultValue float64,
+	format string,
+) fyne.CanvasObject {
+	slider := widget.NewSlider(minimum, maximum)
+	slider.Step = step
+	label := widget.NewLabel("")
+	slider.OnChanged = func(changed float64) {
+		*value = changed
+		label.SetText(fmt.Sprintf(format, changed))
+	}
+	if *value == 0 {
+		slider.SetValue(defaultValue)
+	} else {
+		slider.SetValue(*value)
+	}
+	slider.OnChanged(slider.Value)
+	return container.NewBorder(nil, nil, nil, label, slider)
+}
+
+// Method returns the value, or the fallback if the value is empty.
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,338 @@
+package utils
+
+import (
+	"os"
+	"fmt"
+	"math"
+	"encoding/binary"
+)
+
+// Method converts 16-bit little-endian PCM bytes to samples.
+func BytesToSamples(audioData []byte) []int16 {
+	samples := make([]int16, len(audioData)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(audioData[2*i:]))
+	}
+	return samples
+}
+
+// Method converts samples to 16-bit little-endian PCM bytes.
+func SamplesToBytes(samples []int16) []byte {
+	audioData := make([]byte, len(samples)*2)
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(audioData[2*i:], uint16(sample))
+	}
+	return audioData
+}
+
+// Anti-aliasing filter applied before downsampling: the cutoff as a
+// fraction of the target rate, e.g. 7.2 kHz for 16 kHz, and the filter
+// taps on each side per unit of the rate ratio.
+const (
+	antiAliasCutoff      = 0.45
+	antiAliasTapsPerSide = 24
+)
+
+// Method resamples mono 16-bit PCM using linear interpolation. When
+// downsampling, the input is low-pass filtered first so frequencies above
+// the new Nyquist rate do not fold back into the audible band.
+//
+// Parameters:
+//  - samples: The input samples.
+//  - fromRate: The sample rate of the input.
+//  - toRate: The desired sample rate.
+//
+// Returns:
+//  - []int16: The resampled samples.
+func Resample(samples []int16, fromRate, toRate int) []int16 {
+	if fromRate == toRate || fromRate <= 0 || toRate <= 0 || len(samples) == 0 {
+		return samples
+	}
+	input := make([]float64, len(samples))
+	for i, sample := range samples {
+		input[i] = float64(sample)
+	}
+	if taps := lowPassTaps(fromRate, toRate); taps != nil {
+		input = filterCentered(input, taps)
+	}
+	length := int(int64(len(samples)) * int64(toRate) / int64(fromRate))
+	resampled := make([]int16, length)
+	step := float64(fromRate) / float64(toRate)
+	for i := range resampled {
+		position := float64(i) * step
+		index := int(position)
+		if index >= len(input)-1 {
+			resampled[i] = clampSample(input[len(input)-1])
+			continue
+		}
+		fraction := position - float64(index)
+		resampled[i] = clampSample(input[index]*(1-fraction) + input[index+1]*fraction)
+	}
+	return resampled
+}
+
+// Resampler resamples a stream of mono 16-bit PCM buffer by buffer using
+// linear interpolation, low-pass filtering it first when downsampling.
+// Unlike Resample it carries its position and filter state over from one
+// buffer to the next, so buffer boundaries neither click nor drift.
+type Resampler struct {
+	step     float64   // Input samples per output sample
+	position float64   // Position of the next output sample, 0 being previous
+	previous float64   // Last filtered sample of the previous buffer
+	taps     []float64 // Anti-aliasing filter; nil when upsampling
+	history  []float64 // Last input samples, for the filter
+}
+
+// Method creates a resampler between two sample rates.
+func NewResampler(fromRate, toRate int) *Resampler {
+	taps := lowPassTaps(fromRate, toRate)
+	return &Resampler{
+		step:     float64(fromRate) / float64(toRate),
+		position: 1,
+		taps:     taps,
+		history:  make([]float64, max(len(taps)-1, 0)),
+	}
+}
+
+// Method resamples the next buffer of the stream. The anti-aliasing filter
+// delays the stream by half its length, under a millisecond.
+func (r *Resampler) Process(samples []int16) []int16 {
+	if r.step == 1 || len(samples) == 0 {
+		return samples
+	}
+	filtered := r.filter(samples)
+	// Index 0 is the last sample of the previous buffer
+	at := func(index int) float64 {
+		if index == 0 {
+			return r.previous
+		}
+		return filtered[index-1]
+	}
+	last := float64(len(filtered))
+	resampled := make([]int16, 0, int(last/r.step)+1)
+	for ; r.position <= last; r.position += r.step {
+		index := int(r.position)
+		fraction := r.position - float64(index)
+		value := at(index)
+		if fraction > 0 {
+			value = value*(1-fraction) + at(index+1)*fraction
+		}
+		resampled = append(resampled, clampSample(value))
+	}
+	r.position -= last
+	r.previous = filtered[len(filtered)-1]
+	return resampled
+}
+
+// Method applies the anti-aliasing filter to the next buffer, continuing
+// from the samples of the previous buffers.
+func (r *Resampler) filter(samples []int16) []float64 {
+	filtered := make([]float64, len(samples))
+	if r.taps == nil {
+		for i, sample := range samples {
+			filtered[i] = float64(sample)
+		}
+		return filtered
+	}
+	input := r.history
+	for _, sample := range samples {
+		input = append(input, float64(sample))
+	}
+	for i := range filtered {
+		sum := 0.0
+		for k, tap := range r.taps {
+			sum += tap * input[i+k]
+		}
+		filtered[i] = sum
+	}
+	r.history = append(r.history[:0], input[len(input)-len(r.history):]...)
+	return filtered
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Method designs the low-pass filter applied before downsampling, a
+// Blackman-windowed sinc with unity gain. Longer filters are used for larger
+// rate ratios, so the transition band stays narrow in absolute terms.
+//
+// Parameters:
+//  - fromRate: The sample rate of the input.
+//  - toRate: The sample rate after resampling.
+//
+// Returns:
+//  - []float64: The filter taps, or nil if the rate is not reduced.
+func lowPassTaps(fromRate, toRate int) []float64 {
+	if fromRate <= 0 || toRate <= 0 || toRate >= fromRate {
+		return nil
+	}
+	ratio := float64(fromRate) / float64(toRate)
+	half := int(math.Ceil(antiAliasTapsPerSide * ratio))
+	// Cutoff in cycles per input sample
+	cutoff := antiAliasCutoff / ratio
+	taps := make([]float64, 2*half+1)
+	sum := 0.0
+	for i := range taps {
+		x := float64(i - half)
+		sinc := 2 * cutoff
+		if x != 0 {
+			sinc = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		phase := 2 * math.Pi * float64(i) / float64(len(taps)-1)
+		window := 0.42 - 0.5*math.Cos(phase) + 0.08*math.Cos(2*phase)
+		taps[i] = sinc * window
+		sum += taps[i]
+	}
+	for i := range taps {
+		taps[i] /= sum
+	}
+	return taps
+}
+
+// Method filters a whole signal without delaying it, repeating the edge
+// samples beyond its ends.
+func filterCentered(input, taps []float64) []float64 {
+	half := len(taps) / 2
+	filtered := make([]float64, len(input))
+	for i := range filtered {
+		sum := 0.0
+		for k, tap := range taps {
+			index := min(max(i+k-half, 0), len(input)-1)
+			sum += tap * input[index]
+		}
+		filtered[i] = sum
+	}
+	return filtered
+}
+
+// Method rounds a value to the nearest 16-bit sample, clipping it.
+func clampSample(value float64) int16 {
+	return int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(value))))
+}
+
+// Method writes mono 16-bit PCM data to a WAV file.
+//
+// Parameters:
+//  - filename: The path of the file to create.
+//  - audioData: The 16-bit little-endian PCM data.
+//  - sampleRate: The sample rate of the data.
+//
+// Returns:
+//  - error: Error encountered while writing, if any.
+func WriteWavFile(filename string, audioData []byte, sampleRate int) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	defer file.Close()
+	// WAV file header
+	var header = []byte{
+		'R', 'I', 'F', 'F',
+		0, 0, 0, 0, // ChunkSize (to be filled later)
+		'W', 'A', 'V', 'E',
+		'f', 'm', 't', ' ',
+		16, 0, 0, 0, // Subchunk1Size (16 for PCM)
+		1, 0, // AudioFormat (1 for PCM)
+		1, 0, // NumChannels (1 for mono)
+		0, 0, 0, 0, // SampleRate (to be filled later)
+		0, 0, 0, 0, // ByteRate (SampleRate * NumChannels * BitsPerSample/8)
+		2, 0, // BlockAlign (NumChannels * BitsPerSample/8)
+		16, 0, // BitsPerSample (16 bits)
+		'd', 'a', 't', 'a',
+		0, 0, 0, 0, // Subchunk2Size (to be filled later)
+	}
+	// Fill in the sizes and rates
+	binary.LittleEndian.PutUint32(header[4:], uint32(36+len(audioData)))
+	binary.LittleEndian.PutUint32(header[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(header[28:], uint32(sampleRate*2))
+	binary.LittleEndian.PutUint32(header[40:], uint32(len(audioData)))
+	// Write the header and audio data to the file
+	if _, err := file.Write(header); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+	if _, err := file.Write(audioData); err != nil {
+		return fmt.Errorf("failed to write audio data: %v", err)
+	}
+	return nil
+}
+
+// Method decodes a 16-bit PCM WAV file, mixing multiple channels down to
+// mono.
+//
+// Parameters:
+//  - data: The contents of the WAV file.
+//
+// Returns:
+//  - []int16: The mono samples.
+//  - int: The sample rate of the file.
+//  - error: Error if the data is not 16-bit PCM WAV.
+func DecodeWav(data []byte) ([]int16, int, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, fmt.Errorf("not a WAV file")
+	}
+	var channels, sampleRate, bitsPerSample int
+	var format uint16
+	for offset := 12; offset+8 <= len(data); {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		body := data[offset+8:]
+		// Streamed files may leave the data size unset
+		if size > len(body) || size < 0 {
+			size = len(body)
+		}
+		body = body[:size]
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, 0, fmt.Errorf("invalid WAV format chunk")
+			}
+			format = binary.LittleEndian.Uint16(body[0:])
+			channels = int(binary.LittleEndian.Uint16(body[2:]))
+			sampleRate = int(binary.LittleEndian.Uint32(body[4:]))
+			bitsPerSample = int(binary.LittleEndian.Uint16(body[14:]))
+		case "data":
+			// 0xFFFE is WAVE_FORMAT_EXTENSIBLE, used by some encoders for PCM
+			if (format != 1 && format != 0xFFFE) || bitsPerSample != 16 || channels < 1 {
+				return nil, 0, fmt.Errorf(
+					"unsupported WAV encoding: format %d, %d bits", format, bitsPerSample,
+				)
+			}
+			return DownmixSamples(BytesToSamples(body), channels), sampleRate, nil
+		}
+		// Chunks are padded to an even size
+		offset += 8 + size + size%2
+	}
+	return nil, 0, fmt.Errorf("WAV file has no audio data")
+}
+
+// Method averages interleaved multi-channel samples into mono.
+func DownmixSamples(samples []int16, channels int) []int16 {
+	if channels <= 1 {
+		return samples
+	}
+	mono := make([]int16, len(samples)/channels)
+	for i := range mono {
+		var sum int
+		for channel := 0; channel < channels; channel++ {
+			sum += int(samples[i*channels+channel])
+		}
+		mono[i] = int16(sum / channels)
+	}
+	return mono
+}
+
+// Method changes the volume of samples by a gain in decibels, clipping at
+// the limits of 16-bit audio.
+func ApplyGain(samples []int16, gainDb float64) []int16 {
+	if gainDb == 0 {
+		return samples
+	}
+	factor := math.Pow(10, gainDb/20)
+	adjusted := make([]int16, len(samples))
+	for i, sample := range samples {
+		value := float64(sample) * factor
+		adjusted[i] = int16(max(min(value, math.MaxInt16), math.MinInt16))
+	}
+	return adjusted
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+// Amplitude of the test tones.
+const toneAmplitude = 10000
+
+func tone(frequency float64, rate int, seconds float64) []int16 {
+	samples := make([]int16, int(float64(rate)*seconds))
+	for i := range samples {
+		samples[i] = int16(toneAmplitude * math.Sin(2*math.Pi*frequency*float64(i)/float64(rate)))
+	}
+	return samples
+}
+
+// Gain in dB of the samples relative to the test tone, skipping the edges.
+func gain(samples []int16) float64 {
+	samples = samples[len(samples)/10 : len(samples)-len(samples)/10]
+	sum := 0.0
+	for _, sample := range samples {
+		sum += float64(sample) * float64(sample)
+	}
+	rms := math.Sqrt(sum / float64(len(samples)))
+	return 20 * math.Log10(rms/(toneAmplitude/math.Sqrt2))
+}
+
+// Method resamples a whole signal buffer by buffer.
+func streamResample(samples []int16, fromRate, toRate, buffer int) []int16 {
+	resampler := NewResampler(fromRate, toRate)
+	var resampled []int16
+	for start := 0; start < len(samples); start += buffer {
+		resampled = append(resampled, resampler.Process(samples[start:min(start+buffer, len(samples))])...)
+	}
+	return resampled
+}
+
+func TestResampleAntiAliasing(t *testing.T) {
+	for _, fromRate := range []int{44100, 48000} {
+		for _, streaming := range []bool{false, true} {
+			resample := func(samples []int16) []int16 {
+				if streaming {
+					return streamResample(samples, fromRate, 16000, 441)
+				}
+				return Resample(samples, fromRate, 16000)
+			}
+			// 10 kHz is above the 8 kHz Nyquist rate and would alias to 6 kHz
+			if got := gain(resample(tone(10000, fromRate, 1))); got > -40 {
+				t.Errorf("%d Hz, streaming %v: 10 kHz tone at %.1f dB, want below -40 dB", fromRate, streaming, got)
+			}
+			for _, frequency := range []float64{300, 1000, 4000} {
+				if got := gain(resample(tone(frequency, fromRate, 1))); math.Abs(got) > 0.5 {
+					t.Errorf("%d Hz, streaming %v: %.0f Hz tone at %.1f dB, want 0 dB", fromRate, streaming, frequency, got)
+				}
+			}
+		}
+	}
+}
+
+func TestResamplerLength(t *testing.T) {
+	samples := tone(1000, 44100, 2)
+	resampled := streamResample(samples, 44100, 16000, 1000)
+	if want := len(samples) * 16000 / 44100; math.Abs(float64(len(resampled)-want)) > 1 {
+		t.Errorf("got %d samples, want %d", len(resampled), want)
+	}
+	upsampled := streamResample(tone(1000, 16000, 1), 16000, 48000, 160)
+	if got := gain(upsampled); math.Abs(got) > 0.5 {
+		t.Errorf("upsampled 1 kHz tone at %.1f dB, want 0 dB", got)
+	}
+}
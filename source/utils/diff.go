@@ -0,0 +1,509 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Number of unchanged lines shown around each change in a unified diff.
+const diffContextLines = 3
+
+// Upper bound on the LCS table size; larger inputs produce a single hunk
+// that replaces the whole file instead of a minimal diff.
+const maxDiffCells = 4000000
+
+// Marker following a diff line that is the last line of a file without a
+// trailing newline.
+const noNewlineMarker = `\ No newline at end of file`
+
+// Appended to the last line of content without a trailing newline while
+// diffing, so a change of only the final newline still shows up as a change.
+const noNewlineSentinel = "\x00"
+
+// Matches a unified diff hunk header, e.g. "@@ -12,5 +12,7 @@".
+var hunkHeaderRegexp = regexp.MustCompile(
+	`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`,
+)
+
+// A single line operation within a diff: ' ' (keep), '-' (delete) or
+// '+' (insert). NoNewline marks the last line of a file that does not end
+// with a newline.
+type diffLine struct {
+	Op        byte
+	Text      string
+	NoNewline bool
+}
+
+// Hunk is one contiguous block of changes in a unified diff.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []diffLine
+}
+
+// PatchConflict describes a hunk that could not be applied.
+type PatchConflict struct {
+	Hunk     int
+	Line     int
+	Expected []string
+	Actual   []string
+}
+
+// PatchConflictError is returned when one or more hunks of a patch do not
+// match the target content. No changes are applied in that case.
+type PatchConflictError struct {
+	Conflicts []PatchConflict
+}
+
+// Error returns a short summary of the conflicting hunks.
+func (e *PatchConflictError) Error() string {
+	return fmt.Sprintf("patch failed: %d conflicting hunk(s)", len(e.Conflicts))
+}
+
+// Report returns a human (and model) readable description of every conflict,
+// showing the lines the hunk expected next to the lines actually found.
+func (e *PatchConflictError) Report() string {
+	var report strings.Builder
+	for _, conflict := range e.Conflicts {
+		fmt.Fprintf(
+			&report, "Hunk %d does not apply at line %d.\n",
+			conflict.Hunk, conflict.Line,
+		)
+		report.WriteString("Expected:\n")
+		for _, line := range conflict.Expected {
+			report.WriteString("  " + line + "\n")
+		}
+		report.WriteString("Found:\n")
+		for _, line := range conflict.Actual {
+			report.WriteString("  " + line + "\n")
+		}
+	}
+	return strings.TrimRight(report.String(), "\n")
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Method returns a unified diff between the old and new content of a file.
+// An empty string is returned when both contents are identical.
+//
+// Parameters:
+//  - path: The file path used in the diff headers.
+//  - oldContent: The original file content.
+//  - newContent: The updated file content.
+//
+// Returns:
+//  - string: The unified diff.
+func UnifiedDiff(path, oldContent, newContent string) string {
+	if oldContent == newContent {
+		return ""
+	}
+	oldLines := markNoNewline(oldContent)
+	newLines := markNoNewline(newContent)
+	ops := diffLines(oldLines, newLines)
+	var diff strings.Builder
+	fmt.Fprintf(&diff, "--- a/%s\n+++ b/%s\n", path, path)
+	for _, hunk := range groupHunks(ops) {
+		fmt.Fprintf(
+			&diff, "@@ -%s +%s @@\n",
+			hunkRange(hunk.OldStart, hunk.OldLines),
+			hunkRange(hunk.NewStart, hunk.NewLines),
+		)
+		for _, line := range hunk.Lines {
+			text, noNewline := strings.CutSuffix(line.Text, noNewlineSentinel)
+			diff.WriteByte(line.Op)
+			diff.WriteString(text)
+			diff.WriteByte('\n')
+			if noNewline {
+				diff.WriteString(noNewlineMarker + "\n")
+			}
+		}
+	}
+	return diff.String()
+}
+
+// Method applies a unified diff to the given content. Hunks are located at
+// their stated line numbers first and then by searching outward for their
+// context, so small offsets are tolerated. The final newline is kept unless
+// a "\ No newline at end of file" marker changes it. If any hunk cannot be
+// placed a *PatchConflictError is returned and the content is left
+// untouched.
+//
+// Parameters:
+//  - content: The original content.
+//  - patch: The unified diff to apply.
+//
+// Returns:
+//  - string: The patched content.
+//  - error: A parse error or a *PatchConflictError.
+func ApplyPatch(content, patch string) (string, error) {
+	hunks, err := ParsePatch(patch)
+	if err != nil {
+		return "", err
+	}
+	if len(hunks) == 0 {
+		return "", fmt.Errorf("patch contains no hunks")
+	}
+	lines := splitLines(content)
+	trailingNewline := content == "" || strings.HasSuffix(content, "\n")
+	var result []string
+	var conflicts []PatchConflict
+	cursor, offset := 0, 0
+	for i, hunk := range hunks {
+		var oldBlock, newBlock []string
+		oldMarker, newMarker := false, false
+		for _, line := range hunk.Lines {
+			if line.Op != '+' {
+				oldBlock = append(oldBlock, line.Text)
+				oldMarker = oldMarker || line.NoNewline
+			}
+			if line.Op != '-' {
+				newBlock = append(newBlock, line.Text)
+				newMarker = newMarker || line.NoNewline
+			}
+		}
+		expected := hunk.OldStart - 1 + offset
+		if hunk.OldLines == 0 {
+			// Pure insertions reference the line after which to insert
+			expected = hunk.OldStart + offset
+		}
+		position := locateBlock(lines, oldBlock, expected, cursor)
+		if position < 0 {
+			conflicts = append(conflicts, PatchConflict{
+				Hunk:     i + 1,
+				Line:     hunk.OldStart,
+				Expected: oldBlock,
+				Actual:   sliceLines(lines, expected, len(oldBlock)),
+			})
+			continue
+		}
+		// A marker on the new side removes the final newline; one only on
+		// the old side adds it
+		if newMarker {
+			trailingNewline = false
+		} else if oldMarker {
+			trailingNewline = true
+		}
+		result = append(result, lines[cursor:position]...)
+		result = append(result, newBlock...)
+		cursor = position + len(oldBlock)
+		offset = position - (hunk.OldStart - 1)
+		if hunk.OldLines == 0 {
+			offset = position - hunk.OldStart
+		}
+	}
+	if len(conflicts) > 0 {
+		return "", &PatchConflictError{Conflicts: conflicts}
+	}
+	result = append(result, lines[cursor:]...)
+	patched := strings.Join(result, "\n")
+	if trailingNewline && len(result) > 0 {
+		patched += "\n"
+	}
+	return patched, nil
+}
+
+// Method parses the hunks of a unified diff. File headers ("---", "+++",
+// "diff", "index") are skipped. Inside a hunk, lines starting with "--- "
+// or "+++ " are removed or added lines, e.g. a deleted SQL comment, until
+// the hunk has as many lines as its header counts.
+//
+// Parameters:
+//  - patch: The unified diff text.
+//
+// Returns:
+//  - []Hunk: The parsed hunks, in order.
+//  - error: Error encountered while parsing, if any.
+func ParsePatch(patch string) ([]Hunk, error) {
+	var hunks []Hunk
+	var current *Hunk
+	// Old and new lines of the current hunk seen so far
+	oldSeen, newSeen := 0, 0
+	for number, line := range strings.Split(patch, "\n") {
+		if matches := hunkHeaderRegexp.FindStringSubmatch(line); matches != nil {
+			hunks = append(hunks, Hunk{
+				OldStart: atoiDefault(matches[1], 0),
+				OldLines: atoiDefault(matches[2], 1),
+				NewStart: atoiDefault(matches[3], 0),
+				NewLines: atoiDefault(matches[4], 1),
+			})
+			current = &hunks[len(hunks)-1]
+			oldSeen, newSeen = 0, 0
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if strings.HasPrefix(line, `\ No newline`) {
+			// The marker applies to the line before it
+			if count := len(current.Lines); count > 0 {
+				current.Lines[count-1].NoNewline = true
+			}
+			continue
+		}
+		complete := oldSeen >= current.OldLines && newSeen >= current.NewLines
+		if complete && (strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ ")) {
+			continue
+		}
+		if line == "" {
+			// Editors and models often strip the space of empty context lines
+			current.Lines = append(current.Lines, diffLine{Op: ' '})
+			oldSeen, newSeen = oldSeen+1, newSeen+1
+			continue
+		}
+		switch line[0] {
+		case ' ', '-', '+':
+			current.Lines = append(
+				current.Lines, diffLine{Op: line[0], Text: line[1:]},
+			)
+			if line[0] != '+' {
+				oldSeen++
+			}
+			if line[0] != '-' {
+				newSeen++
+			}
+		default:
+			return nil, fmt.Errorf(
+				"malformed patch line %d: %q", number+1, line,
+			)
+		}
+	}
+	// Drop the blank context lines produced by a trailing newline
+	for i := range hunks {
+		hunks[i].Lines = trimHunk(hunks[i])
+	}
+	return hunks, nil
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Method computes a line level edit script using a longest common
+// subsequence table.
+func diffLines(oldLines, newLines []string) []diffLine {
+	n, m := len(oldLines), len(newLines)
+	if n*m > maxDiffCells {
+		var ops []diffLine
+		for _, line := range oldLines {
+			ops = append(ops, diffLine{Op: '-', Text: line})
+		}
+		for _, line := range newLines {
+			ops = append(ops, diffLine{Op: '+', Text: line})
+		}
+		return ops
+	}
+	table := make([][]int, n+1)
+	for i := range table {
+		table[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else if table[i+1][j] >= table[i][j+1] {
+				table[i][j] = table[i+1][j]
+			} else {
+				table[i][j] = table[i][j+1]
+			}
+		}
+	}
+	var ops []diffLine
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case oldLines[i] == newLines[j]:
+			ops = append(ops, diffLine{Op: ' ', Text: oldLines[i]})
+			i++
+			j++
+		case table[i+1][j] >= table[i][j+1]:
+			ops = append(ops, diffLine{Op: '-', Text: oldLines[i]})
+			i++
+		default:
+			ops = append(ops, diffLine{Op: '+', Text: newLines[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffLine{Op: '-', Text: oldLines[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffLine{Op: '+', Text: newLines[j]})
+	}
+	return ops
+}
+
+// Method groups an edit script into hunks with surrounding context lines.
+func groupHunks(ops []diffLine) []Hunk {
+	var hunks []Hunk
+	oldLine, newLine := 1, 1
+	for i := 0; i < len(ops); {
+		if ops[i].Op == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+		// Found a change; back up to include leading context
+		start := i
+		for start > 0 && i-start < diffContextLines && ops[start-1].Op == ' ' {
+			start--
+		}
+		hunk := Hunk{
+			OldStart: oldLine - (i - start),
+			NewStart: newLine - (i - start),
+		}
+		// Extend until there are more than two context blocks of equal lines
+		end := i
+		for end < len(ops) {
+			if ops[end].Op != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].Op == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContextLines {
+				end += min(run-end, diffContextLines)
+				break
+			}
+			end = run
+		}
+		for _, op := range ops[start:end] {
+			hunk.Lines = append(hunk.Lines, op)
+			if op.Op != '+' {
+				hunk.OldLines++
+			}
+			if op.Op != '-' {
+				hunk.NewLines++
+			}
+		}
+		for _, op := range ops[i:end] {
+			if op.Op != '+' {
+				oldLine++
+			}
+			if op.Op != '-' {
+				newLine++
+			}
+		}
+		hunks = append(hunks, hunk)
+		i = end
+	}
+	return hunks
+}
+
+// Method finds where a block of lines occurs, preferring the expected
+// position and searching outward from it. Matches before the cursor are
+// ignored so hunks are applied in order.
+func locateBlock(lines, block []string, expected, cursor int) int {
+	matches := func(position int) bool {
+		if position < cursor || position+len(block) > len(lines) {
+			return false
+		}
+		for k, line := range block {
+			if lines[position+k] != line {
+				return false
+			}
+		}
+		return true
+	}
+	for distance := 0; distance <= len(lines); distance++ {
+		if matches(expected - distance) {
+			return expected - distance
+		}
+		if distance > 0 && matches(expected+distance) {
+			return expected + distance
+		}
+	}
+	return -1
+}
+
+/* ************************************************************************* */
+/* ************************************************************************* */
+/* ************************************************************************* */
+
+// Method splits content into lines without the trailing empty element
+// produced by a final newline.
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// Method splits content into lines like splitLines, marking the last line
+// with noNewlineSentinel if the content does not end with a newline.
+func markNoNewline(content string) []string {
+	lines := splitLines(content)
+	if len(lines) > 0 && !strings.HasSuffix(content, "\n") {
+		lines[len(lines)-1] += noNewlineSentinel
+	}
+	return lines
+}
+
+// Method returns up to count lines starting at position, clamped to bounds.
+func sliceLines(lines []string, position, count int) []string {
+	if position < 0 {
+		position = 0
+	}
+	if position > len(lines) {
+		position = len(lines)
+	}
+	end := min(position+count, len(lines))
+	return lines[position:end]
+}
+
+// Method trims blank context lines that only exist because the hunk's line
+// counts were exceeded, which happens when the patch ends with newlines.
+func trimHunk(hunk Hunk) []diffLine {
+	lines := hunk.Lines
+	for len(lines) > 0 {
+		oldCount, newCount := 0, 0
+		for _, line := range lines {
+			if line.Op != '+' {
+				oldCount++
+			}
+			if line.Op != '-' {
+				newCount++
+			}
+		}
+		last := lines[len(lines)-1]
+		overflow := oldCount > hunk.OldLines || newCount > hunk.NewLines
+		if !overflow || last.Op != ' ' || last.Text != "" || last.NoNewline {
+			break
+		}
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Method formats a hunk range as "start,count", using the short form for
+// single lines.
+func hunkRange(start, count int) string {
+	if count == 0 && start > 0 {
+		// Empty ranges refer to the line before the change
+		start--
+	}
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// Method parses an integer, returning a fallback for empty strings.
+func atoiDefault(value string, fallback int) int {
+	if value == "" {
+		return fallback
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return fallback
+	}
+	return number
+}
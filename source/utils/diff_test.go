@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiffRoundTrip(t *testing.T) {
+	long := strings.Repeat("line\n", 20)
+	tests := []struct {
+		name     string
+		old, new string
+	}{
+		{"change one line", "a\nb\nc\n", "a\nB\nc\n"},
+		{"insert at start", "a\nb\n", "x\na\nb\n"},
+		{"append lines", "a\nb\n", "a\nb\nc\nd\n"},
+		{"delete everything", "a\nb\n", ""},
+		{"create from empty", "", "a\nb\n"},
+		{"two hunks", "1\n" + long + "2\n" + long + "3\n", "one\n" + long + "2\n" + long + "three\n"},
+		{"add final newline", "a\nb", "a\nb\n"},
+		{"remove final newline", "a\nb\n", "a\nb"},
+		{"change last line without newline", "a\nb", "a\nc"},
+		{"keep missing newline", "a\nb\nc\nd\ne\nf", "A\nb\nc\nd\ne\nf"},
+		{"append to line without newline", "a", "a\nb"},
+		{"single line newline only", "a", "a\n"},
+		{"delete line starting with dashes", "SELECT 1;\n-- old comment\nSELECT 2;\n", "SELECT 1;\nSELECT 2;\n"},
+		{"add line starting with pluses", "a\n", "a\n++ x\n"},
+		{"replace header-like lines", "--- a\n+++ b\n", "+++ b\n--- a\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diff := UnifiedDiff("file.txt", test.old, test.new)
+			if !strings.Contains(diff, "@@") {
+				t.Fatalf("diff has no hunks:\n%s", diff)
+			}
+			patched, err := ApplyPatch(test.old, diff)
+			if err != nil {
+				t.Fatalf("ApplyPatch: %v\n%s", err, diff)
+			}
+			if patched != test.new {
+				t.Errorf("got %q, want %q\n%s", patched, test.new, diff)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiffIdentical(t *testing.T) {
+	if diff := UnifiedDiff("file.txt", "a\n", "a\n"); diff != "" {
+		t.Errorf("got %q, want an empty diff", diff)
+	}
+}
+
+func TestUnifiedDiffNoNewlineMarker(t *testing.T) {
+	diff := UnifiedDiff("file.txt", "a\nb\n", "a\nb")
+	want := "--- a/file.txt\n+++ b/file.txt\n@@ -1,2 +1,2 @@\n a\n-b\n+b\n" +
+		noNewlineMarker + "\n"
+	if diff != want {
+		t.Errorf("got:\n%s\nwant:\n%s", diff, want)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		patch   string
+		want    string
+	}{
+		{
+			name:    "offset hunk",
+			content: "x\ny\na\nb\nc\n",
+			patch:   "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:    "x\ny\na\nB\nc\n",
+		},
+		{
+			name:    "stripped space on empty context line",
+			content: "a\n\nb\n",
+			patch:   "@@ -1,3 +1,3 @@\n a\n\n-b\n+c\n",
+			want:    "a\n\nc\n",
+		},
+		{
+			name:    "missing newline kept without a marker",
+			content: "a\nb",
+			patch:   "@@ -1,2 +1,2 @@\n-a\n+A\n b\n",
+			want:    "A\nb",
+		},
+		{
+			name:    "marker on context line",
+			content: "a\nb",
+			patch:   "@@ -1,2 +1,2 @@\n-a\n+A\n b\n" + noNewlineMarker + "\n",
+			want:    "A\nb",
+		},
+		{
+			name:    "marker removes newline",
+			content: "a\n",
+			patch:   "@@ -1 +1 @@\n-a\n+a\n" + noNewlineMarker + "\n",
+			want:    "a",
+		},
+		{
+			name:    "marker adds newline",
+			content: "a",
+			patch:   "@@ -1 +1 @@\n-a\n" + noNewlineMarker + "\n+a\n",
+			want:    "a\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			patched, err := ApplyPatch(test.content, test.patch)
+			if err != nil {
+				t.Fatalf("ApplyPatch: %v", err)
+			}
+			if patched != test.want {
+				t.Errorf("got %q, want %q", patched, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyPatchConflict(t *testing.T) {
+	content := "a\nb\nc\n"
+	_, err := ApplyPatch(content, "@@ -1,2 +1,2 @@\n a\n-x\n+y\n")
+	conflict, ok := err.(*PatchConflictError)
+	if !ok {
+		t.Fatalf("got %v, want a *PatchConflictError", err)
+	}
+	if len(conflict.Conflicts) != 1 || conflict.Conflicts[0].Hunk != 1 {
+		t.Errorf("unexpected conflicts: %+v", conflict.Conflicts)
+	}
+}
+
+func TestApplyPatchWithoutHunks(t *testing.T) {
+	if _, err := ApplyPatch("a\n", "--- a/file\n+++ b/file\n"); err == nil {
+		t.Error("expected an error for a patch without hunks")
+	}
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// Analysis parameters of the cepstral features, as commonly used for
+// speech: 25 ms frames every 10 ms, 26 mel bands from 100 Hz.
+const (
+	mfccFrame       = 0.025
+	mfccHop         = 0.010
+	mfccBands       = 26
+	mfccLowFreq     = 100.0
+	mfccPreemphasis = 0.97
+)
+
+// Method computes mel-frequency cepstral coefficients of mono 16-bit PCM,
+// one vector per 25 ms frame. The first coefficient, which only measures
+// loudness, is left out.
+//
+// Parameters:
+//  - samples: The input samples.
+//  - sampleRate: The sample rate of the input.
+//  - coefficients: The number of coefficients per frame, at most 25.
+//
+// Returns:
+//  - [][]float64: The coefficients of each frame.
+func MFCC(samples []int16, sampleRate, coefficients int) [][]float64 {
+	frameLength := int(mfccFrame * float64(sampleRate))
+	hop := int(mfccHop * float64(sampleRate))
+	if frameLength <= 0 || hop <= 0 || len(samples) < frameLength {
+		return nil
+	}
+	size := 1
+	for size < frameLength {
+		size *= 2
+	}
+	coefficients = min(coefficients, mfccBands-1)
+	filters := melFilterbank(size, sampleRate)
+	window := make([]float64, frameLength)
+	for i := range window {
+		window[i] = 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(frameLength-1))
+	}
+	var features [][]float64
+	buffer := make([]complex128, size)
+	energies := make([]float64, mfccBands)
+	for start := 0; start+frameLength <= len(samples); start += hop {
+		previous := 0.0
+		if start > 0 {
+			previous = float64(samples[start-1])
+		}
+		for i := range buffer {
+			buffer[i] = 0
+		}
+		for i := 0; i < frameLength; i++ {
+			sample := float64(samples[start+i])
+			buffer[i] = complex((sample-mfccPreemphasis*previous)*window[i], 0)
+			previous = sample
+		}
+		fft(buffer)
+		for band, filter := range filters {
+			energy := 0.0
+			for bin, weight := range filter {
+				if weight > 0 {
+					power := cmplx.Abs(buffer[bin])
+					energy += weight * power * power
+				}
+			}
+			energies[band] = math.Log(energy + 1e-10)
+		}
+		// Decorrelate the band energies with a DCT-II
+		frame := make([]float64, coefficients)
+		for k := range frame {
+			sum := 0.0
+			for band, energy := range energies {
+				sum += energy * math.Cos(
+					math.Pi*float64(k+1)*(float64(band)+0.5)/mfccBands,
+				)
+			}
+			frame[k] = sum
+		}
+		features = append(features, frame)
+	}
+	return features
+}
+
+// Method builds triangular filters evenly spaced on the mel scale, each a
+// weight per FFT bin.
+func melFilterbank(size, sampleRate int) [][]float64 {
+	mel := func(hz float64) float64 { return 2595 * math.Log10(1+hz/700) }
+	hz := func(mel float64) float64 { return 700 * (math.Pow(10, mel/2595) - 1) }
+	low, high := mel(mfccLowFreq), mel(float64(sampleRate)/2)
+	bins := make([]float64, mfccBands+2)
+	for i := range bins {
+		frequency := hz(low + (high-low)*float64(i)/float64(mfccBands+1))
+		bins[i] = frequency * float64(size) / float64(sampleRate)
+	}
+	filters := make([][]float64, mfccBands)
+	for band := range filters {
+		filters[band] = make([]float64, size/2+1)
+		left, center, right := bins[band], bins[band+1], bins[band+2]
+		for bin := range filters[band] {
+			position := float64(bin)
+			switch {
+			case position > left && position <= center:
+				filters[band][bin] = (position - left) / (center - left)
+			case position > center && position < right:
+				filters[band][bin] = (right - position) / (right - center)
+			}
+		}
+	}
+	return filters
+}
+
+// Method computes an in-place radix-2 fast Fourier transform. The length
+// of the input must be a power of two.
+func fft(values []complex128) {
+	n := len(values)
+	// Reorder the values by bit-reversed index
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			values[i], values[j] = values[j], values[i]
+		}
+	}
+	for length := 2; length <= n; length <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(length)))
+		for start := 0; start < n; start += length {
+			twiddle := complex(1, 0)
+			for k := 0; k < length/2; k++ {
+				even := values[start+k]
+				odd := values[start+k+length/2] * twiddle
+				values[start+k] = even + odd
+				values[start+k+length/2] = even - odd
+				twiddle *= step
+			}
+		}
+	}
+}